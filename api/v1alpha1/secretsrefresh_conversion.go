@@ -0,0 +1,447 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// ConversionDataAnnotation holds the JSON encoded v1beta1 fields v1alpha1 can't
+// represent, so they survive a round trip through v1alpha1
+const ConversionDataAnnotation = "traktor.gdxcloud.net/conversion-data"
+
+// conversionData are the v1beta1 fields without a v1alpha1 counterpart
+type conversionData struct {
+	Notifications *v1beta1.Notifications `json:"notifications,omitempty"`
+}
+
+// ConvertTo converts this SecretsRefresh to the Hub version (v1beta1).
+func (src *SecretsRefresh) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1beta1.SecretsRefresh)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 SecretsRefresh but got %T", dstRaw)
+	}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	convertSpecTo(&src.Spec, &dst.Spec)
+	convertStatusTo(&src.Status, &dst.Status)
+	return restoreConversionData(&dst.ObjectMeta, &dst.Spec)
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *SecretsRefresh) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1beta1.SecretsRefresh)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 SecretsRefresh but got %T", srcRaw)
+	}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	convertSpecFrom(&src.Spec, &dst.Spec)
+	convertStatusFrom(&src.Status, &dst.Status)
+	return saveConversionData(&dst.ObjectMeta, &src.Spec)
+}
+
+// ConvertTo converts this ClusterSecretsRefresh to the Hub version (v1beta1).
+func (src *ClusterSecretsRefresh) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1beta1.ClusterSecretsRefresh)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 ClusterSecretsRefresh but got %T", dstRaw)
+	}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	convertSpecTo(&src.Spec, &dst.Spec)
+	convertStatusTo(&src.Status, &dst.Status)
+	return restoreConversionData(&dst.ObjectMeta, &dst.Spec)
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *ClusterSecretsRefresh) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1beta1.ClusterSecretsRefresh)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 ClusterSecretsRefresh but got %T", srcRaw)
+	}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	convertSpecFrom(&src.Spec, &dst.Spec)
+	convertStatusFrom(&src.Status, &dst.Status)
+	return saveConversionData(&dst.ObjectMeta, &src.Spec)
+}
+
+// saveConversionData stores the v1beta1 only fields of the spec in an annotation
+func saveConversionData(meta *metav1.ObjectMeta, spec *v1beta1.SecretsRefreshSpec) error {
+	data := conversionData{Notifications: spec.Notifications}
+	if data == (conversionData{}) {
+		return nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode conversion data: %w", err)
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[ConversionDataAnnotation] = string(raw)
+	return nil
+}
+
+// restoreConversionData restores the v1beta1 only fields of the spec from the annotation
+func restoreConversionData(meta *metav1.ObjectMeta, spec *v1beta1.SecretsRefreshSpec) error {
+	raw, ok := meta.Annotations[ConversionDataAnnotation]
+	if !ok {
+		return nil
+	}
+	delete(meta.Annotations, ConversionDataAnnotation)
+	if len(meta.Annotations) == 0 {
+		meta.Annotations = nil
+	}
+
+	var data conversionData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return fmt.Errorf("failed to decode conversion data: %w", err)
+	}
+	spec.Notifications = data.Notifications
+	return nil
+}
+
+// convertSpecTo converts a v1alpha1 spec to the structured v1beta1 spec
+func convertSpecTo(src *SecretsRefreshSpec, dst *v1beta1.SecretsRefreshSpec) {
+	dst.Suspend = src.Suspend
+	dst.Priority = src.Priority
+	dst.Targets = v1beta1.Targets{
+		NamespaceSelector: src.NamespaceSelector.DeepCopy(),
+		SecretSelector:    src.SecretSelector.DeepCopy(),
+		AllNamespaces:     src.AllNamespaces,
+	}
+	dst.Triggers = v1beta1.Triggers{
+		RestartWhen: src.RestartWhen,
+	}
+	if src.ChangedBy != nil {
+		cb := v1beta1.ChangedBy(*src.ChangedBy.DeepCopy())
+		dst.Triggers.ChangedBy = &cb
+	}
+	if src.FlapDetection != nil {
+		fd := v1beta1.FlapDetection(*src.FlapDetection)
+		dst.Triggers.FlapDetection = &fd
+	}
+	if src.VersionedSecrets != nil {
+		vs := v1beta1.VersionedSecrets(*src.VersionedSecrets.DeepCopy())
+		dst.Triggers.VersionedSecrets = &vs
+	}
+
+	dst.Rollout = v1beta1.Rollout{
+		Strategy:         v1beta1.RestartStrategyType(src.RestartStrategy),
+		DisruptionPolicy: v1beta1.DisruptionPolicy(src.DisruptionPolicy),
+		Cooldown:         src.Cooldown.DeepCopy(),
+		Approval: v1beta1.Approval{
+			Required: src.RequireApproval,
+			TTL:      src.ApprovalTTL.DeepCopy(),
+		},
+		DryRun: src.DryRun,
+	}
+	if src.GitOps != nil {
+		dst.Rollout.GitOps = &v1beta1.GitOps{
+			Mode:         v1beta1.GitOpsMode(src.GitOps.Mode),
+			FieldManager: src.GitOps.FieldManager,
+			Managers:     copySlice(src.GitOps.Managers),
+		}
+	}
+	if src.MaintenanceWindows != nil {
+		dst.Rollout.MaintenanceWindows = make([]v1beta1.MaintenanceWindow, len(src.MaintenanceWindows))
+		for i, w := range src.MaintenanceWindows {
+			dst.Rollout.MaintenanceWindows[i] = v1beta1.MaintenanceWindow(w)
+		}
+	}
+	if src.Rollback != nil {
+		rb := v1beta1.Rollback(*src.Rollback.DeepCopy())
+		dst.Rollout.Rollback = &rb
+	}
+
+	dst.Actions = nil
+	if src.Actions != nil {
+		dst.Actions = make([]v1beta1.Action, len(src.Actions))
+		for i, action := range src.Actions {
+			dst.Actions[i] = v1beta1.Action{
+				Name:  action.Name,
+				Type:  v1beta1.ActionType(action.Type),
+				Order: v1beta1.ActionOrder(action.Order),
+			}
+			if runJob := action.RunJob; runJob != nil {
+				dst.Actions[i].RunJob = &v1beta1.RunJobAction{JobTemplate: runJob.JobTemplate.DeepCopy()}
+				if runJob.CronJobRef != nil {
+					ref := v1beta1.CronJobReference(*runJob.CronJobRef)
+					dst.Actions[i].RunJob.CronJobRef = &ref
+				}
+			}
+		}
+	}
+	dst.Notifications = nil
+}
+
+// convertSpecFrom converts a structured v1beta1 spec to the v1alpha1 spec
+func convertSpecFrom(src *v1beta1.SecretsRefreshSpec, dst *SecretsRefreshSpec) {
+	*dst = SecretsRefreshSpec{
+		Suspend:           src.Suspend,
+		Priority:          src.Priority,
+		NamespaceSelector: src.Targets.NamespaceSelector.DeepCopy(),
+		SecretSelector:    src.Targets.SecretSelector.DeepCopy(),
+		AllNamespaces:     src.Targets.AllNamespaces,
+		RestartWhen:       src.Triggers.RestartWhen,
+		DryRun:            src.Rollout.DryRun,
+		RequireApproval:   src.Rollout.Approval.Required,
+		ApprovalTTL:       src.Rollout.Approval.TTL.DeepCopy(),
+		DisruptionPolicy:  DisruptionPolicy(src.Rollout.DisruptionPolicy),
+		Cooldown:          src.Rollout.Cooldown.DeepCopy(),
+		RestartStrategy:   RestartStrategyType(src.Rollout.Strategy),
+	}
+	if src.Triggers.ChangedBy != nil {
+		cb := ChangedBy(*src.Triggers.ChangedBy.DeepCopy())
+		dst.ChangedBy = &cb
+	}
+	if src.Triggers.FlapDetection != nil {
+		fd := FlapDetection(*src.Triggers.FlapDetection)
+		dst.FlapDetection = &fd
+	}
+	if src.Triggers.VersionedSecrets != nil {
+		vs := VersionedSecrets(*src.Triggers.VersionedSecrets.DeepCopy())
+		dst.VersionedSecrets = &vs
+	}
+	if src.Rollout.GitOps != nil {
+		dst.GitOps = &GitOps{
+			Mode:         GitOpsMode(src.Rollout.GitOps.Mode),
+			FieldManager: src.Rollout.GitOps.FieldManager,
+			Managers:     copySlice(src.Rollout.GitOps.Managers),
+		}
+	}
+	if src.Rollout.MaintenanceWindows != nil {
+		dst.MaintenanceWindows = make([]MaintenanceWindow, len(src.Rollout.MaintenanceWindows))
+		for i, w := range src.Rollout.MaintenanceWindows {
+			dst.MaintenanceWindows[i] = MaintenanceWindow(w)
+		}
+	}
+	if src.Rollout.Rollback != nil {
+		rb := Rollback(*src.Rollout.Rollback.DeepCopy())
+		dst.Rollback = &rb
+	}
+	if src.Actions != nil {
+		dst.Actions = make([]Action, len(src.Actions))
+		for i, action := range src.Actions {
+			dst.Actions[i] = Action{
+				Name:  action.Name,
+				Type:  ActionType(action.Type),
+				Order: ActionOrder(action.Order),
+			}
+			if runJob := action.RunJob; runJob != nil {
+				dst.Actions[i].RunJob = &RunJobAction{JobTemplate: runJob.JobTemplate.DeepCopy()}
+				if runJob.CronJobRef != nil {
+					ref := CronJobReference(*runJob.CronJobRef)
+					dst.Actions[i].RunJob.CronJobRef = &ref
+				}
+			}
+		}
+	}
+}
+
+// convertStatusTo converts a v1alpha1 status to v1beta1, the fields are the same
+func convertStatusTo(src *SecretsRefreshStatus, dst *v1beta1.SecretsRefreshStatus) {
+	*dst = v1beta1.SecretsRefreshStatus{
+		LastRefreshTime:    src.LastRefreshTime.DeepCopy(),
+		Conditions:         copySlice(src.Conditions),
+		ObservedGeneration: src.ObservedGeneration,
+		MatchedNamespaces:  src.MatchedNamespaces,
+		MatchedSecrets:     src.MatchedSecrets,
+		MatchedWorkloads:   src.MatchedWorkloads,
+		LastRefreshNow:     src.LastRefreshNow,
+		RefreshHistory: convertSlice(src.RefreshHistory, func(r RefreshRecord) v1beta1.RefreshRecord {
+			return v1beta1.RefreshRecord{
+				SecretName:      r.SecretName,
+				SecretNamespace: r.SecretNamespace,
+				Version:         r.Version,
+				Workloads:       convertWorkloadsTo(r.Workloads),
+				Result:          r.Result,
+				Message:         r.Message,
+				RefreshNow:      r.RefreshNow,
+				GovernedBy: convertSlice(r.GovernedBy, func(g SecretsRefreshReference) v1beta1.SecretsRefreshReference {
+					return v1beta1.SecretsRefreshReference(g)
+				}),
+				Time: r.Time,
+			}
+		}),
+		PendingRestarts: convertSlice(src.PendingRestarts, func(p PendingRestart) v1beta1.PendingRestart {
+			return v1beta1.PendingRestart{
+				SecretName:      p.SecretName,
+				SecretNamespace: p.SecretNamespace,
+				DetectedAt:      p.DetectedAt,
+				Reason:          p.Reason,
+				NotBefore:       p.NotBefore.DeepCopy(),
+				PlanID:          p.PlanID,
+				Workloads:       convertWorkloadsTo(p.Workloads),
+				ExpiresAt:       p.ExpiresAt.DeepCopy(),
+			}
+		}),
+		DryRunRestarts: convertSlice(src.DryRunRestarts, func(d DryRunRestart) v1beta1.DryRunRestart {
+			return v1beta1.DryRunRestart(*d.DeepCopy())
+		}),
+		ApprovalHistory: convertSlice(src.ApprovalHistory, func(a ApprovalRecord) v1beta1.ApprovalRecord {
+			return v1beta1.ApprovalRecord(a)
+		}),
+		ActionRuns: convertSlice(src.ActionRuns, func(a ActionRun) v1beta1.ActionRun {
+			return v1beta1.ActionRun{
+				Action:          a.Action,
+				Order:           v1beta1.ActionOrder(a.Order),
+				SecretName:      a.SecretName,
+				SecretNamespace: a.SecretNamespace,
+				JobName:         a.JobName,
+				JobNamespace:    a.JobNamespace,
+				Result:          a.Result,
+				Message:         a.Message,
+				StartTime:       a.StartTime,
+				CompletionTime:  a.CompletionTime.DeepCopy(),
+			}
+		}),
+		RolloutChecks: convertSlice(src.RolloutChecks, func(c RolloutCheck) v1beta1.RolloutCheck {
+			return v1beta1.RolloutCheck{
+				SecretName:      c.SecretName,
+				SecretNamespace: c.SecretNamespace,
+				Version:         c.Version,
+				SnapshotName:    c.SnapshotName,
+				Workloads:       convertWorkloadsTo(c.Workloads),
+				Deadline:        c.Deadline,
+			}
+		}),
+		RollbackHistory: convertSlice(src.RollbackHistory, func(r RollbackRecord) v1beta1.RollbackRecord {
+			return v1beta1.RollbackRecord{
+				SecretName:      r.SecretName,
+				SecretNamespace: r.SecretNamespace,
+				Version:         r.Version,
+				SnapshotName:    r.SnapshotName,
+				FailedWorkloads: convertWorkloadsTo(r.FailedWorkloads),
+				Time:            r.Time,
+			}
+		}),
+	}
+}
+
+// convertStatusFrom converts a v1beta1 status to v1alpha1, the fields are the same
+func convertStatusFrom(src *v1beta1.SecretsRefreshStatus, dst *SecretsRefreshStatus) {
+	*dst = SecretsRefreshStatus{
+		LastRefreshTime:    src.LastRefreshTime.DeepCopy(),
+		Conditions:         copySlice(src.Conditions),
+		ObservedGeneration: src.ObservedGeneration,
+		MatchedNamespaces:  src.MatchedNamespaces,
+		MatchedSecrets:     src.MatchedSecrets,
+		MatchedWorkloads:   src.MatchedWorkloads,
+		LastRefreshNow:     src.LastRefreshNow,
+		RefreshHistory: convertSlice(src.RefreshHistory, func(r v1beta1.RefreshRecord) RefreshRecord {
+			return RefreshRecord{
+				SecretName:      r.SecretName,
+				SecretNamespace: r.SecretNamespace,
+				Version:         r.Version,
+				Workloads:       convertWorkloadsFrom(r.Workloads),
+				Result:          r.Result,
+				Message:         r.Message,
+				RefreshNow:      r.RefreshNow,
+				GovernedBy: convertSlice(r.GovernedBy, func(g v1beta1.SecretsRefreshReference) SecretsRefreshReference {
+					return SecretsRefreshReference(g)
+				}),
+				Time: r.Time,
+			}
+		}),
+		PendingRestarts: convertSlice(src.PendingRestarts, func(p v1beta1.PendingRestart) PendingRestart {
+			return PendingRestart{
+				SecretName:      p.SecretName,
+				SecretNamespace: p.SecretNamespace,
+				DetectedAt:      p.DetectedAt,
+				Reason:          p.Reason,
+				NotBefore:       p.NotBefore.DeepCopy(),
+				PlanID:          p.PlanID,
+				Workloads:       convertWorkloadsFrom(p.Workloads),
+				ExpiresAt:       p.ExpiresAt.DeepCopy(),
+			}
+		}),
+		DryRunRestarts: convertSlice(src.DryRunRestarts, func(d v1beta1.DryRunRestart) DryRunRestart {
+			return DryRunRestart(*d.DeepCopy())
+		}),
+		ApprovalHistory: convertSlice(src.ApprovalHistory, func(a v1beta1.ApprovalRecord) ApprovalRecord {
+			return ApprovalRecord(a)
+		}),
+		ActionRuns: convertSlice(src.ActionRuns, func(a v1beta1.ActionRun) ActionRun {
+			return ActionRun{
+				Action:          a.Action,
+				Order:           ActionOrder(a.Order),
+				SecretName:      a.SecretName,
+				SecretNamespace: a.SecretNamespace,
+				JobName:         a.JobName,
+				JobNamespace:    a.JobNamespace,
+				Result:          a.Result,
+				Message:         a.Message,
+				StartTime:       a.StartTime,
+				CompletionTime:  a.CompletionTime.DeepCopy(),
+			}
+		}),
+		RolloutChecks: convertSlice(src.RolloutChecks, func(c v1beta1.RolloutCheck) RolloutCheck {
+			return RolloutCheck{
+				SecretName:      c.SecretName,
+				SecretNamespace: c.SecretNamespace,
+				Version:         c.Version,
+				SnapshotName:    c.SnapshotName,
+				Workloads:       convertWorkloadsFrom(c.Workloads),
+				Deadline:        c.Deadline,
+			}
+		}),
+		RollbackHistory: convertSlice(src.RollbackHistory, func(r v1beta1.RollbackRecord) RollbackRecord {
+			return RollbackRecord{
+				SecretName:      r.SecretName,
+				SecretNamespace: r.SecretNamespace,
+				Version:         r.Version,
+				SnapshotName:    r.SnapshotName,
+				FailedWorkloads: convertWorkloadsFrom(r.FailedWorkloads),
+				Time:            r.Time,
+			}
+		}),
+	}
+}
+
+func convertWorkloadsTo(workloads []WorkloadReference) []v1beta1.WorkloadReference {
+	return convertSlice(workloads, func(w WorkloadReference) v1beta1.WorkloadReference {
+		return v1beta1.WorkloadReference(w)
+	})
+}
+
+func convertWorkloadsFrom(workloads []v1beta1.WorkloadReference) []WorkloadReference {
+	return convertSlice(workloads, func(w v1beta1.WorkloadReference) WorkloadReference {
+		return WorkloadReference(w)
+	})
+}
+
+// convertSlice converts every element of a slice, keeping nil slices nil
+func convertSlice[S, D any](src []S, convert func(S) D) []D {
+	if src == nil {
+		return nil
+	}
+	dst := make([]D, len(src))
+	for i := range src {
+		dst[i] = convert(src[i])
+	}
+	return dst
+}
+
+// copySlice returns a copy of a slice, keeping nil slices nil
+func copySlice[T any](src []T) []T {
+	return convertSlice(src, func(v T) T { return v })
+}
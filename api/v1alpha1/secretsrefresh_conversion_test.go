@@ -0,0 +1,151 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/randfill"
+
+	"github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// fuzzIterations is how many random objects each round trip is checked with
+const fuzzIterations = 500
+
+// newFiller returns a filler for random SecretsRefresh objects. Job templates are kept
+// small, the conversion only copies them.
+func newFiller(seed int64) *randfill.Filler {
+	return randfill.NewWithSeed(seed).NilChance(0.3).NumElements(0, 3).Funcs(
+		func(template *batchv1.JobTemplateSpec, c randfill.Continue) {
+			c.Fill(&template.ObjectMeta.Name)
+			template.Spec.Template.Spec.Containers = []corev1.Container{{Name: c.String(10), Image: c.String(10)}}
+		},
+	)
+}
+
+var _ = Describe("SecretsRefresh conversion", func() {
+	It("should round trip SecretsRefresh from v1alpha1 through v1beta1", func() {
+		f := newFiller(GinkgoRandomSeed())
+		for range fuzzIterations {
+			original := &SecretsRefresh{}
+			f.Fill(original)
+
+			hub := &v1beta1.SecretsRefresh{}
+			Expect(original.DeepCopy().ConvertTo(hub)).To(Succeed())
+			restored := &SecretsRefresh{}
+			Expect(restored.ConvertFrom(hub)).To(Succeed())
+
+			Expect(apiequality.Semantic.DeepEqual(original.Spec, restored.Spec)).To(BeTrue(), "spec %+v became %+v", original.Spec, restored.Spec)
+			Expect(apiequality.Semantic.DeepEqual(original.Status, restored.Status)).To(BeTrue(), "status %+v became %+v", original.Status, restored.Status)
+			Expect(apiequality.Semantic.DeepEqual(original.ObjectMeta, restored.ObjectMeta)).To(BeTrue())
+		}
+	})
+
+	It("should round trip SecretsRefresh from v1beta1 through v1alpha1", func() {
+		f := newFiller(GinkgoRandomSeed())
+		for range fuzzIterations {
+			original := &v1beta1.SecretsRefresh{}
+			f.Fill(original)
+
+			spoke := &SecretsRefresh{}
+			Expect(spoke.ConvertFrom(original.DeepCopy())).To(Succeed())
+			restored := &v1beta1.SecretsRefresh{}
+			Expect(spoke.ConvertTo(restored)).To(Succeed())
+
+			Expect(apiequality.Semantic.DeepEqual(original.Spec, restored.Spec)).To(BeTrue(), "spec %+v became %+v", original.Spec, restored.Spec)
+			Expect(apiequality.Semantic.DeepEqual(original.Status, restored.Status)).To(BeTrue(), "status %+v became %+v", original.Status, restored.Status)
+			Expect(apiequality.Semantic.DeepEqual(original.ObjectMeta, restored.ObjectMeta)).To(BeTrue())
+		}
+	})
+
+	It("should round trip ClusterSecretsRefresh in both directions", func() {
+		f := newFiller(GinkgoRandomSeed())
+		for range fuzzIterations {
+			spoke := &ClusterSecretsRefresh{}
+			f.Fill(spoke)
+			hub := &v1beta1.ClusterSecretsRefresh{}
+			Expect(spoke.DeepCopy().ConvertTo(hub)).To(Succeed())
+			restoredSpoke := &ClusterSecretsRefresh{}
+			Expect(restoredSpoke.ConvertFrom(hub)).To(Succeed())
+			Expect(apiequality.Semantic.DeepEqual(spoke.ObjectMeta, restoredSpoke.ObjectMeta)).To(BeTrue())
+			Expect(apiequality.Semantic.DeepEqual(spoke.Spec, restoredSpoke.Spec)).To(BeTrue())
+			Expect(apiequality.Semantic.DeepEqual(spoke.Status, restoredSpoke.Status)).To(BeTrue())
+
+			hub = &v1beta1.ClusterSecretsRefresh{}
+			f.Fill(hub)
+			spoke = &ClusterSecretsRefresh{}
+			Expect(spoke.ConvertFrom(hub.DeepCopy())).To(Succeed())
+			restoredHub := &v1beta1.ClusterSecretsRefresh{}
+			Expect(spoke.ConvertTo(restoredHub)).To(Succeed())
+			Expect(apiequality.Semantic.DeepEqual(hub.ObjectMeta, restoredHub.ObjectMeta)).To(BeTrue())
+			Expect(apiequality.Semantic.DeepEqual(hub.Spec, restoredHub.Spec)).To(BeTrue())
+			Expect(apiequality.Semantic.DeepEqual(hub.Status, restoredHub.Status)).To(BeTrue())
+		}
+	})
+
+	It("should move the flat v1alpha1 fields into the v1beta1 sub-objects", func() {
+		spoke := &SecretsRefresh{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec: SecretsRefreshSpec{
+				SecretSelector:   &metav1.LabelSelector{MatchLabels: map[string]string{"auto-refresh": "enabled"}},
+				RestartWhen:      "true",
+				RequireApproval:  true,
+				ApprovalTTL:      &metav1.Duration{Duration: time.Hour},
+				RestartStrategy:  RestartStrategyDeletePods,
+				DisruptionPolicy: DisruptionPolicySkip,
+				Rollback:         &Rollback{FailureThreshold: 50},
+			},
+		}
+		hub := &v1beta1.SecretsRefresh{}
+		Expect(spoke.ConvertTo(hub)).To(Succeed())
+
+		Expect(hub.Spec.Targets.SecretSelector.MatchLabels).To(HaveKeyWithValue("auto-refresh", "enabled"))
+		Expect(hub.Spec.Triggers.RestartWhen).To(Equal("true"))
+		Expect(hub.Spec.Rollout.Approval.Required).To(BeTrue())
+		Expect(hub.Spec.Rollout.Approval.TTL.Duration).To(Equal(time.Hour))
+		Expect(hub.Spec.Rollout.Strategy).To(Equal(v1beta1.RestartStrategyDeletePods))
+		Expect(hub.Spec.Rollout.DisruptionPolicy).To(Equal(v1beta1.DisruptionPolicySkip))
+		Expect(hub.Spec.Rollout.Rollback.FailureThreshold).To(BeEquivalentTo(50))
+	})
+
+	It("should keep v1beta1 notifications in an annotation of the v1alpha1 object", func() {
+		hub := &v1beta1.SecretsRefresh{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec: v1beta1.SecretsRefreshSpec{
+				Notifications: &v1beta1.Notifications{Webhooks: []v1beta1.NotificationWebhook{{
+					Name: "chat",
+					URL:  "https://chat.example.com/hook",
+				}}},
+			},
+		}
+		spoke := &SecretsRefresh{}
+		Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		Expect(spoke.Annotations).To(HaveKey(ConversionDataAnnotation))
+
+		restored := &v1beta1.SecretsRefresh{}
+		Expect(spoke.ConvertTo(restored)).To(Succeed())
+		Expect(restored.Annotations).NotTo(HaveKey(ConversionDataAnnotation))
+		Expect(restored.Spec.Notifications).To(Equal(hub.Spec.Notifications))
+	})
+})
@@ -17,34 +17,648 @@ limitations under the License.
 package v1alpha1
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
-
 // SecretsRefreshSpec defines the desired state of SecretsRefresh.
 type SecretsRefreshSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Suspend stops handling secret changes until it is unset again. Changes that
+	// arrive meanwhile are not restarted for later; pending restarts are kept.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Priority decides which SecretsRefresh objects govern a secret several of them
+	// select: only those with the highest priority apply their policies, the others
+	// are marked Conflicting. Policies of objects with the same priority are merged.
+	// A namespaced SecretsRefresh never overrides a ClusterSecretsRefresh: the
+	// ClusterSecretsRefresh objects with the highest priority among them always govern,
+	// and namespaced ones can neither loosen their restartWhen nor put them in dry run.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
 
-	// NamespaceSelector defines label selector for filtering namespaces
+	// NamespaceSelector defines label selector for filtering namespaces. A SecretsRefresh
+	// only selects its own namespace and namespaces granting it access with the
+	// traktor.gdxcloud.net/allow-secretsrefresh-from annotation; a ClusterSecretsRefresh
+	// selects from all namespaces.
 	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
 
 	// SecretSelector defines label selector for filtering secrets within namespaces
 	SecretSelector *metav1.LabelSelector `json:"secretSelector,omitempty"`
+
+	// AllNamespaces confirms that a ClusterSecretsRefresh without a namespace selector
+	// selects every namespace of the cluster; without it such a ClusterSecretsRefresh
+	// selects none. It can't be combined with namespaceSelector.
+	// +optional
+	AllNamespaces bool `json:"allNamespaces,omitempty"`
+
+	// RestartWhen is a CEL expression that decides whether a secret change should
+	// restart a workload. It is evaluated once per workload and must return a bool.
+	// Available variables: oldSecret, newSecret, workload and namespaceObject (objects
+	// as maps, e.g. newSecret.metadata.annotations) and now (timestamp).
+	// When empty, every workload that uses the secret is restarted.
+	// +optional
+	RestartWhen string `json:"restartWhen,omitempty"`
+
+	// MaintenanceWindows restricts restarts to the given windows. Changes detected
+	// outside of every window are recorded in status.pendingRestarts and executed
+	// when the next window opens. When empty, restarts may happen at any time.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// DryRun evaluates matching and policies as usual but only records which
+	// workloads would be restarted, in events and status.dryRunRestarts, without
+	// patching them.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// RequireApproval holds restarts until a human approves them. The prepared plan is
+	// listed in status.pendingRestarts and executed once its ID is set as the
+	// traktor.gdxcloud.net/approve annotation on this object, or dropped when its ID
+	// is set as the traktor.gdxcloud.net/reject annotation.
+	// +optional
+	RequireApproval bool `json:"requireApproval,omitempty"`
+
+	// ApprovalTTL is how long a restart plan waits for approval before it expires. Defaults to 24h.
+	// +optional
+	ApprovalTTL *metav1.Duration `json:"approvalTTL,omitempty"`
+
+	// DisruptionPolicy decides what happens to workloads whose restart would cause
+	// downtime, e.g. Deployments with the Recreate strategy, a single replica that may
+	// become unavailable, or maxSurge 0. Allow restarts them anyway, Warn restarts them
+	// and emits a warning event, Skip leaves them alone and SurgeTemporarily switches
+	// them to a surging rolling update until the restart has rolled out or failed. Defaults to Warn.
+	// +kubebuilder:validation:Enum=Allow;Warn;Skip;SurgeTemporarily
+	// +optional
+	DisruptionPolicy DisruptionPolicy `json:"disruptionPolicy,omitempty"`
+
+	// Cooldown is the minimum time between two restarts of the same workload by
+	// Traktor, measured from the restartedAt annotation of its pod template. Restarts
+	// requested earlier are deferred until the cooldown has passed.
+	// +optional
+	Cooldown *metav1.Duration `json:"cooldown,omitempty"`
+
+	// ChangedBy holds changes by authors it doesn't allow for approval, as with
+	// requireApproval, instead of restarting workloads right away
+	// +optional
+	ChangedBy *ChangedBy `json:"changedBy,omitempty"`
+
+	// FlapDetection pauses restarts for a secret that changes too often, until it is stable again
+	// +optional
+	FlapDetection *FlapDetection `json:"flapDetection,omitempty"`
+
+	// RestartStrategy is how workloads are restarted. Workloads can override it with
+	// the traktor.gdxcloud.net/restart-strategy annotation. Defaults to TemplateAnnotation.
+	// +kubebuilder:validation:Enum=TemplateAnnotation;DeletePods;ScaleBounce;ServerSideApply
+	// +optional
+	RestartStrategy RestartStrategyType `json:"restartStrategy,omitempty"`
+
+	// GitOps restarts workloads without creating drift for GitOps tools such as
+	// Argo CD or Flux. It takes precedence over restartStrategy; the workload
+	// annotation still overrides it.
+	// +optional
+	GitOps *GitOps `json:"gitOps,omitempty"`
+
+	// Actions run side effects such as Jobs when a matching secret changes, before or
+	// after the workloads are restarted. A failed action ordered before the restarts
+	// blocks them.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Actions []Action `json:"actions,omitempty"`
+
+	// Rollback restores the previous data of a secret when the workloads restarted for
+	// its change fail to roll out. The previous data is kept in a snapshot Secret next
+	// to the secret before restarting.
+	// +optional
+	Rollback *Rollback `json:"rollback,omitempty"`
+
+	// VersionedSecrets handles series of immutable secrets such as app-db-v7 and
+	// app-db-v8: when a newer version appears, workloads referencing an older version
+	// are switched to it and unreferenced old versions are deleted after a retention period.
+	// +optional
+	VersionedSecrets *VersionedSecrets `json:"versionedSecrets,omitempty"`
+}
+
+// VersionedSecrets configures how versions of a secret are recognised.
+type VersionedSecrets struct {
+	// SeriesLabel is the label whose value is shared by all versions of a secret.
+	// Defaults to traktor.gdxcloud.net/series.
+	// +optional
+	SeriesLabel string `json:"seriesLabel,omitempty"`
+
+	// VersionLabel is the label holding the integer version of a secret. If empty,
+	// the version is taken from a -v<N> suffix of the secret name.
+	// +optional
+	VersionLabel string `json:"versionLabel,omitempty"`
+
+	// Retention is how long old versions are kept after a newer version appeared
+	// before they are deleted, provided no workload references them. Defaults to 24h.
+	// +optional
+	Retention *metav1.Duration `json:"retention,omitempty"`
+}
+
+// Rollback configures automatic rollback of secret changes that break their consumers.
+type Rollback struct {
+	// FailureThreshold is the percentage of restarted workloads that have to fail before
+	// the secret is rolled back. Defaults to 100, i.e. every restarted workload failed.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=100
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+
+	// Timeout is how long restarted workloads have to finish rolling out before they
+	// count as failed. Defaults to 10m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ActionType is the kind of an action.
+type ActionType string
+
+// Supported action types
+const (
+	// ActionTypeRunJob creates a Job from a template or an existing CronJob
+	ActionTypeRunJob ActionType = "RunJob"
+)
+
+// ActionOrder is when an action runs relative to the workload restarts.
+type ActionOrder string
+
+// Supported action orders
+const (
+	// ActionOrderBefore runs the action before the restarts, which wait for it to succeed
+	ActionOrderBefore ActionOrder = "Before"
+	// ActionOrderAfter runs the action once all workloads have been restarted
+	ActionOrderAfter ActionOrder = "After"
+)
+
+// Action is a side effect of a secret change.
+type Action struct {
+	// Name identifies the action within the SecretsRefresh and prefixes the names of
+	// the Jobs it creates
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=40
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Type of the action
+	// +kubebuilder:validation:Enum=RunJob
+	// +kubebuilder:default=RunJob
+	// +optional
+	Type ActionType `json:"type,omitempty"`
+
+	// Order is when the action runs relative to the restarts. Defaults to After.
+	// +kubebuilder:validation:Enum=Before;After
+	// +kubebuilder:default=After
+	// +optional
+	Order ActionOrder `json:"order,omitempty"`
+
+	// RunJob configures the Job of a RunJob action
+	// +optional
+	RunJob *RunJobAction `json:"runJob,omitempty"`
+}
+
+// RunJobAction creates a Job in the namespace of the changed secret, from a template
+// or from the job template of an existing CronJob. Exactly one must be set.
+type RunJobAction struct {
+	// JobTemplate is the Job to create. The template is validated by the API server
+	// when the Job is created.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	JobTemplate *batchv1.JobTemplateSpec `json:"jobTemplate,omitempty"`
+
+	// CronJobRef references a CronJob whose job template is instantiated
+	// +optional
+	CronJobRef *CronJobReference `json:"cronJobRef,omitempty"`
+}
+
+// CronJobReference identifies a CronJob.
+type CronJobReference struct {
+	// Name of the CronJob
+	Name string `json:"name"`
+
+	// Namespace of the CronJob. If empty, the namespace of a SecretsRefresh or,
+	// for a ClusterSecretsRefresh, the namespace of the changed secret
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GitOpsMode is how restarts are applied in GitOps mode.
+type GitOpsMode string
+
+// Supported GitOps modes
+const (
+	// GitOpsModeServerSideApply sets the restart annotations on the pod template with
+	// server-side apply under a field manager the GitOps tool is told to ignore
+	GitOpsModeServerSideApply GitOpsMode = "ServerSideApply"
+	// GitOpsModeRecyclePods evicts the pods one at a time without touching the pod template
+	GitOpsModeRecyclePods GitOpsMode = "RecyclePods"
+)
+
+// GitOps configures restarts of workloads managed by a GitOps tool.
+type GitOps struct {
+	// Mode is how restarts are applied. Defaults to ServerSideApply.
+	// +kubebuilder:validation:Enum=ServerSideApply;RecyclePods
+	// +optional
+	Mode GitOpsMode `json:"mode,omitempty"`
+
+	// FieldManager is the field manager restarts are applied with. Configure the GitOps
+	// tool to ignore fields owned by it. Defaults to traktor.
+	// +optional
+	FieldManager string `json:"fieldManager,omitempty"`
+
+	// Managers are the field managers of the GitOps tool. A restart annotation owned by
+	// one of them is reported as a conflict and the pods are recycled instead.
+	// Defaults to argocd-controller, kustomize-controller and helm-controller.
+	// +optional
+	Managers []string `json:"managers,omitempty"`
+}
+
+// RestartStrategyType is a way of restarting the pods of a workload.
+type RestartStrategyType string
+
+// Supported restart strategies
+const (
+	// RestartStrategyTemplateAnnotation patches the restartedAt annotation into the pod
+	// template, like kubectl rollout restart
+	RestartStrategyTemplateAnnotation RestartStrategyType = "TemplateAnnotation"
+	// RestartStrategyDeletePods evicts the pods one at a time, waiting for the workload
+	// to be available again in between. Evictions respect PodDisruptionBudgets.
+	RestartStrategyDeletePods RestartStrategyType = "DeletePods"
+	// RestartStrategyScaleBounce scales the workload to zero and back
+	RestartStrategyScaleBounce RestartStrategyType = "ScaleBounce"
+	// RestartStrategyServerSideApply sets the pod template annotations with server-side
+	// apply under Traktor's own field manager
+	RestartStrategyServerSideApply RestartStrategyType = "ServerSideApply"
+)
+
+// FlapDetection detects secrets that keep changing, e.g. because of a misbehaving syncer.
+type FlapDetection struct {
+	// MaxChanges is the number of changes within Window a secret may have before it is
+	// considered flapping
+	// +kubebuilder:validation:Minimum=1
+	MaxChanges int32 `json:"maxChanges"`
+
+	// Window is the period changes are counted in. A flapping secret is resumed once it
+	// has not changed for a whole window.
+	Window metav1.Duration `json:"window"`
+}
+
+// ChangedBy filters secret changes by their author, the field manager that last wrote
+// the data of the secret according to its managedFields. Entries may contain * wildcards,
+// e.g. kubectl-*.
+type ChangedBy struct {
+	// Allow lists the field managers whose changes restart workloads. When empty, every
+	// field manager that isn't denied is allowed.
+	// +listType=set
+	// +optional
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny lists field managers whose changes are never trusted, even if allowed
+	// +listType=set
+	// +optional
+	Deny []string `json:"deny,omitempty"`
+}
+
+// DisruptionPolicy decides how restarts that would cause downtime are handled.
+type DisruptionPolicy string
+
+// Supported disruption policies
+const (
+	DisruptionPolicyAllow            DisruptionPolicy = "Allow"
+	DisruptionPolicyWarn             DisruptionPolicy = "Warn"
+	DisruptionPolicySkip             DisruptionPolicy = "Skip"
+	DisruptionPolicySurgeTemporarily DisruptionPolicy = "SurgeTemporarily"
+)
+
+// MaintenanceWindow is a recurring period during which workloads may be restarted.
+type MaintenanceWindow struct {
+	// Schedule is a standard 5 field cron expression marking the start of the window,
+	// e.g. "0 22 * * 1-5" for 22:00 on weekdays.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Duration is how long the window stays open after each start, e.g. "2h".
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone is the IANA time zone the schedule is evaluated in. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// PendingRestart is a secret change whose restarts have been deferred.
+type PendingRestart struct {
+	// SecretName is the name of the changed secret
+	SecretName string `json:"secretName"`
+
+	// SecretNamespace is the namespace of the changed secret
+	SecretNamespace string `json:"secretNamespace"`
+
+	// DetectedAt is when the change was first deferred
+	DetectedAt metav1.Time `json:"detectedAt"`
+
+	// Reason is why the restarts are deferred, e.g. OutsideMaintenanceWindow or ChangeFreeze
+	Reason string `json:"reason"`
+
+	// NotBefore is the earliest time the restarts will be executed, if known
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+
+	// PlanID identifies a restart plan awaiting approval
+	// +optional
+	PlanID string `json:"planID,omitempty"`
+
+	// Workloads are the workloads the plan will restart once approved
+	// +optional
+	Workloads []WorkloadReference `json:"workloads,omitempty"`
+
+	// ExpiresAt is when an unapproved plan is dropped
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// WorkloadReference identifies a workload.
+type WorkloadReference struct {
+	// Kind of the workload, e.g. Deployment
+	Kind string `json:"kind"`
+
+	// Name of the workload
+	Name string `json:"name"`
+
+	// Namespace of the workload
+	Namespace string `json:"namespace"`
+}
+
+// SecretsRefreshReference identifies a SecretsRefresh or ClusterSecretsRefresh.
+type SecretsRefreshReference struct {
+	// Kind is SecretsRefresh or ClusterSecretsRefresh
+	Kind string `json:"kind"`
+
+	// Name of the SecretsRefresh
+	Name string `json:"name"`
+
+	// Namespace of the SecretsRefresh, empty for a ClusterSecretsRefresh
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ApprovalRecord is the outcome of a restart plan that required approval.
+type ApprovalRecord struct {
+	// PlanID identifies the restart plan
+	PlanID string `json:"planID"`
+
+	// SecretName is the name of the changed secret
+	SecretName string `json:"secretName"`
+
+	// SecretNamespace is the namespace of the changed secret
+	SecretNamespace string `json:"secretNamespace"`
+
+	// Decision is Approved, Rejected or Expired
+	Decision string `json:"decision"`
+
+	// By is the field manager that set the approve or reject annotation
+	// +optional
+	By string `json:"by,omitempty"`
+
+	// Time is when the decision was processed
+	Time metav1.Time `json:"time"`
+}
+
+// DryRunRestart is a restart that would have happened if dry run was disabled.
+type DryRunRestart struct {
+	// Kind of the workload, e.g. Deployment
+	Kind string `json:"kind"`
+
+	// Name of the workload
+	Name string `json:"name"`
+
+	// Namespace of the workload and the secret
+	Namespace string `json:"namespace"`
+
+	// SecretName is the name of the changed secret
+	SecretName string `json:"secretName"`
+
+	// References lists how the workload uses the secret, e.g. env, envFrom or volume
+	References []string `json:"references,omitempty"`
+
+	// Time is when the restart would have happened
+	Time metav1.Time `json:"time"`
+
+	// DeferredBy is the reason the restart would have been deferred, e.g. SecretFlapping or ChangeFreeze
+	// +optional
+	DeferredBy string `json:"deferredBy,omitempty"`
+
+	// NotBefore is when the deferred restart would have been retried, if known
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+}
+
+// ActionRun is the outcome of an action run for a secret change.
+type ActionRun struct {
+	// Action is the name of the action
+	Action string `json:"action"`
+
+	// Order is when the action ran relative to the restarts
+	Order ActionOrder `json:"order"`
+
+	// SecretName is the name of the changed secret
+	SecretName string `json:"secretName"`
+
+	// SecretNamespace is the namespace of the changed secret
+	SecretNamespace string `json:"secretNamespace"`
+
+	// JobName is the name of the Job created for the action
+	JobName string `json:"jobName"`
+
+	// JobNamespace is the namespace of the Job
+	JobNamespace string `json:"jobNamespace"`
+
+	// Result is Running, Succeeded or Failed
+	Result string `json:"result"`
+
+	// Message explains a failure
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// StartTime is when the Job was created
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is when the Job finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// RolloutCheck tracks the rollout of workloads restarted for a secret change that can
+// be rolled back.
+type RolloutCheck struct {
+	// SecretName is the name of the changed secret
+	SecretName string `json:"secretName"`
+
+	// SecretNamespace is the namespace of the changed secret
+	SecretNamespace string `json:"secretNamespace"`
+
+	// Version identifies the data of the secret the workloads were restarted for
+	Version string `json:"version"`
+
+	// SnapshotName is the name of the Secret holding the previous data
+	SnapshotName string `json:"snapshotName"`
+
+	// Workloads are the restarted workloads
+	Workloads []WorkloadReference `json:"workloads"`
+
+	// Deadline is when workloads that haven't finished rolling out count as failed
+	Deadline metav1.Time `json:"deadline"`
+}
+
+// RollbackRecord is a secret change that was rolled back.
+type RollbackRecord struct {
+	// SecretName is the name of the rolled back secret
+	SecretName string `json:"secretName"`
+
+	// SecretNamespace is the namespace of the rolled back secret
+	SecretNamespace string `json:"secretNamespace"`
+
+	// Version identifies the data that was rolled back
+	Version string `json:"version"`
+
+	// SnapshotName is the name of the Secret the previous data was restored from
+	SnapshotName string `json:"snapshotName"`
+
+	// FailedWorkloads are the workloads that failed to roll out
+	FailedWorkloads []WorkloadReference `json:"failedWorkloads"`
+
+	// Time is when the secret was rolled back
+	Time metav1.Time `json:"time"`
+}
+
+// Condition types of a SecretsRefresh.
+const (
+	// ConditionReady is True when the SecretsRefresh is valid and its last refresh succeeded
+	ConditionReady = "Ready"
+	// ConditionDegraded is True when the SecretsRefresh is invalid or its last refresh failed
+	ConditionDegraded = "Degraded"
+	// ConditionConflicting is True when a SecretsRefresh with a higher priority governs
+	// secrets this one selects as well
+	ConditionConflicting = "Conflicting"
+)
+
+// Results of refreshes.
+const (
+	RefreshResultSucceeded = "Succeeded"
+	RefreshResultFailed    = "Failed"
+	RefreshResultBlocked   = "Blocked"
+)
+
+// RefreshRecord is a secret change handled by Traktor.
+type RefreshRecord struct {
+	// SecretName is the name of the changed secret
+	SecretName string `json:"secretName"`
+
+	// SecretNamespace is the namespace of the changed secret
+	SecretNamespace string `json:"secretNamespace"`
+
+	// Version identifies the data of the secret
+	Version string `json:"version"`
+
+	// Workloads are the restarted workloads
+	// +optional
+	Workloads []WorkloadReference `json:"workloads,omitempty"`
+
+	// Result is Succeeded, Failed when a workload couldn't be restarted, or Blocked
+	// when a failed action prevented the restarts
+	Result string `json:"result"`
+
+	// Message explains a failed or blocked refresh
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// RefreshNow is the traktor.gdxcloud.net/refresh-now token that requested the
+	// refresh, empty for refreshes caused by a secret change
+	// +optional
+	RefreshNow string `json:"refreshNow,omitempty"`
+
+	// GovernedBy are the SecretsRefresh objects whose policies applied to the refresh
+	// +optional
+	GovernedBy []SecretsRefreshReference `json:"governedBy,omitempty"`
+
+	// Time is when the refresh happened
+	Time metav1.Time `json:"time"`
 }
 
 // SecretsRefreshStatus defines the observed state of SecretsRefresh.
 type SecretsRefreshStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
-	LastRefreshTime metav1.Time        `json:"lastRefreshTime"`
-	Conditions      []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+	// LastRefreshTime is when a matching secret was last refreshed
+	// +optional
+	LastRefreshTime *metav1.Time `json:"lastRefreshTime,omitempty"`
+
+	// Conditions are the Ready and Degraded conditions of the SecretsRefresh
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// ObservedGeneration is the generation of the spec the conditions and counts were computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// MatchedNamespaces is the number of namespaces selected by the namespace selector
+	// +optional
+	MatchedNamespaces int32 `json:"matchedNamespaces"`
+
+	// MatchedSecrets is the number of secrets selected in the matched namespaces
+	// +optional
+	MatchedSecrets int32 `json:"matchedSecrets"`
+
+	// MatchedWorkloads is the number of Deployments using a matched secret
+	// +optional
+	MatchedWorkloads int32 `json:"matchedWorkloads"`
+
+	// RefreshHistory lists the most recent refreshes, newest last
+	// +optional
+	RefreshHistory []RefreshRecord `json:"refreshHistory,omitempty"`
+
+	// LastRefreshNow is the last traktor.gdxcloud.net/refresh-now token set on the
+	// SecretsRefresh that has been handled
+	// +optional
+	LastRefreshNow string `json:"lastRefreshNow,omitempty"`
+
+	// PendingRestarts lists secret changes waiting for a maintenance window or the end of a change freeze
+	// +optional
+	PendingRestarts []PendingRestart `json:"pendingRestarts,omitempty"`
+
+	// DryRunRestarts lists the most recent restarts skipped because of dry run, newest last
+	// +optional
+	DryRunRestarts []DryRunRestart `json:"dryRunRestarts,omitempty"`
+
+	// ApprovalHistory lists the most recent approval decisions, newest last
+	// +optional
+	ApprovalHistory []ApprovalRecord `json:"approvalHistory,omitempty"`
+
+	// ActionRuns lists the most recent action runs, newest last
+	// +optional
+	ActionRuns []ActionRun `json:"actionRuns,omitempty"`
+
+	// RolloutChecks lists the secret changes whose restarted workloads are watched for rollback
+	// +optional
+	RolloutChecks []RolloutCheck `json:"rolloutChecks,omitempty"`
+
+	// RollbackHistory lists the most recent rollbacks, newest last
+	// +optional
+	RollbackHistory []RollbackRecord `json:"rollbackHistory,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Namespaces",type=integer,JSONPath=`.status.matchedNamespaces`
+// +kubebuilder:printcolumn:name="Secrets",type=integer,JSONPath=`.status.matchedSecrets`
+// +kubebuilder:printcolumn:name="Workloads",type=integer,JSONPath=`.status.matchedWorkloads`
+// +kubebuilder:printcolumn:name="Last Refresh",type=date,JSONPath=`.status.lastRefreshTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // SecretsRefresh is the Schema for the secretsrefreshes API.
 type SecretsRefresh struct {
@@ -0,0 +1,105 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+// Annotations understood by the operator
+const (
+	// RestartedAtAnnotation is set on the pod template of restarted workloads
+	RestartedAtAnnotation = "traktor.gdxcloud.net/restartedAt"
+
+	// SecretVersionsAnnotation is set on the pod template of handled workloads and maps
+	// each changed secret to the version of its data the pods were started with
+	SecretVersionsAnnotation = "traktor.gdxcloud.net/secretVersions"
+
+	// FreezeAnnotation set to "true" on a namespace defers all restarts in that namespace.
+	// Set on the operator's own namespace it freezes the whole cluster.
+	FreezeAnnotation = "traktor.gdxcloud.net/freeze"
+
+	// AllowSecretsRefreshFromAnnotation on a namespace grants namespaced SecretsRefresh
+	// objects of other namespaces access to it. It holds a comma separated list of
+	// namespaces, granting all SecretsRefresh objects in them, or namespace/name entries
+	// granting a single one, e.g. "team-a, team-b/app-refresh".
+	AllowSecretsRefreshFromAnnotation = "traktor.gdxcloud.net/allow-secretsrefresh-from"
+
+	// UrgentAnnotation set to "true" on a Secret bypasses maintenance windows and freezes
+	UrgentAnnotation = "traktor.gdxcloud.net/urgent"
+
+	// RefreshNowAnnotation set to a token on a SecretsRefresh or a Secret restarts all
+	// current consumers of the selected secrets, or of the Secret, once for that token
+	RefreshNowAnnotation = "traktor.gdxcloud.net/refresh-now"
+
+	// RefreshedNowAnnotation on a Deployment holds the last refresh-now token it was
+	// restarted for, so it is restarted only once per token
+	RefreshedNowAnnotation = "traktor.gdxcloud.net/refreshed-now"
+
+	// ApproveAnnotation set to a plan ID on a SecretsRefresh executes that restart plan
+	ApproveAnnotation = "traktor.gdxcloud.net/approve"
+
+	// RejectAnnotation set to a plan ID on a SecretsRefresh drops that restart plan
+	RejectAnnotation = "traktor.gdxcloud.net/reject"
+
+	// OriginalStrategyAnnotation holds the JSON encoded strategy of a Deployment while
+	// it is temporarily switched to a surging rolling update
+	OriginalStrategyAnnotation = "traktor.gdxcloud.net/original-strategy"
+
+	// RestartStrategyAnnotation on a workload overrides spec.rollout.strategy for it
+	RestartStrategyAnnotation = "traktor.gdxcloud.net/restart-strategy"
+
+	// RecycleBeforeAnnotation on a Deployment makes Traktor evict its pods created
+	// before the given RFC3339 time, one at a time
+	RecycleBeforeAnnotation = "traktor.gdxcloud.net/recycle-before"
+
+	// ScaleBounceReplicasAnnotation on a Deployment holds the replica count to scale
+	// back to once it has been scaled to zero for a restart
+	ScaleBounceReplicasAnnotation = "traktor.gdxcloud.net/scale-bounce-replicas"
+
+	// HotReloadAnnotation set to "true" on a workload declares that it picks up changes
+	// of mounted secret files itself, so it is only restarted for env, envFrom and
+	// subPath references
+	HotReloadAnnotation = "traktor.gdxcloud.net/hot-reload"
+
+	// ReloadURLAnnotation on a workload makes Traktor POST to this URL on every ready pod
+	// instead of restarting it when a mounted secret changes. The host of the URL is
+	// replaced by the pod IP, e.g. "http://:8080/-/reload".
+	ReloadURLAnnotation = "traktor.gdxcloud.net/reload-url"
+
+	// ReloadAfterAnnotation on a Deployment makes Traktor call its reload URL once the
+	// given RFC3339 time has passed and the kubelet has synced the mounted secret
+	ReloadAfterAnnotation = "traktor.gdxcloud.net/reload-after"
+
+	// ActionSecretAnnotation on a Job created by an action holds the namespace/name of
+	// the changed secret it was created for
+	ActionSecretAnnotation = "traktor.gdxcloud.net/secret"
+
+	// ActionSecretsRefreshAnnotation on a Job created by an action holds the
+	// namespace/name of the SecretsRefresh the action belongs to
+	ActionSecretsRefreshAnnotation = "traktor.gdxcloud.net/secretsrefresh"
+
+	// SnapshotOfAnnotation on a snapshot Secret names the secret whose previous data it holds
+	SnapshotOfAnnotation = "traktor.gdxcloud.net/snapshot-of"
+
+	// SnapshotForVersionAnnotation on a snapshot Secret holds the version of the change
+	// the snapshot was taken for
+	SnapshotForVersionAnnotation = "traktor.gdxcloud.net/snapshot-for-version"
+
+	// RolledBackToAnnotation on a Secret holds the version Traktor restored, so the
+	// rollback itself is not tracked for another rollback
+	RolledBackToAnnotation = "traktor.gdxcloud.net/rolled-back-to"
+)
+
+// ActionLabel on a Job names the SecretsRefresh action that created it
+const ActionLabel = "traktor.gdxcloud.net/action"
@@ -0,0 +1,45 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import "time"
+
+// Defaults of unset SecretsRefresh fields. The defaulting webhook fills them in and the
+// controller falls back to them when the webhook is disabled.
+const (
+	// DefaultApprovalTTL is how long a restart plan waits for approval
+	DefaultApprovalTTL = 24 * time.Hour
+
+	// DefaultRollbackThreshold is the percentage of restarted workloads that must fail
+	// before a secret is rolled back
+	DefaultRollbackThreshold = 100
+
+	// DefaultRollbackTimeout is how long restarted workloads have to roll out
+	DefaultRollbackTimeout = 10 * time.Minute
+
+	// DefaultSeriesLabel is the label shared by all versions of a versioned secret
+	DefaultSeriesLabel = "traktor.gdxcloud.net/series"
+
+	// DefaultVersionRetention is how long old versions of a secret are kept
+	DefaultVersionRetention = 24 * time.Hour
+
+	// DefaultFieldManager is the field manager Traktor applies restarts with
+	DefaultFieldManager = "traktor"
+)
+
+// DefaultGitOpsManagers are the field managers of Argo CD and Flux
+var DefaultGitOpsManagers = []string{"argocd-controller", "kustomize-controller", "helm-controller"}
@@ -0,0 +1,159 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TraktorConfigName is the name of the only TraktorConfig the operator reads
+const TraktorConfigName = "default"
+
+// TraktorConfigSpec defines the operator-wide configuration
+type TraktorConfigSpec struct {
+	// Protected are workloads that are never restarted, whatever SecretsRefresh objects
+	// select them. The namespace the operator runs in is always protected.
+	// +optional
+	Protected Protection `json:"protected,omitempty"`
+
+	// DefaultRestartStrategy is the restart strategy of SecretsRefresh objects that
+	// don't set one. Defaults to TemplateAnnotation.
+	// +kubebuilder:validation:Enum=TemplateAnnotation;DeletePods;ScaleBounce;ServerSideApply
+	// +optional
+	DefaultRestartStrategy RestartStrategyType `json:"defaultRestartStrategy,omitempty"`
+
+	// RateLimits bound how fast the operator restarts workloads across all SecretsRefresh objects
+	// +optional
+	RateLimits RateLimits `json:"rateLimits,omitempty"`
+
+	// Notifiers receive every refresh of every SecretsRefresh, in addition to the
+	// notification webhooks of the SecretsRefresh itself
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Notifiers []NotificationWebhook `json:"notifiers,omitempty"`
+
+	// WorkloadKinds are the kinds of workloads that are restarted. When empty, all
+	// supported kinds are. Only Deployment is supported for now.
+	// +kubebuilder:validation:items:Enum=Deployment
+	// +listType=set
+	// +optional
+	WorkloadKinds []string `json:"workloadKinds,omitempty"`
+
+	// Features switches optional features on or off for the whole cluster
+	// +optional
+	Features Features `json:"features,omitempty"`
+}
+
+// Protection lists the workloads that are never restarted.
+type Protection struct {
+	// Namespaces whose workloads are protected, e.g. kube-system or the CNI namespace
+	// +listType=set
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Workloads are individual protected workloads
+	// +optional
+	Workloads []WorkloadReference `json:"workloads,omitempty"`
+
+	// Selector protects the workloads whose labels match
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// RateLimits bound the restarts of the operator.
+type RateLimits struct {
+	// MaxRestartsPerMinute is how many workloads may be restarted within a minute. Further
+	// restarts are deferred until the budget allows them. Unlimited when unset.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxRestartsPerMinute int32 `json:"maxRestartsPerMinute,omitempty"`
+}
+
+// Features switches optional features on or off.
+type Features struct {
+	// DryRun reports restarts of all SecretsRefresh objects instead of performing them,
+	// like the --dry-run flag
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Actions runs the actions of SecretsRefresh objects. Defaults to true.
+	// +optional
+	Actions *bool `json:"actions,omitempty"`
+
+	// Rollback snapshots secrets and rolls back changes whose workloads fail. Defaults to true.
+	// +optional
+	Rollback *bool `json:"rollback,omitempty"`
+
+	// ReloadEndpoints calls the reload endpoints of workloads instead of restarting
+	// them. Defaults to true.
+	// +optional
+	ReloadEndpoints *bool `json:"reloadEndpoints,omitempty"`
+
+	// NamespacedJobTemplates lets namespaced SecretsRefresh objects run actions from an
+	// inline jobTemplate. The Job runs with whatever service account, volumes and
+	// privileges the template asks for, so by default only ClusterSecretsRefresh
+	// objects may use job templates and namespaced ones must reference a CronJob in
+	// their own namespace.
+	// +optional
+	NamespacedJobTemplates bool `json:"namespacedJobTemplates,omitempty"`
+}
+
+// TraktorConfigStatus defines the observed state of TraktorConfig.
+type TraktorConfigStatus struct {
+	// ObservedGeneration is the generation of the spec the status was computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report whether the configuration is valid and applied. An invalid
+	// configuration isn't applied and is Degraded, the operator keeps the last valid
+	// one, or the defaults when it started with an invalid configuration.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:validation:XValidation:rule="self.metadata.name == 'default'",message="the TraktorConfig must be named default"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// TraktorConfig is the operator-wide configuration. It is a singleton named default,
+// changes are applied without restarting the operator.
+type TraktorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TraktorConfigSpec   `json:"spec,omitempty"`
+	Status TraktorConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TraktorConfigList contains a list of TraktorConfig.
+type TraktorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TraktorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TraktorConfig{}, &TraktorConfigList{})
+}
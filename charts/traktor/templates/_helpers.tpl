@@ -84,6 +84,20 @@ Metrics service name
 {{- printf "%s-metrics-service" (include "traktor.fullname" .) }}
 {{- end }}
 
+{{/*
+Webhook service name
+*/}}
+{{- define "traktor.webhookServiceName" -}}
+{{- printf "%s-webhook-service" (include "traktor.fullname" .) }}
+{{- end }}
+
+{{/*
+Webhook serving certificate secret name
+*/}}
+{{- define "traktor.webhookCertSecretName" -}}
+{{- printf "%s-webhook-server-cert" (include "traktor.fullname" .) }}
+{{- end }}
+
 {{/*
 Controller manager name
 */}}
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
@@ -30,6 +31,7 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -42,7 +44,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	appsv1alpha1 "github.com/GDXbsv/traktor/api/v1alpha1"
+	appsv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
 	"github.com/GDXbsv/traktor/internal/controller"
+	webhookv1beta1 "github.com/GDXbsv/traktor/internal/webhook/v1beta1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -53,8 +57,10 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 
 	utilruntime.Must(appsv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(appsv1beta1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -142,6 +148,7 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var dryRun bool
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -160,6 +167,8 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"If set, workloads are never restarted. Restarts that would happen are reported as events and in status.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -281,14 +290,102 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The TraktorConfig is loaded before any controller starts, so protections and
+	// rate limits hold from the first reconciliation, and applied as it changes. An
+	// invalid one doesn't stop the operator, the defaults apply until it is fixed.
+	operatorConfig, err := controller.LoadOperatorConfig(context.Background(), mgr.GetAPIReader())
+	if err != nil {
+		setupLog.Error(err, "unable to load TraktorConfig")
+		os.Exit(1)
+	}
+	if err := (&controller.TraktorConfigReconciler{
+		Client: mgr.GetClient(),
+		Config: operatorConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TraktorConfig")
+		os.Exit(1)
+	}
+	notifier := &controller.Notifier{Recorder: mgr.GetEventRecorderFor("secretsrefresh-controller")}
+	if err := mgr.Add(notifier); err != nil {
+		setupLog.Error(err, "unable to add notifier to manager")
+		os.Exit(1)
+	}
 	if err := (&controller.SecretsRefreshReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("secretsrefresh-controller"),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("secretsrefresh-controller"),
+		DryRun:    dryRun,
+		Config:    operatorConfig,
+		APIReader: mgr.GetAPIReader(),
+		Notifier:  notifier,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SecretsRefresh")
 		os.Exit(1)
 	}
+	if err := (&controller.SecretsRefreshStatusReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SecretsRefreshStatus")
+		os.Exit(1)
+	}
+	if err := (&controller.StrategyRestoreReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "StrategyRestore")
+		os.Exit(1)
+	}
+	if err := (&controller.PodRecycleReconciler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PodRecycle")
+		os.Exit(1)
+	}
+	if err := (&controller.RestartDurationReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RestartDuration")
+		os.Exit(1)
+	}
+	if err := (&controller.ReloadReconciler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Recorder:  mgr.GetEventRecorderFor("reload-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Reload")
+		os.Exit(1)
+	}
+	if err := (&controller.RollbackReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor("rollback-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Rollback")
+		os.Exit(1)
+	}
+	// The webhooks of the v1beta1 hub types also serve /convert, which converts v1alpha1 objects
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhookv1beta1.SetupSecretsRefreshWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "SecretsRefresh")
+			os.Exit(1)
+		}
+		if err := webhookv1beta1.SetupClusterSecretsRefreshWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterSecretsRefresh")
+			os.Exit(1)
+		}
+		if err := webhookv1beta1.SetupTraktorConfigWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "TraktorConfig")
+			os.Exit(1)
+		}
+	}
+	// Store every object as v1beta1, so v1alpha1 can eventually be removed from the CRDs
+	if err := mgr.Add(&controller.StorageVersionMigrator{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+	}); err != nil {
+		setupLog.Error(err, "unable to add storage version migration to manager")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -0,0 +1,323 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// Results of action runs
+const (
+	ActionResultRunning   = "Running"
+	ActionResultSucceeded = "Succeeded"
+	ActionResultFailed    = "Failed"
+)
+
+// actionRecheckInterval is how often running actions ordered before the restarts are checked
+const actionRecheckInterval = 10 * time.Second
+
+// maxActionRuns bounds status.actionRuns
+const maxActionRuns = 20
+
+// actionsResult is the outcome of the actions of one order for a secret change
+type actionsResult struct {
+	// running is true while at least one action has not finished
+	running bool
+	// failed lists the actions that failed
+	failed []string
+}
+
+// actionOrder returns when the action runs, After by default
+func actionOrder(action *traktorv1beta1.Action) traktorv1beta1.ActionOrder {
+	if action.Order == "" {
+		return traktorv1beta1.ActionOrderAfter
+	}
+	return action.Order
+}
+
+// actionJobName derives a stable Job name from the action and the secret version,
+// so every change runs each action exactly once
+func actionJobName(sr *traktorv1beta1.SecretsRefresh, action *traktorv1beta1.Action, secret *corev1.Secret) string {
+	sum := sha256.Sum256([]byte(sr.Namespace + "/" + sr.Name + "/" + secret.Namespace + "/" + secret.Name + "/" + secretVersion(secret)))
+	return action.Name + "-" + hex.EncodeToString(sum[:])[:10]
+}
+
+// finishedActionRun returns the recorded run of the Job if it already finished
+func finishedActionRun(sr *traktorv1beta1.SecretsRefresh, namespace, name string) *traktorv1beta1.ActionRun {
+	for i := range sr.Status.ActionRuns {
+		run := &sr.Status.ActionRuns[i]
+		if run.JobName == name && run.JobNamespace == namespace && run.Result != ActionResultRunning {
+			return run
+		}
+	}
+	return nil
+}
+
+// runActions starts the actions of the given order of all matching SecretsRefresh
+// objects for the secret change and collects their results. Jobs are only created
+// once per change; later calls update the recorded results.
+func (r *SecretsRefreshReconciler) runActions(ctx context.Context, policies []restartPolicy, secret *corev1.Secret, order traktorv1beta1.ActionOrder) (*actionsResult, error) {
+	result := &actionsResult{}
+	if !r.Config.actionsEnabled() {
+		return result, nil
+	}
+	for _, p := range policies {
+		sr := p.secretsRefresh
+		for i := range sr.Spec.Actions {
+			action := &sr.Spec.Actions[i]
+			if actionOrder(action) != order {
+				continue
+			}
+
+			run, err := r.runJobAction(ctx, sr, action, secret)
+			if err != nil {
+				return nil, err
+			}
+			switch run.Result {
+			case ActionResultRunning:
+				result.running = true
+			case ActionResultFailed:
+				result.failed = append(result.failed, sr.Name+"/"+action.Name)
+			}
+		}
+	}
+	return result, nil
+}
+
+// runJobAction creates the Job of a RunJob action if needed and records its result
+func (r *SecretsRefreshReconciler) runJobAction(ctx context.Context, sr *traktorv1beta1.SecretsRefresh, action *traktorv1beta1.Action, secret *corev1.Secret) (traktorv1beta1.ActionRun, error) {
+	run := traktorv1beta1.ActionRun{
+		Action:          action.Name,
+		Order:           actionOrder(action),
+		SecretName:      secret.Name,
+		SecretNamespace: secret.Namespace,
+		JobName:         actionJobName(sr, action, secret),
+		JobNamespace:    secret.Namespace,
+	}
+	if action.RunJob != nil && action.RunJob.CronJobRef != nil {
+		run.JobNamespace = cronJobNamespace(sr, action.RunJob.CronJobRef, secret)
+	}
+
+	// Finished Jobs may have been cleaned up by their TTL, don't run them again
+	if finished := finishedActionRun(sr, run.JobNamespace, run.JobName); finished != nil {
+		return *finished, nil
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: run.JobNamespace, Name: run.JobName}, job)
+	switch {
+	case apierrors.IsNotFound(err):
+		job, err = r.createActionJob(ctx, sr, action, secret, run.JobNamespace, run.JobName)
+		if err != nil {
+			if !isActionConfigError(err) {
+				return run, err
+			}
+			run.Result = ActionResultFailed
+			run.Message = err.Error()
+			run.StartTime = metav1.Now()
+			return run, r.recordActionRun(ctx, sr, run)
+		}
+	case err != nil:
+		return run, err
+	}
+
+	run.StartTime = job.CreationTimestamp
+	run.Result, run.Message, run.CompletionTime = jobResult(job)
+	return run, r.recordActionRun(ctx, sr, run)
+}
+
+// actionConfigError is an action that can't be run as configured
+type actionConfigError struct {
+	error
+}
+
+func isActionConfigError(err error) bool {
+	var configErr *actionConfigError
+	return errors.As(err, &configErr)
+}
+
+// actionNotPermitted returns why the SecretsRefresh may not run the action, empty if
+// it may. The operator creates the Jobs with its own permissions, so a namespaced
+// SecretsRefresh may only instantiate CronJobs of its own namespace, and inline job
+// templates only when the TraktorConfig allows them.
+func (r *SecretsRefreshReconciler) actionNotPermitted(sr *traktorv1beta1.SecretsRefresh, action *traktorv1beta1.Action) string {
+	if isClusterSecretsRefresh(sr) || action.RunJob == nil {
+		return ""
+	}
+	if ref := action.RunJob.CronJobRef; ref != nil && cronJobNamespace(sr, ref, nil) != sr.Namespace {
+		return fmt.Sprintf("CronJob namespace %s is not the namespace of the SecretsRefresh", ref.Namespace)
+	}
+	if action.RunJob.JobTemplate != nil && !r.Config.namespacedJobTemplatesEnabled() {
+		return "jobTemplate requires a ClusterSecretsRefresh or the TraktorConfig feature namespacedJobTemplates"
+	}
+	return ""
+}
+
+// cronJobNamespace resolves the namespace of a referenced CronJob. An empty namespace
+// means the namespace of a namespaced SecretsRefresh, which may differ from the namespace
+// of the secret, and the namespace of the secret for a ClusterSecretsRefresh.
+func cronJobNamespace(sr *traktorv1beta1.SecretsRefresh, ref *traktorv1beta1.CronJobReference, secret *corev1.Secret) string {
+	switch {
+	case ref.Namespace != "":
+		return ref.Namespace
+	case !isClusterSecretsRefresh(sr):
+		return sr.Namespace
+	case secret != nil:
+		return secret.Namespace
+	}
+	return ""
+}
+
+// createActionJob creates the Job of a RunJob action from its template or CronJob
+func (r *SecretsRefreshReconciler) createActionJob(ctx context.Context, sr *traktorv1beta1.SecretsRefresh, action *traktorv1beta1.Action, secret *corev1.Secret, namespace, name string) (*batchv1.Job, error) {
+	runJob := action.RunJob
+	if runJob == nil || (runJob.JobTemplate == nil) == (runJob.CronJobRef == nil) {
+		return nil, &actionConfigError{fmt.Errorf("action %s must set exactly one of runJob.jobTemplate and runJob.cronJobRef", action.Name)}
+	}
+	// The webhook rejects these as well, but it may be disabled
+	if reason := r.actionNotPermitted(sr, action); reason != "" {
+		return nil, &actionConfigError{fmt.Errorf("action %s is not permitted: %s", action.Name, reason)}
+	}
+
+	job := &batchv1.Job{}
+	job.Name = name
+	job.Namespace = namespace
+	if runJob.JobTemplate != nil {
+		template := runJob.JobTemplate.DeepCopy()
+		job.Labels = template.Labels
+		job.Annotations = template.Annotations
+		job.Spec = template.Spec
+	} else {
+		cronJob := &batchv1.CronJob{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: runJob.CronJobRef.Name}, cronJob); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, &actionConfigError{fmt.Errorf("CronJob %s/%s of action %s not found", namespace, runJob.CronJobRef.Name, action.Name)}
+			}
+			return nil, err
+		}
+		template := cronJob.Spec.JobTemplate.DeepCopy()
+		job.Labels = template.Labels
+		job.Annotations = template.Annotations
+		job.Spec = template.Spec
+		if job.Annotations == nil {
+			job.Annotations = map[string]string{}
+		}
+		// Same as kubectl create job --from=cronjob
+		job.Annotations["cronjob.kubernetes.io/instantiate"] = "manual"
+		if err := controllerutil.SetOwnerReference(cronJob, job, r.Scheme); err != nil {
+			return nil, err
+		}
+	}
+
+	if job.Labels == nil {
+		job.Labels = map[string]string{}
+	}
+	job.Labels[traktorv1beta1.ActionLabel] = action.Name
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[traktorv1beta1.ActionSecretAnnotation] = secret.Namespace + "/" + secret.Name
+	job.Annotations[traktorv1beta1.ActionSecretsRefreshAnnotation] = sr.Namespace + "/" + sr.Name
+
+	if err := r.Create(ctx, job); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return job, r.Get(ctx, client.ObjectKeyFromObject(job), job)
+		}
+		if apierrors.IsInvalid(err) {
+			return nil, &actionConfigError{fmt.Errorf("invalid Job of action %s: %w", action.Name, err)}
+		}
+		return nil, err
+	}
+
+	r.recordEvent(sr, corev1.EventTypeNormal, "ActionStarted",
+		fmt.Sprintf("Started Job %s/%s of action %s for Secret %s/%s", namespace, name, action.Name, secret.Namespace, secret.Name))
+	return job, nil
+}
+
+// jobResult returns the result of a Job from its conditions
+func jobResult(job *batchv1.Job) (string, string, *metav1.Time) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			completed := condition.LastTransitionTime
+			if job.Status.CompletionTime != nil {
+				completed = *job.Status.CompletionTime
+			}
+			return ActionResultSucceeded, "", &completed
+		case batchv1.JobFailed:
+			completed := condition.LastTransitionTime
+			message := condition.Reason
+			if condition.Message != "" {
+				message += ": " + condition.Message
+			}
+			return ActionResultFailed, message, &completed
+		}
+	}
+	return ActionResultRunning, "", nil
+}
+
+// recordActionRun records the run in the status of the SecretsRefresh and reports
+// finished runs in an event
+func (r *SecretsRefreshReconciler) recordActionRun(ctx context.Context, sr *traktorv1beta1.SecretsRefresh, run traktorv1beta1.ActionRun) error {
+	changed := false
+	err := r.updateStatus(ctx, sr, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+		for i := range status.ActionRuns {
+			existing := &status.ActionRuns[i]
+			if existing.JobName != run.JobName || existing.JobNamespace != run.JobNamespace {
+				continue
+			}
+			if equality.Semantic.DeepEqual(*existing, run) {
+				return false
+			}
+			*existing = run
+			changed = true
+			return true
+		}
+		status.ActionRuns = append(status.ActionRuns, run)
+		if overflow := len(status.ActionRuns) - maxActionRuns; overflow > 0 {
+			status.ActionRuns = status.ActionRuns[overflow:]
+		}
+		changed = true
+		return true
+	})
+	if err != nil || !changed {
+		return err
+	}
+
+	switch run.Result {
+	case ActionResultSucceeded:
+		r.recordEvent(sr, corev1.EventTypeNormal, "ActionSucceeded",
+			fmt.Sprintf("Job %s/%s of action %s succeeded", run.JobNamespace, run.JobName, run.Action))
+	case ActionResultFailed:
+		r.recordEvent(sr, corev1.EventTypeWarning, "ActionFailed",
+			fmt.Sprintf("Job %s/%s of action %s failed: %s", run.JobNamespace, run.JobName, run.Action, run.Message))
+	}
+	return nil
+}
+
+// secretForActionJob maps a Job created by an action back to its secret, so the
+// secret change is reconciled again when the Job finishes
+func secretForActionJob(_ context.Context, job *batchv1.Job) []SecretRequest {
+	namespace, name, ok := strings.Cut(job.Annotations[traktorv1beta1.ActionSecretAnnotation], "/")
+	if !ok {
+		return nil
+	}
+	return []SecretRequest{{NamespacedName: client.ObjectKey{Namespace: namespace, Name: name}}}
+}
@@ -0,0 +1,347 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// Approval reasons and decisions
+const (
+	ReasonAwaitingApproval = "AwaitingApproval"
+
+	DecisionApproved = "Approved"
+	DecisionRejected = "Rejected"
+	DecisionExpired  = "Expired"
+)
+
+// maxApprovalHistory bounds status.approvalHistory
+const maxApprovalHistory = 20
+
+// approvalResult is the outcome of the approval gate for a secret change
+type approvalResult struct {
+	// waiting is true while the plan has neither been approved, rejected nor expired
+	waiting bool
+	planID  string
+	// requeueAfter is when the plan expires
+	requeueAfter time.Duration
+	// gated is true when the change required approval at all
+	gated bool
+	// workloads are the approved workloads, empty when the plan was rejected or expired
+	workloads map[types.NamespacedName]bool
+	// refused is true when the plan was rejected or expired
+	refused bool
+}
+
+// filter returns the targets the approval allows to restart
+func (a *approvalResult) filter(targets []*appsv1.Deployment) []*appsv1.Deployment {
+	if !a.gated {
+		return targets
+	}
+	allowed := make([]*appsv1.Deployment, 0, len(targets))
+	for _, deployment := range targets {
+		if a.workloads[client.ObjectKeyFromObject(deployment)] {
+			allowed = append(allowed, deployment)
+		}
+	}
+	return allowed
+}
+
+// approvalTTL returns the plan lifetime of a SecretsRefresh
+func approvalTTL(sr *traktorv1beta1.SecretsRefresh) time.Duration {
+	if sr.Spec.Rollout.Approval.TTL != nil && sr.Spec.Rollout.Approval.TTL.Duration > 0 {
+		return sr.Spec.Rollout.Approval.TTL.Duration
+	}
+	return traktorv1beta1.DefaultApprovalTTL
+}
+
+// restartPlanID derives a stable plan ID from the data of the secret, so the same
+// change always maps to the same plan, metadata updates keep it and a newer change
+// replaces it
+func restartPlanID(secret *corev1.Secret) string {
+	sum := sha256.Sum256([]byte(secret.Namespace + "/" + secret.Name + "/" + secretVersion(secret)))
+	return secret.Name + "-" + hex.EncodeToString(sum[:])[:8]
+}
+
+// pendingPlan returns the pending restart of the secret recorded in the SecretsRefresh, if any
+func pendingPlan(sr *traktorv1beta1.SecretsRefresh, secret *corev1.Secret) *traktorv1beta1.PendingRestart {
+	for i := range sr.Status.PendingRestarts {
+		pending := &sr.Status.PendingRestarts[i]
+		if pending.SecretName == secret.Name && pending.SecretNamespace == secret.Namespace && pending.PlanID != "" {
+			return pending
+		}
+	}
+	return nil
+}
+
+// planDecision returns how the plan has already been decided, empty if it hasn't. An
+// approval only counts while the approve annotation still names the plan, rejected
+// and expired plans stay decided so they aren't proposed again.
+func planDecision(approvers []*traktorv1beta1.SecretsRefresh, planID string) string {
+	decision := ""
+	for _, sr := range approvers {
+		for _, record := range sr.Status.ApprovalHistory {
+			if record.PlanID != planID {
+				continue
+			}
+			switch record.Decision {
+			case DecisionApproved:
+				if sr.Annotations[traktorv1beta1.ApproveAnnotation] == planID {
+					return DecisionApproved
+				}
+			case DecisionRejected, DecisionExpired:
+				decision = record.Decision
+			}
+		}
+	}
+	return decision
+}
+
+// annotationManager returns the field manager that last wrote the annotation, taken
+// from managedFields so the approver is recorded without trusting user input
+func annotationManager(obj metav1.Object, annotation string) string {
+	field := []byte(`"f:` + annotation + `"`)
+	manager := ""
+	var latest time.Time
+	for _, entry := range obj.GetManagedFields() {
+		if entry.FieldsV1 == nil || !bytes.Contains(entry.FieldsV1.Raw, field) {
+			continue
+		}
+		var at time.Time
+		if entry.Time != nil {
+			at = entry.Time.Time
+		}
+		if manager == "" || !at.Before(latest) {
+			manager = entry.Manager
+			latest = at
+		}
+	}
+	return manager
+}
+
+// dataManager returns the field manager that last wrote the data of the secret, empty
+// if its managedFields don't tell. stringData is written to data, so it counts as well.
+func dataManager(secret *corev1.Secret) string {
+	manager := ""
+	var latest time.Time
+	for _, entry := range secret.ManagedFields {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		_, data := fields["f:data"]
+		_, stringData := fields["f:stringData"]
+		if !data && !stringData {
+			continue
+		}
+		var at time.Time
+		if entry.Time != nil {
+			at = entry.Time.Time
+		}
+		if manager == "" || !at.Before(latest) {
+			manager = entry.Manager
+			latest = at
+		}
+	}
+	return manager
+}
+
+// changeAuthorAllowed reports whether spec.triggers.changedBy trusts changes by the
+// field manager. Without changedBy every author is.
+func changeAuthorAllowed(changedBy *traktorv1beta1.ChangedBy, manager string) bool {
+	if changedBy == nil {
+		return true
+	}
+	if managerMatches(changedBy.Deny, manager) {
+		return false
+	}
+	return len(changedBy.Allow) == 0 || managerMatches(changedBy.Allow, manager)
+}
+
+// managerMatches reports whether the field manager matches one of the patterns
+func managerMatches(patterns []string, manager string) bool {
+	for _, pattern := range patterns {
+		// Invalid patterns are rejected by the webhook and match nothing
+		if ok, err := path.Match(pattern, manager); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkApproval holds restarts of SecretsRefresh objects with spec.rollout.approval.required,
+// or whose spec.triggers.changedBy doesn't trust the author of the change, until the
+// prepared plan is approved, rejected or expired. Approval on any of the matching
+// SecretsRefresh objects executes the plan.
+func (r *SecretsRefreshReconciler) checkApproval(ctx context.Context, policies []restartPolicy, secret *corev1.Secret, targets []*appsv1.Deployment, now time.Time) (*approvalResult, error) {
+	author := dataManager(secret)
+	var approvers []*traktorv1beta1.SecretsRefresh
+	for _, p := range policies {
+		if p.secretsRefresh.Spec.Rollout.Approval.Required ||
+			!changeAuthorAllowed(p.secretsRefresh.Spec.Triggers.ChangedBy, author) {
+			approvers = append(approvers, p.secretsRefresh)
+		}
+	}
+	if len(approvers) == 0 {
+		return &approvalResult{}, nil
+	}
+
+	planID := restartPlanID(secret)
+	result := &approvalResult{gated: true, planID: planID, workloads: map[types.NamespacedName]bool{}}
+
+	// Look for an existing plan for this version of the secret
+	var plan *traktorv1beta1.PendingRestart
+	for _, sr := range approvers {
+		if pending := pendingPlan(sr, secret); pending != nil && pending.PlanID == planID {
+			plan = pending
+			break
+		}
+	}
+
+	if plan == nil {
+		if len(targets) == 0 {
+			// Nothing to approve
+			return result, nil
+		}
+		switch planDecision(approvers, planID) {
+		case DecisionApproved:
+			// Workloads deferred while an approved plan was executed don't need another approval
+			result.gated = false
+			return result, nil
+		case DecisionRejected, DecisionExpired:
+			// Nothing is restarted for this change until the secret changes again
+			result.refused = true
+			return result, nil
+		}
+		return result, r.proposePlan(ctx, approvers, secret, author, targets, planID, now, result)
+	}
+
+	decision, by, decidedOn := "", "", approvers[0]
+	for _, sr := range approvers {
+		switch planID {
+		case sr.Annotations[traktorv1beta1.ApproveAnnotation]:
+			decision, by, decidedOn = DecisionApproved, annotationManager(sr, traktorv1beta1.ApproveAnnotation), sr
+		case sr.Annotations[traktorv1beta1.RejectAnnotation]:
+			if decision == "" {
+				decision, by, decidedOn = DecisionRejected, annotationManager(sr, traktorv1beta1.RejectAnnotation), sr
+			}
+		}
+		if decision == DecisionApproved {
+			break
+		}
+	}
+	if decision == "" && plan.ExpiresAt != nil && !now.Before(plan.ExpiresAt.Time) {
+		decision = DecisionExpired
+	}
+
+	if decision == "" {
+		result.waiting = true
+		if plan.ExpiresAt != nil {
+			result.requeueAfter = plan.ExpiresAt.Sub(now)
+		}
+		return result, nil
+	}
+
+	if decision == DecisionApproved {
+		for _, workload := range plan.Workloads {
+			result.workloads[types.NamespacedName{Namespace: workload.Namespace, Name: workload.Name}] = true
+		}
+	} else {
+		result.refused = true
+	}
+
+	eventType := corev1.EventTypeNormal
+	if decision != DecisionApproved {
+		eventType = corev1.EventTypeWarning
+	}
+	message := fmt.Sprintf("Restart plan %s for secret %s/%s %s", planID, secret.Namespace, secret.Name, decision)
+	if by != "" {
+		message += " by " + by
+	}
+	r.recordEvent(decidedOn, eventType, "Plan"+decision, message)
+
+	record := traktorv1beta1.ApprovalRecord{
+		PlanID:          planID,
+		SecretName:      secret.Name,
+		SecretNamespace: secret.Namespace,
+		Decision:        decision,
+		By:              by,
+		Time:            metav1.NewTime(now),
+	}
+	for _, sr := range approvers {
+		err := r.updateStatus(ctx, sr, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+			status.ApprovalHistory = append(status.ApprovalHistory, record)
+			if overflow := len(status.ApprovalHistory) - maxApprovalHistory; overflow > 0 {
+				status.ApprovalHistory = status.ApprovalHistory[overflow:]
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// proposePlan records a new restart plan in every approver SecretsRefresh
+func (r *SecretsRefreshReconciler) proposePlan(ctx context.Context, approvers []*traktorv1beta1.SecretsRefresh, secret *corev1.Secret, author string, targets []*appsv1.Deployment, planID string, now time.Time, result *approvalResult) error {
+	workloads := make([]traktorv1beta1.WorkloadReference, 0, len(targets))
+	for _, deployment := range targets {
+		workloads = append(workloads, traktorv1beta1.WorkloadReference{
+			Kind:      "Deployment",
+			Name:      deployment.Name,
+			Namespace: deployment.Namespace,
+		})
+	}
+
+	// The shortest TTL wins so no approver sees a plan outlive its own limit
+	ttl := approvalTTL(approvers[0])
+	for _, sr := range approvers[1:] {
+		ttl = min(ttl, approvalTTL(sr))
+	}
+	expiresAt := metav1.NewTime(now.Add(ttl))
+
+	pending := traktorv1beta1.PendingRestart{
+		SecretName:      secret.Name,
+		SecretNamespace: secret.Namespace,
+		DetectedAt:      metav1.NewTime(now),
+		Reason:          ReasonAwaitingApproval,
+		PlanID:          planID,
+		Workloads:       workloads,
+		ExpiresAt:       &expiresAt,
+	}
+
+	changedBy := ""
+	if author != "" {
+		changedBy = " changed by " + author
+	}
+	for _, sr := range approvers {
+		if _, err := r.upsertPendingRestart(ctx, sr, pending); err != nil {
+			return err
+		}
+		r.recordEvent(sr, corev1.EventTypeNormal, "ApprovalRequired",
+			fmt.Sprintf("Restart plan %s for secret %s/%s%s restarts %d workload(s); approve with annotation %s=%s",
+				planID, secret.Namespace, secret.Name, changedBy, len(workloads), traktorv1beta1.ApproveAnnotation, planID))
+	}
+
+	result.waiting = true
+	result.requeueAfter = ttl
+	return nil
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// A ClusterSecretsRefresh is handled as a SecretsRefresh without a namespace, so the
+// policies, status and events work the same way for both kinds. secretsRefreshObject
+// turns it back into the stored object before it is written or referenced.
+
+// fromClusterSecretsRefresh returns the ClusterSecretsRefresh as a SecretsRefresh without a namespace
+func fromClusterSecretsRefresh(csr *traktorv1beta1.ClusterSecretsRefresh) *traktorv1beta1.SecretsRefresh {
+	return &traktorv1beta1.SecretsRefresh{
+		ObjectMeta: *csr.ObjectMeta.DeepCopy(),
+		Spec:       *csr.Spec.DeepCopy(),
+		Status:     *csr.Status.DeepCopy(),
+	}
+}
+
+// isClusterSecretsRefresh reports whether the SecretsRefresh stands for a ClusterSecretsRefresh
+func isClusterSecretsRefresh(sr *traktorv1beta1.SecretsRefresh) bool {
+	return sr.Namespace == ""
+}
+
+// secretsRefreshObject returns the stored object of the SecretsRefresh
+func secretsRefreshObject(sr *traktorv1beta1.SecretsRefresh) client.Object {
+	if !isClusterSecretsRefresh(sr) {
+		return sr
+	}
+	return &traktorv1beta1.ClusterSecretsRefresh{
+		ObjectMeta: sr.ObjectMeta,
+		Spec:       sr.Spec,
+		Status:     sr.Status,
+	}
+}
+
+// getSecretsRefresh gets the SecretsRefresh, or the ClusterSecretsRefresh for keys without a namespace
+func getSecretsRefresh(ctx context.Context, c client.Reader, key types.NamespacedName, sr *traktorv1beta1.SecretsRefresh) error {
+	if key.Namespace != "" {
+		return c.Get(ctx, key, sr)
+	}
+	csr := &traktorv1beta1.ClusterSecretsRefresh{}
+	if err := c.Get(ctx, key, csr); err != nil {
+		return err
+	}
+	*sr = *fromClusterSecretsRefresh(csr)
+	return nil
+}
+
+// listSecretsRefreshes lists all SecretsRefresh and ClusterSecretsRefresh objects
+func listSecretsRefreshes(ctx context.Context, c client.Reader) ([]traktorv1beta1.SecretsRefresh, error) {
+	srList := &traktorv1beta1.SecretsRefreshList{}
+	if err := c.List(ctx, srList); err != nil {
+		return nil, err
+	}
+	csrList := &traktorv1beta1.ClusterSecretsRefreshList{}
+	if err := c.List(ctx, csrList); err != nil {
+		return nil, err
+	}
+
+	srs := make([]traktorv1beta1.SecretsRefresh, 0, len(csrList.Items)+len(srList.Items))
+	for i := range csrList.Items {
+		srs = append(srs, *fromClusterSecretsRefresh(&csrList.Items[i]))
+	}
+	return append(srs, srList.Items...), nil
+}
+
+// selectsNoNamespace reports whether the SecretsRefresh is a ClusterSecretsRefresh without
+// a namespace selector that hasn't confirmed selecting all namespaces with allNamespaces
+func selectsNoNamespace(sr *traktorv1beta1.SecretsRefresh) bool {
+	sel := sr.Spec.Targets.NamespaceSelector
+	empty := sel == nil || (len(sel.MatchLabels) == 0 && len(sel.MatchExpressions) == 0)
+	return isClusterSecretsRefresh(sr) && empty && !sr.Spec.Targets.AllNamespaces
+}
+
+// namespaceAllowed reports whether the SecretsRefresh may select the namespace: its own
+// namespace, namespaces granting it access, or any namespace for a ClusterSecretsRefresh
+func namespaceAllowed(sr *traktorv1beta1.SecretsRefresh, namespace *corev1.Namespace) bool {
+	if isClusterSecretsRefresh(sr) || namespace.Name == sr.Namespace {
+		return true
+	}
+	for _, grant := range strings.Split(namespace.Annotations[traktorv1beta1.AllowSecretsRefreshFromAnnotation], ",") {
+		grantNamespace, grantName, named := strings.Cut(strings.TrimSpace(grant), "/")
+		if grantNamespace == sr.Namespace && (!named || grantName == sr.Name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// Reasons of the Ready condition of the TraktorConfig
+const (
+	ReasonConfigApplied = "Applied"
+	ReasonInvalidConfig = "InvalidConfig"
+)
+
+// ReasonRateLimited is recorded for restarts deferred by the global restart rate limit
+const ReasonRateLimited = "RateLimited"
+
+// rateLimitWindow is the window of TraktorConfig spec.rateLimits.maxRestartsPerMinute
+const rateLimitWindow = time.Minute
+
+// OperatorConfig is the applied TraktorConfig, shared by the reconcilers. A nil
+// OperatorConfig, or one without a TraktorConfig, applies the defaults.
+type OperatorConfig struct {
+	mu   sync.RWMutex
+	spec traktorv1beta1.TraktorConfigSpec
+	// applied is false while the defaults apply
+	applied bool
+	// restarts are the times of the restarts within the rate limit window
+	restarts []time.Time
+}
+
+// set applies the spec of a valid TraktorConfig
+func (c *OperatorConfig) set(spec *traktorv1beta1.TraktorConfigSpec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spec = *spec.DeepCopy()
+	c.applied = true
+}
+
+// setDefaults applies the defaults, when there is no valid TraktorConfig to apply
+func (c *OperatorConfig) setDefaults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spec = traktorv1beta1.TraktorConfigSpec{}
+	c.applied = false
+}
+
+// usesDefaults reports whether the defaults apply rather than a TraktorConfig
+func (c *OperatorConfig) usesDefaults() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.applied
+}
+
+// get returns a copy of the applied spec
+func (c *OperatorConfig) get() traktorv1beta1.TraktorConfigSpec {
+	if c == nil {
+		return traktorv1beta1.TraktorConfigSpec{}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return *c.spec.DeepCopy()
+}
+
+// defaultRestartStrategy returns the restart strategy of SecretsRefresh objects that don't set one
+func (c *OperatorConfig) defaultRestartStrategy() traktorv1beta1.RestartStrategyType {
+	if s := c.get().DefaultRestartStrategy; s != "" {
+		return s
+	}
+	return traktorv1beta1.RestartStrategyTemplateAnnotation
+}
+
+// workloadKindEnabled reports whether workloads of the kind are restarted
+func (c *OperatorConfig) workloadKindEnabled(kind string) bool {
+	kinds := c.get().WorkloadKinds
+	return len(kinds) == 0 || slices.Contains(kinds, kind)
+}
+
+// dryRun reports whether the features enable dry run for all SecretsRefresh objects
+func (c *OperatorConfig) dryRun() bool {
+	return c.get().Features.DryRun
+}
+
+// actionsEnabled reports whether actions of SecretsRefresh objects run
+func (c *OperatorConfig) actionsEnabled() bool {
+	return featureEnabled(c.get().Features.Actions)
+}
+
+// rollbackEnabled reports whether secret changes are snapshotted for rollback
+func (c *OperatorConfig) rollbackEnabled() bool {
+	return featureEnabled(c.get().Features.Rollback)
+}
+
+// reloadEndpointsEnabled reports whether reload endpoints are called instead of restarting
+func (c *OperatorConfig) reloadEndpointsEnabled() bool {
+	return featureEnabled(c.get().Features.ReloadEndpoints)
+}
+
+// namespacedJobTemplatesEnabled reports whether namespaced SecretsRefresh objects may
+// run actions from inline job templates
+func (c *OperatorConfig) namespacedJobTemplatesEnabled() bool {
+	return c.get().Features.NamespacedJobTemplates
+}
+
+// notifiers returns the notification webhooks receiving every refresh
+func (c *OperatorConfig) notifiers() []traktorv1beta1.NotificationWebhook {
+	return c.get().Notifiers
+}
+
+// featureEnabled returns the value of a feature toggle that defaults to on
+func featureEnabled(toggle *bool) bool {
+	return toggle == nil || *toggle
+}
+
+// reserveRestart takes a restart from the global rate limit. It returns zero if the
+// restart may happen now, otherwise how long to wait before trying again.
+func (c *OperatorConfig) reserveRestart(now time.Time) time.Duration {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	limit := int(c.spec.RateLimits.MaxRestartsPerMinute)
+	if limit <= 0 {
+		return 0
+	}
+	c.restarts = slices.DeleteFunc(c.restarts, func(t time.Time) bool {
+		return !t.After(now.Add(-rateLimitWindow))
+	})
+	if len(c.restarts) >= limit {
+		return c.restarts[0].Add(rateLimitWindow).Sub(now)
+	}
+	c.restarts = append(c.restarts, now)
+	return 0
+}
+
+// validateTraktorConfig returns why the TraktorConfig can't be applied, empty if it is valid.
+// The schema of the CRD validates the rest.
+func validateTraktorConfig(spec *traktorv1beta1.TraktorConfigSpec) string {
+	problems := validateProtection(&spec.Protected)
+	for _, notifier := range spec.Notifiers {
+		if u, err := url.Parse(notifier.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("notifier %s: must be an absolute http or https URL", notifier.Name))
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// LoadOperatorConfig reads the TraktorConfig once, before the manager starts, so the
+// reconcilers don't run with the defaults until the TraktorConfig reconciler catches
+// up. Without a TraktorConfig the defaults apply. With an invalid one they apply as
+// well, the last valid configuration isn't known yet, and the TraktorConfig reconciler
+// reports the TraktorConfig as Degraded until it is fixed.
+func LoadOperatorConfig(ctx context.Context, reader client.Reader) (*OperatorConfig, error) {
+	operatorConfig := &OperatorConfig{}
+	config := &traktorv1beta1.TraktorConfig{}
+	if err := reader.Get(ctx, client.ObjectKey{Name: traktorv1beta1.TraktorConfigName}, config); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to read TraktorConfig: %w", err)
+		}
+		return operatorConfig, nil
+	}
+	if problem := validateTraktorConfig(&config.Spec); problem != "" {
+		log.FromContext(ctx).Info("Invalid TraktorConfig, applying the defaults", "problem", problem)
+		return operatorConfig, nil
+	}
+	operatorConfig.set(&config.Spec)
+	return operatorConfig, nil
+}
+
+// TraktorConfigReconciler validates the TraktorConfig and applies it to the shared
+// OperatorConfig, so changes take effect without restarting the operator
+type TraktorConfigReconciler struct {
+	client.Client
+
+	// Config receives the applied configuration
+	Config *OperatorConfig
+}
+
+// +kubebuilder:rbac:groups=traktor.gdxcloud.net,resources=traktorconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=traktor.gdxcloud.net,resources=traktorconfigs/status,verbs=get;update;patch
+
+// Reconcile applies the TraktorConfig and reports the result in its status
+func (r *TraktorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Only the singleton is read, the CRD rejects other names
+	if req.Name != traktorv1beta1.TraktorConfigName {
+		return ctrl.Result{}, nil
+	}
+
+	config := &traktorv1beta1.TraktorConfig{}
+	if err := r.Get(ctx, req.NamespacedName, config); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("TraktorConfig deleted, applying the defaults")
+		r.Config.setDefaults()
+		return ctrl.Result{}, nil
+	}
+
+	ready := metav1.Condition{
+		Type:               traktorv1beta1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonConfigApplied,
+		Message:            "Configuration applied",
+		ObservedGeneration: config.Generation,
+	}
+	degraded := metav1.Condition{
+		Type:               traktorv1beta1.ConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonConfigApplied,
+		Message:            "Configuration applied",
+		ObservedGeneration: config.Generation,
+	}
+	if problem := validateTraktorConfig(&config.Spec); problem != "" {
+		// The operator may have started with the defaults, then there is no valid configuration to keep
+		inUse := "the last valid configuration"
+		if r.Config.usesDefaults() {
+			inUse = "the defaults"
+		}
+		logger.Info("Invalid TraktorConfig, applying "+inUse, "problem", problem)
+		ready.Status, ready.Reason = metav1.ConditionFalse, ReasonInvalidConfig
+		degraded.Status, degraded.Reason = metav1.ConditionTrue, ReasonInvalidConfig
+		ready.Message = "Applying " + inUse + ": " + problem
+		degraded.Message = ready.Message
+	} else {
+		r.Config.set(&config.Spec)
+		logger.Info("TraktorConfig applied", "generation", config.Generation)
+	}
+
+	changed := meta.SetStatusCondition(&config.Status.Conditions, ready)
+	changed = meta.SetStatusCondition(&config.Status.Conditions, degraded) || changed
+	if !changed && config.Status.ObservedGeneration == config.Generation {
+		return ctrl.Result{}, nil
+	}
+	config.Status.ObservedGeneration = config.Generation
+	return ctrl.Result{}, r.Status().Update(ctx, config)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TraktorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&traktorv1beta1.TraktorConfig{}).
+		Named("traktorconfig").
+		Complete(r)
+}
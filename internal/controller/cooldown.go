@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// Reasons and conditions for restarts held back because of cooldowns and flapping secrets
+const (
+	ReasonCooldown = "Cooldown"
+
+	ConditionSecretFlapping = "SecretFlapping"
+	ReasonSecretFlapping    = "SecretFlapping"
+	ReasonSecretStable      = "SecretStable"
+)
+
+// maxTrackedChanges bounds the change timestamps kept per secret
+const maxTrackedChanges = 100
+
+// cooldownFor returns the longest cooldown of the matching SecretsRefresh objects
+func cooldownFor(policies []restartPolicy) time.Duration {
+	var cooldown time.Duration
+	for _, p := range policies {
+		if c := p.secretsRefresh.Spec.Rollout.Cooldown; c != nil && c.Duration > cooldown {
+			cooldown = c.Duration
+		}
+	}
+	return cooldown
+}
+
+// lastRestart returns when Traktor last restarted the Deployment, from the pod
+// template or, for strategies that leave the template alone, the Deployment itself
+func lastRestart(deployment *appsv1.Deployment) time.Time {
+	var last time.Time
+	for _, annotations := range []map[string]string{deployment.Spec.Template.Annotations, deployment.Annotations} {
+		raw, ok := annotations[traktorv1beta1.RestartedAtAnnotation]
+		if !ok {
+			continue
+		}
+		if restartedAt, err := time.Parse(time.RFC3339, raw); err == nil && restartedAt.After(last) {
+			last = restartedAt
+		}
+	}
+	return last
+}
+
+// cooldownRemaining returns how long the Deployment has to wait before Traktor may
+// restart it again, zero when it may be restarted now
+func cooldownRemaining(deployment *appsv1.Deployment, cooldown time.Duration, now time.Time) time.Duration {
+	if cooldown <= 0 {
+		return 0
+	}
+	last := lastRestart(deployment)
+	if last.IsZero() {
+		return 0
+	}
+	return max(last.Add(cooldown).Sub(now), 0)
+}
+
+// secretChanges remembers when secrets changed, so flapping secrets can be detected.
+// Changes are only kept as long as the largest flap detection window of the
+// SecretsRefresh objects selecting the secret needs them.
+type secretChanges struct {
+	mu      sync.Mutex
+	changes map[types.NamespacedName][]time.Time
+	// windows are the largest flap detection windows of the tracked secrets
+	windows map[types.NamespacedName]time.Duration
+}
+
+// record remembers a change of the secret for the window, forgetting the secret when
+// the window is zero. Changes of all secrets that fell out of their window are evicted.
+func (c *secretChanges) record(key types.NamespacedName, at time.Time, window time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changes == nil {
+		c.changes = map[types.NamespacedName][]time.Time{}
+		c.windows = map[types.NamespacedName]time.Duration{}
+	}
+	if window <= 0 {
+		delete(c.changes, key)
+		delete(c.windows, key)
+	} else {
+		changes := append(c.changes[key], at)
+		if overflow := len(changes) - maxTrackedChanges; overflow > 0 {
+			changes = changes[overflow:]
+		}
+		c.changes[key] = changes
+		c.windows[key] = window
+	}
+
+	for other, changes := range c.changes {
+		expired := at.Add(-c.windows[other])
+		changes = slices.DeleteFunc(changes, func(t time.Time) bool { return !t.After(expired) })
+		if len(changes) == 0 {
+			delete(c.changes, other)
+			delete(c.windows, other)
+			continue
+		}
+		c.changes[other] = changes
+	}
+}
+
+// flapWindow returns the largest flap detection window of the SecretsRefresh objects,
+// zero if none of them detects flapping
+func flapWindow(srs []traktorv1beta1.SecretsRefresh) time.Duration {
+	var window time.Duration
+	for i := range srs {
+		if detection := srs[i].Spec.Triggers.FlapDetection; detection != nil {
+			window = max(window, detection.Window.Duration)
+		}
+	}
+	return window
+}
+
+// since returns how often the secret changed after the given time and when it changed last
+func (c *secretChanges) since(key types.NamespacedName, after time.Time) (int, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count := 0
+	var last time.Time
+	for _, at := range c.changes[key] {
+		if at.After(after) {
+			count++
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	return count, last
+}
+
+// flapping describes a secret whose restarts are paused because it changes too often
+type flapping struct {
+	// resumeAt is when the secret is considered stable if it doesn't change again
+	resumeAt time.Time
+	// changes is the number of changes that triggered the detection, zero if already paused
+	changes int
+	// secretsRefreshes are the SecretsRefresh objects with flap detection enabled
+	secretsRefreshes []*traktorv1beta1.SecretsRefresh
+}
+
+// flappingFor reports whether restarts for the secret are paused by flap detection.
+// A secret starts flapping when it changed more than maxChanges times within the window
+// of a SecretsRefresh and stays paused until it has not changed for a whole window.
+func (r *SecretsRefreshReconciler) flappingFor(secret *corev1.Secret, policies []restartPolicy, now time.Time) *flapping {
+	key := types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}
+	var f *flapping
+	for _, p := range policies {
+		sr := p.secretsRefresh
+		detection := sr.Spec.Triggers.FlapDetection
+		if detection == nil || detection.Window.Duration <= 0 {
+			continue
+		}
+
+		window := detection.Window.Duration
+		count, last := r.secretChanges.since(key, now.Add(-window))
+		paused := pendingReason(sr, secret) == ReasonSecretFlapping
+		if count <= int(detection.MaxChanges) && !paused {
+			continue
+		}
+		resumeAt := last.Add(window)
+		if !resumeAt.After(now) {
+			continue
+		}
+
+		if f == nil {
+			f = &flapping{}
+		}
+		if resumeAt.After(f.resumeAt) {
+			f.resumeAt = resumeAt
+		}
+		if !paused {
+			f.changes = max(f.changes, count)
+		}
+		f.secretsRefreshes = append(f.secretsRefreshes, sr)
+	}
+	return f
+}
+
+// pendingReason returns the reason of the pending restart of the secret in the SecretsRefresh, if any
+func pendingReason(sr *traktorv1beta1.SecretsRefresh, secret *corev1.Secret) string {
+	for _, pending := range sr.Status.PendingRestarts {
+		if pending.SecretName == secret.Name && pending.SecretNamespace == secret.Namespace {
+			return pending.Reason
+		}
+	}
+	return ""
+}
+
+// pauseFlappingSecret records the flapping secret as pending and raises the SecretFlapping condition
+func (r *SecretsRefreshReconciler) pauseFlappingSecret(ctx context.Context, f *flapping, secret *corev1.Secret, now time.Time) error {
+	resumeAt := metav1.NewTime(f.resumeAt)
+	pending := traktorv1beta1.PendingRestart{
+		SecretName:      secret.Name,
+		SecretNamespace: secret.Namespace,
+		DetectedAt:      metav1.NewTime(now),
+		Reason:          ReasonSecretFlapping,
+		NotBefore:       &resumeAt,
+	}
+
+	for _, sr := range f.secretsRefreshes {
+		if f.changes > 0 {
+			r.recordEvent(sr, corev1.EventTypeWarning, ReasonSecretFlapping,
+				fmt.Sprintf("Secret %s/%s changed %d times within the flap detection window, restarts paused until it is stable",
+					secret.Namespace, secret.Name, f.changes))
+		}
+		if _, err := r.upsertPendingRestart(ctx, sr, pending); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncFlappingCondition sets the SecretFlapping condition from the pending restarts
+// and reports whether it changed
+func syncFlappingCondition(status *traktorv1beta1.SecretsRefreshStatus, generation int64) bool {
+	var secrets []string
+	for _, pending := range status.PendingRestarts {
+		if pending.Reason == ReasonSecretFlapping {
+			secrets = append(secrets, pending.SecretNamespace+"/"+pending.SecretName)
+		}
+	}
+
+	if len(secrets) > 0 {
+		sort.Strings(secrets)
+		return meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               ConditionSecretFlapping,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             ReasonSecretFlapping,
+			Message:            "Restarts paused for secrets that change too often: " + strings.Join(secrets, ", "),
+		})
+	}
+
+	if !meta.IsStatusConditionTrue(status.Conditions, ConditionSecretFlapping) {
+		return false
+	}
+	return meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionSecretFlapping,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             ReasonSecretStable,
+		Message:            "No secret is flapping",
+	})
+}
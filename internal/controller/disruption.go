@@ -0,0 +1,262 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// rolloutRecheckInterval is how often a Deployment that is still rolling out is checked
+// again: to restore its strategy after a surged restart, to measure the restart duration,
+// to decide on a rollback, and to retry a restart deferred until the rollout finished
+const rolloutRecheckInterval = 30 * time.Second
+
+// disruptionPolicyOrder ranks the policies from least to most conservative
+var disruptionPolicyOrder = map[traktorv1beta1.DisruptionPolicy]int{
+	traktorv1beta1.DisruptionPolicyAllow:            0,
+	traktorv1beta1.DisruptionPolicyWarn:             1,
+	traktorv1beta1.DisruptionPolicySurgeTemporarily: 2,
+	traktorv1beta1.DisruptionPolicySkip:             3,
+}
+
+// disruptionPolicyFor returns the most conservative disruption policy of the matching
+// SecretsRefresh objects, Warn when none is set
+func disruptionPolicyFor(policies []restartPolicy) traktorv1beta1.DisruptionPolicy {
+	result := traktorv1beta1.DisruptionPolicyAllow
+	if len(policies) == 0 {
+		return traktorv1beta1.DisruptionPolicyWarn
+	}
+	for _, p := range policies {
+		dp := p.secretsRefresh.Spec.Rollout.DisruptionPolicy
+		if dp == "" {
+			dp = traktorv1beta1.DisruptionPolicyWarn
+		}
+		if disruptionPolicyOrder[dp] > disruptionPolicyOrder[result] {
+			result = dp
+		}
+	}
+	return result
+}
+
+// deploymentReplicas returns the desired replicas of a Deployment, defaulting to 1
+func deploymentReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
+// rollingUpdateLimits resolves maxSurge and maxUnavailable against the replica count
+// the same way the Deployment controller does
+func rollingUpdateLimits(deployment *appsv1.Deployment) (surge, unavailable int, err error) {
+	defaultLimit := intstr.FromString("25%")
+	maxSurge, maxUnavailable := &defaultLimit, &defaultLimit
+	if ru := deployment.Spec.Strategy.RollingUpdate; ru != nil {
+		if ru.MaxSurge != nil {
+			maxSurge = ru.MaxSurge
+		}
+		if ru.MaxUnavailable != nil {
+			maxUnavailable = ru.MaxUnavailable
+		}
+	}
+
+	replicas := int(deploymentReplicas(deployment))
+	surge, err = intstr.GetScaledValueFromIntOrPercent(maxSurge, replicas, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	unavailable, err = intstr.GetScaledValueFromIntOrPercent(maxUnavailable, replicas, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	return surge, unavailable, nil
+}
+
+// disruptionRisk explains why restarting the Deployment with the strategy would cause
+// downtime or reduced capacity, or returns an empty string when it is zero downtime
+func disruptionRisk(deployment *appsv1.Deployment, strategy traktorv1beta1.RestartStrategyType) string {
+	replicas := deploymentReplicas(deployment)
+	if replicas == 0 {
+		return ""
+	}
+
+	switch strategy {
+	case restartStrategyReload:
+		return ""
+	case traktorv1beta1.RestartStrategyScaleBounce:
+		return "restart strategy ScaleBounce stops all pods before starting new ones"
+	case traktorv1beta1.RestartStrategyDeletePods:
+		if replicas == 1 {
+			return "restart strategy DeletePods evicts the only pod"
+		}
+		return ""
+	}
+
+	if deployment.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+		return "strategy Recreate stops all pods before starting new ones"
+	}
+
+	surge, unavailable, err := rollingUpdateLimits(deployment)
+	if err != nil {
+		return fmt.Sprintf("invalid rolling update parameters: %v", err)
+	}
+	if unavailable >= int(replicas) {
+		return fmt.Sprintf("maxUnavailable %d allows all %d replica(s) to be down at once", unavailable, replicas)
+	}
+	if surge == 0 {
+		return "maxSurge 0 stops pods before their replacements are ready"
+	}
+	return ""
+}
+
+// surgeStrategy restarts the Deployment with a surging rolling update that keeps
+// every replica available. The original strategy is kept in an annotation and
+// restored by the StrategyRestoreReconciler once the rollout has finished.
+type surgeStrategy struct {
+	client client.Client
+}
+
+func (s *surgeStrategy) Restart(ctx context.Context, deployment *appsv1.Deployment, secret *corev1.Secret) error {
+	annotations, err := restartAnnotations(deployment.Spec.Template.Annotations, secret)
+	if err != nil {
+		return err
+	}
+	patched := deployment.DeepCopy()
+
+	if _, surged := patched.Annotations[traktorv1beta1.OriginalStrategyAnnotation]; !surged {
+		original, err := json.Marshal(deployment.Spec.Strategy)
+		if err != nil {
+			return fmt.Errorf("failed to marshal strategy: %w", err)
+		}
+		if patched.Annotations == nil {
+			patched.Annotations = map[string]string{}
+		}
+		patched.Annotations[traktorv1beta1.OriginalStrategyAnnotation] = string(original)
+	}
+
+	// Keep a configured surge, otherwise add one pod at a time
+	maxSurge := intstr.FromInt32(1)
+	if ru := deployment.Spec.Strategy.RollingUpdate; ru != nil && ru.MaxSurge != nil &&
+		deployment.Spec.Strategy.Type != appsv1.RecreateDeploymentStrategyType {
+		if surge, _, err := rollingUpdateLimits(deployment); err == nil && surge > 0 {
+			maxSurge = *ru.MaxSurge
+		}
+	}
+	maxUnavailable := intstr.FromInt32(0)
+	patched.Spec.Strategy = appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxSurge:       &maxSurge,
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+
+	if patched.Spec.Template.Annotations == nil {
+		patched.Spec.Template.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		patched.Spec.Template.Annotations[key] = value
+	}
+
+	return s.client.Patch(ctx, patched, client.MergeFrom(deployment))
+}
+
+// rolloutComplete reports whether the Deployment controller has finished rolling out
+// the current generation
+func rolloutComplete(deployment *appsv1.Deployment) bool {
+	replicas := deploymentReplicas(deployment)
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.Replicas == replicas &&
+		status.AvailableReplicas == replicas
+}
+
+// progressDeadlineExceeded reports whether the Deployment controller gave up on the rollout
+func progressDeadlineExceeded(deployment *appsv1.Deployment) bool {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing {
+			return condition.Status == corev1.ConditionFalse && condition.Reason == "ProgressDeadlineExceeded"
+		}
+	}
+	return false
+}
+
+// StrategyRestoreReconciler restores the original strategy of Deployments that were
+// temporarily switched to a surging rolling update for a restart
+type StrategyRestoreReconciler struct {
+	client.Client
+}
+
+// Reconcile restores the strategy once the surged rollout has finished, or has failed as
+// the surge would otherwise stay until the next successful rollout
+func (r *StrategyRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, req.NamespacedName, deployment); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	original, ok := deployment.Annotations[traktorv1beta1.OriginalStrategyAnnotation]
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+	// A deadline exceeded before the restart was observed belongs to an earlier rollout
+	failed := deployment.Status.ObservedGeneration >= deployment.Generation && progressDeadlineExceeded(deployment)
+	if !rolloutComplete(deployment) && !failed {
+		return ctrl.Result{RequeueAfter: rolloutRecheckInterval}, nil
+	}
+
+	patched := deployment.DeepCopy()
+	delete(patched.Annotations, traktorv1beta1.OriginalStrategyAnnotation)
+	patched.Spec.Strategy = appsv1.DeploymentStrategy{}
+	if err := json.Unmarshal([]byte(original), &patched.Spec.Strategy); err != nil {
+		// A broken annotation can't be restored, drop it so the Deployment keeps the safe strategy
+		logger.Error(err, "Invalid original strategy annotation, keeping the current strategy",
+			"deployment", deployment.Name, "namespace", deployment.Namespace)
+		patched.Spec.Strategy = deployment.Spec.Strategy
+	}
+
+	if err := r.Patch(ctx, patched, client.MergeFrom(deployment)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("Restored deployment strategy after restart",
+		"deployment", deployment.Name,
+		"namespace", deployment.Namespace,
+		"strategy", patched.Spec.Strategy.Type,
+		"rolloutFailed", failed)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *StrategyRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	surged := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := obj.GetAnnotations()[traktorv1beta1.OriginalStrategyAnnotation]
+		return ok
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appsv1.Deployment{}, builder.WithPredicates(surged)).
+		Named("strategyrestore").
+		Complete(r)
+}
+
+// warnDisruption reports a restart that is going ahead despite its disruption risk
+func (r *SecretsRefreshReconciler) warnDisruption(deployment *appsv1.Deployment, risk string) {
+	r.recordEvent(deployment, corev1.EventTypeWarning, "DisruptiveRestart",
+		fmt.Sprintf("Restart of Deployment %s/%s may cause downtime: %s", deployment.Namespace, deployment.Name, risk))
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// maxDryRunRestarts bounds status.dryRunRestarts
+const maxDryRunRestarts = 50
+
+// dryRunSecretsRefreshes returns whether restarts run in dry run mode and the
+// SecretsRefresh objects to record them in. A single matching SecretsRefresh in
+// dry run is enough, so enabling it never widens the blast radius. When a
+// ClusterSecretsRefresh governs the change, only ClusterSecretsRefresh objects
+// decide, so a tenant can't hold back a change the cluster rolls out.
+func (r *SecretsRefreshReconciler) dryRunSecretsRefreshes(policies []restartPolicy) (bool, []*traktorv1beta1.SecretsRefresh) {
+	global := r.DryRun || r.Config.dryRun()
+	clusterGoverned := slices.ContainsFunc(policies, func(p restartPolicy) bool {
+		return isClusterSecretsRefresh(p.secretsRefresh)
+	})
+	var srs []*traktorv1beta1.SecretsRefresh
+	for _, p := range policies {
+		if clusterGoverned && !global && !isClusterSecretsRefresh(p.secretsRefresh) {
+			continue
+		}
+		if global || p.secretsRefresh.Spec.Rollout.DryRun {
+			srs = append(srs, p.secretsRefresh)
+		}
+	}
+	return global || len(srs) > 0, srs
+}
+
+// recordDryRunRestart records a restart that was skipped because of dry run. A non-nil
+// deferral records that the restart would have been deferred instead.
+func (r *SecretsRefreshReconciler) recordDryRunRestart(ctx context.Context, srs []*traktorv1beta1.SecretsRefresh, deployment *appsv1.Deployment, secret *corev1.Secret, d *deferral, now time.Time) error {
+	references := secretReferences(deployment, secret.Name)
+	message := fmt.Sprintf("Would restart Deployment %s/%s due to Secret %s (reference: %s)",
+		deployment.Namespace, deployment.Name, secret.Name, strings.Join(references, ", "))
+	if d != nil {
+		message = fmt.Sprintf("Would defer restart of Deployment %s/%s due to Secret %s (reference: %s, reason: %s)",
+			deployment.Namespace, deployment.Name, secret.Name, strings.Join(references, ", "), d.reason)
+	}
+
+	// Without a SecretsRefresh to report to, tell the workload itself
+	if len(srs) == 0 {
+		r.recordEvent(deployment, corev1.EventTypeNormal, "DryRunRestart", message)
+		return nil
+	}
+
+	entry := traktorv1beta1.DryRunRestart{
+		Kind:       "Deployment",
+		Name:       deployment.Name,
+		Namespace:  deployment.Namespace,
+		SecretName: secret.Name,
+		References: references,
+		Time:       metav1.NewTime(now),
+	}
+	if d != nil {
+		entry.DeferredBy = d.reason
+		if !d.notBefore.IsZero() {
+			notBefore := metav1.NewTime(d.notBefore)
+			entry.NotBefore = &notBefore
+		}
+	}
+
+	for _, sr := range srs {
+		r.recordEvent(sr, corev1.EventTypeNormal, "DryRunRestart", message)
+		err := r.updateStatus(ctx, sr, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+			status.DryRunRestarts = append(status.DryRunRestarts, entry)
+			if overflow := len(status.DryRunRestarts) - maxDryRunRestarts; overflow > 0 {
+				status.DryRunRestarts = status.DryRunRestarts[overflow:]
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
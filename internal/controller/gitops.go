@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// GitOpsConflictError reports restart annotations owned by the field manager of a GitOps tool
+type GitOpsConflictError struct {
+	Managers []string
+	cause    error
+}
+
+func (e *GitOpsConflictError) Error() string {
+	message := fmt.Sprintf("restart annotations conflict with GitOps field managers %s", strings.Join(e.Managers, ", "))
+	if e.cause != nil {
+		message += ": " + e.cause.Error()
+	}
+	return message
+}
+
+func (e *GitOpsConflictError) Unwrap() error {
+	return e.cause
+}
+
+// gitOpsStrategy returns the restart strategy for GitOps mode
+func gitOpsStrategy(gitOps *traktorv1beta1.GitOps, c client.Client) (traktorv1beta1.RestartStrategyType, RestartStrategy) {
+	if gitOps.Mode == traktorv1beta1.GitOpsModeRecyclePods {
+		return traktorv1beta1.RestartStrategyDeletePods, &deletePodsStrategy{client: c}
+	}
+
+	fieldManager := gitOps.FieldManager
+	if fieldManager == "" {
+		fieldManager = traktorv1beta1.DefaultFieldManager
+	}
+	managers := gitOps.Managers
+	if len(managers) == 0 {
+		managers = traktorv1beta1.DefaultGitOpsManagers
+	}
+	return traktorv1beta1.RestartStrategyServerSideApply, &serverSideApplyStrategy{
+		client:           c,
+		fieldManager:     fieldManager,
+		conflictManagers: managers,
+	}
+}
+
+// restartAnnotationOwners returns the given field managers that own one of the restart
+// annotations of the pod template, e.g. because the annotation was committed to Git
+func restartAnnotationOwners(deployment *appsv1.Deployment, managers []string) []string {
+	var owners []string
+	for _, entry := range deployment.ManagedFields {
+		if !slices.Contains(managers, entry.Manager) || slices.Contains(owners, entry.Manager) || entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		annotations := nestedFields(fields, "f:spec", "f:template", "f:metadata", "f:annotations")
+		for _, key := range []string{traktorv1beta1.RestartedAtAnnotation, traktorv1beta1.SecretVersionsAnnotation} {
+			if _, ok := annotations["f:"+key]; ok {
+				owners = append(owners, entry.Manager)
+				break
+			}
+		}
+	}
+	return owners
+}
+
+// nestedFields walks a managed fields set along the given path
+func nestedFields(fields map[string]interface{}, path ...string) map[string]interface{} {
+	for _, key := range path {
+		next, ok := fields[key].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields = next
+	}
+	return fields
+}
+
+// reportGitOpsConflict tells the workload and the SecretsRefresh objects that the GitOps
+// tool owns the restart annotations and the pods are recycled instead
+func (r *SecretsRefreshReconciler) reportGitOpsConflict(policies []restartPolicy, deployment *appsv1.Deployment, conflict *GitOpsConflictError) {
+	message := fmt.Sprintf("Restart of Deployment %s/%s conflicts with %s, recycling pods instead of patching the pod template",
+		deployment.Namespace, deployment.Name, strings.Join(conflict.Managers, ", "))
+	r.recordEvent(deployment, corev1.EventTypeWarning, "GitOpsConflict", message)
+	for _, p := range policies {
+		if p.secretsRefresh.Spec.Rollout.GitOps != nil {
+			r.recordEvent(p.secretsRefresh, corev1.EventTypeWarning, "GitOpsConflict", message)
+		}
+	}
+}
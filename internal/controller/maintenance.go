@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+	"github.com/GDXbsv/traktor/internal/policy"
+)
+
+// Reasons recorded for deferred restarts
+const (
+	ReasonChangeFreeze             = "ChangeFreeze"
+	ReasonOutsideMaintenanceWindow = "OutsideMaintenanceWindow"
+	ReasonInvalidMaintenanceWindow = "InvalidMaintenanceWindow"
+)
+
+// freezeRecheckInterval is how often a frozen change is retried, freezes have no end time
+const freezeRecheckInterval = time.Minute
+
+// deferral describes why the restarts for a secret change have to wait
+type deferral struct {
+	reason string
+	// notBefore is when the change can be retried, zero if unknown
+	notBefore time.Time
+	// secretsRefreshes are the SecretsRefresh objects the change is recorded in
+	secretsRefreshes []*traktorv1beta1.SecretsRefresh
+}
+
+// requeueAfter returns when the deferred change should be reconciled again
+func (d *deferral) requeueAfter(now time.Time) time.Duration {
+	if d.notBefore.IsZero() {
+		if d.reason == ReasonChangeFreeze {
+			return freezeRecheckInterval
+		}
+		// Invalid windows need a spec change, which re-enqueues pending secrets
+		return 0
+	}
+	return d.notBefore.Sub(now)
+}
+
+// operatorNamespace returns the namespace the operator runs in
+func operatorNamespace() string {
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		namespace = "traktor-system" // fallback to default
+	}
+	return namespace
+}
+
+// changeFrozen reports whether restarts in the namespace are frozen, either by the
+// namespace itself or cluster-wide through the operator's namespace
+func (r *SecretsRefreshReconciler) changeFrozen(ctx context.Context, namespace string) (bool, error) {
+	for _, name := range []string{namespace, operatorNamespace()} {
+		ns := &corev1.Namespace{}
+		if err := r.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return false, err
+		}
+		if ns.Annotations[traktorv1beta1.FreezeAnnotation] == "true" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// deferralFor decides whether the restarts for a secret change have to wait for
+// the end of a change freeze or for a maintenance window. It returns nil when the
+// restarts may run now.
+func (r *SecretsRefreshReconciler) deferralFor(ctx context.Context, secret *corev1.Secret, policies []restartPolicy, now time.Time) (*deferral, error) {
+	logger := log.FromContext(ctx)
+
+	if secret.Annotations[traktorv1beta1.UrgentAnnotation] == "true" {
+		return nil, nil
+	}
+
+	frozen, err := r.changeFrozen(ctx, secret.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check change freeze: %w", err)
+	}
+	if frozen {
+		d := &deferral{reason: ReasonChangeFreeze}
+		for _, p := range policies {
+			d.secretsRefreshes = append(d.secretsRefreshes, p.secretsRefresh)
+		}
+		return d, nil
+	}
+
+	// Every SecretsRefresh with windows must be inside one of them
+	var d *deferral
+	for _, p := range policies {
+		sr := p.secretsRefresh
+		windows, err := policy.ParseWindows(sr.Spec.Rollout.MaintenanceWindows)
+		if err != nil {
+			logger.Error(err, "Invalid maintenance window", "secretsRefresh", sr.Name)
+			r.recordEvent(sr, corev1.EventTypeWarning, ReasonInvalidMaintenanceWindow, err.Error())
+			if d == nil {
+				d = &deferral{reason: ReasonInvalidMaintenanceWindow}
+			}
+			d.secretsRefreshes = append(d.secretsRefreshes, sr)
+			continue
+		}
+
+		open, next := windows.NextOpen(now)
+		if open {
+			continue
+		}
+		if d == nil {
+			d = &deferral{reason: ReasonOutsideMaintenanceWindow}
+		}
+		// Wait for the latest opening so every SecretsRefresh has a chance to be open
+		if next.After(d.notBefore) {
+			d.notBefore = next
+		}
+		d.secretsRefreshes = append(d.secretsRefreshes, sr)
+	}
+
+	return d, nil
+}
+
+// deferRestarts records the secret change as pending in the status of the affected SecretsRefresh objects
+func (r *SecretsRefreshReconciler) deferRestarts(ctx context.Context, d *deferral, secret *corev1.Secret, now time.Time) error {
+	pending := traktorv1beta1.PendingRestart{
+		SecretName:      secret.Name,
+		SecretNamespace: secret.Namespace,
+		DetectedAt:      metav1.NewTime(now),
+		Reason:          d.reason,
+	}
+	if !d.notBefore.IsZero() {
+		notBefore := metav1.NewTime(d.notBefore)
+		pending.NotBefore = &notBefore
+	}
+
+	for _, sr := range d.secretsRefreshes {
+		added, err := r.upsertPendingRestart(ctx, sr, pending)
+		if err != nil {
+			return err
+		}
+		if added {
+			r.recordEvent(sr, corev1.EventTypeNormal, "RestartDeferred",
+				fmt.Sprintf("Restarts for secret %s/%s deferred: %s", secret.Namespace, secret.Name, d.reason))
+		}
+	}
+	return nil
+}
+
+// upsertPendingRestart adds or replaces the pending restart of a secret, keeping
+// its original detection time. It reports whether a new entry was added.
+func (r *SecretsRefreshReconciler) upsertPendingRestart(ctx context.Context, sr *traktorv1beta1.SecretsRefresh, pending traktorv1beta1.PendingRestart) (bool, error) {
+	added := false
+	err := r.updateStatus(ctx, sr, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+		added = false
+		for i := range status.PendingRestarts {
+			existing := &status.PendingRestarts[i]
+			if existing.SecretName != pending.SecretName || existing.SecretNamespace != pending.SecretNamespace {
+				continue
+			}
+			pending.DetectedAt = existing.DetectedAt
+			if equality.Semantic.DeepEqual(*existing, pending) {
+				return false
+			}
+			*existing = pending
+			syncFlappingCondition(status, sr.Generation)
+			return true
+		}
+
+		status.PendingRestarts = append(status.PendingRestarts, pending)
+		syncFlappingCondition(status, sr.Generation)
+		added = true
+		return true
+	})
+	return added, err
+}
+
+// clearPendingRestarts removes the secret from the pending restarts of the given SecretsRefresh objects
+func (r *SecretsRefreshReconciler) clearPendingRestarts(ctx context.Context, policies []restartPolicy, secret *corev1.Secret) error {
+	for _, p := range policies {
+		err := r.updateStatus(ctx, p.secretsRefresh, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+			for i, pending := range status.PendingRestarts {
+				if pending.SecretName == secret.Name && pending.SecretNamespace == secret.Namespace {
+					status.PendingRestarts = append(status.PendingRestarts[:i], status.PendingRestarts[i+1:]...)
+					syncFlappingCondition(status, p.secretsRefresh.Generation)
+					return true
+				}
+			}
+			return false
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateStatus applies mutate to the latest version of the SecretsRefresh status and
+// writes it back, retrying on conflicts. mutate returns false when nothing changed.
+func (r *SecretsRefreshReconciler) updateStatus(ctx context.Context, sr *traktorv1beta1.SecretsRefresh, mutate func(*traktorv1beta1.SecretsRefreshStatus) bool) error {
+	return updateSecretsRefreshStatus(ctx, r.Client, sr, mutate)
+}
+
+// updateSecretsRefreshStatus implements updateStatus for every controller that writes
+// the SecretsRefresh status
+func updateSecretsRefreshStatus(ctx context.Context, c client.Client, sr *traktorv1beta1.SecretsRefresh, mutate func(*traktorv1beta1.SecretsRefreshStatus) bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &traktorv1beta1.SecretsRefresh{}
+		if err := getSecretsRefresh(ctx, c, client.ObjectKeyFromObject(sr), latest); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if !mutate(&latest.Status) {
+			return nil
+		}
+		if err := c.Status().Update(ctx, secretsRefreshObject(latest)); err != nil {
+			return err
+		}
+		pendingRestarts.set(client.ObjectKeyFromObject(sr), len(latest.Status.PendingRestarts))
+		return nil
+	})
+}
+
+// pendingSecretsForSecretsRefresh maps a SecretsRefresh to its pending secret changes,
+// so they are retried after an operator restart or a spec change. The requests look up
+// the matching SecretsRefresh objects again, as other ones may have been deferred as well.
+// The pending restarts of a suspended SecretsRefresh are kept until it is resumed.
+func (r *SecretsRefreshReconciler) pendingSecretsForSecretsRefresh(_ context.Context, sr *traktorv1beta1.SecretsRefresh) []SecretRequest {
+	if sr.Spec.Suspend {
+		return nil
+	}
+	requests := make([]SecretRequest, 0, len(sr.Status.PendingRestarts))
+	for _, pending := range sr.Status.PendingRestarts {
+		requests = append(requests, SecretRequest{
+			NamespacedName: client.ObjectKey{
+				Name:      pending.SecretName,
+				Namespace: pending.SecretNamespace,
+			},
+		})
+	}
+	return requests
+}
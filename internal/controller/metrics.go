@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// ReasonDisruptionSkipped is the blocked restarts reason for workloads left alone by
+// the Skip disruption policy
+const ReasonDisruptionSkipped = "DisruptionSkipped"
+
+// ReasonActionFailed is the blocked restarts reason for restarts blocked by a failed action
+const ReasonActionFailed = "ActionFailed"
+
+// Metrics of Traktor, served on the metrics endpoint of the manager next to the
+// controller-runtime metrics
+var (
+	secretChangesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "traktor_secret_changes_total",
+		Help: "Data changes of secrets selected by a SecretsRefresh",
+	}, []string{"namespace"})
+
+	restartsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "traktor_restarts_total",
+		Help: "Workload restarts by kind, namespace and result",
+	}, []string{"kind", "namespace", "result"})
+
+	restartDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "traktor_restart_duration_seconds",
+		Help:    "Time from restarting a workload until its rollout completed",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
+	pendingRestartsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "traktor_pending_restarts",
+		Help: "Secret changes waiting in status.pendingRestarts of all SecretsRefresh objects",
+	})
+
+	blockedRestartsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "traktor_blocked_restarts_total",
+		Help: "Restarts blocked or deferred by reason, every attempt counts",
+	}, []string{"reason"})
+
+	matchedSecretsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "traktor_matched_secrets",
+		Help: "Secrets selected by each SecretsRefresh and ClusterSecretsRefresh",
+	}, []string{"secretsrefresh"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		secretChangesTotal,
+		restartsTotal,
+		restartDurationSeconds,
+		pendingRestartsGauge,
+		blockedRestartsTotal,
+		matchedSecretsGauge,
+	)
+}
+
+// secretsRefreshMetricLabel is the secretsrefresh label of a SecretsRefresh, namespace/name,
+// or name for a ClusterSecretsRefresh
+func secretsRefreshMetricLabel(key types.NamespacedName) string {
+	if key.Namespace == "" {
+		return key.Name
+	}
+	return key.String()
+}
+
+// forgetSecretsRefreshMetrics drops the metrics of a deleted SecretsRefresh
+func forgetSecretsRefreshMetrics(key types.NamespacedName) {
+	matchedSecretsGauge.DeleteLabelValues(secretsRefreshMetricLabel(key))
+	pendingRestarts.forget(key)
+}
+
+// pendingRestartCounts sums the pending restarts of all SecretsRefresh objects for
+// traktor_pending_restarts, as each status write only knows its own object
+type pendingRestartCounts struct {
+	mu     sync.Mutex
+	counts map[types.NamespacedName]int
+}
+
+// pendingRestarts feeds traktor_pending_restarts
+var pendingRestarts = &pendingRestartCounts{counts: map[types.NamespacedName]int{}}
+
+// set records the number of pending restarts of the SecretsRefresh
+func (p *pendingRestartCounts) set(key types.NamespacedName, count int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counts[key] = count
+	p.publish()
+}
+
+// forget drops a deleted SecretsRefresh
+func (p *pendingRestartCounts) forget(key types.NamespacedName) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.counts, key)
+	p.publish()
+}
+
+// publish sets the gauge, the lock must be held
+func (p *pendingRestartCounts) publish() {
+	total := 0
+	for _, count := range p.counts {
+		total += count
+	}
+	pendingRestartsGauge.Set(float64(total))
+}
+
+// rolloutTimer remembers when workloads were restarted until their rollout completes
+type rolloutTimer struct {
+	mu      sync.Mutex
+	started map[types.NamespacedName]time.Time
+}
+
+// restartRollouts feeds traktor_restart_duration_seconds
+var restartRollouts = &rolloutTimer{started: map[types.NamespacedName]time.Time{}}
+
+// start records the restart of the workload
+func (t *rolloutTimer) start(key types.NamespacedName, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started[key] = at
+}
+
+// startedAt returns when the workload was restarted, if its rollout is still tracked
+func (t *rolloutTimer) startedAt(key types.NamespacedName) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	at, ok := t.started[key]
+	return at, ok
+}
+
+// forget stops tracking the rollout of the workload
+func (t *rolloutTimer) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.started, key)
+}
+
+// observeRestart counts a restart and stops timing its rollout if it failed
+func observeRestart(deployment *appsv1.Deployment, err error) {
+	result := "Succeeded"
+	if err != nil {
+		result = "Failed"
+		restartRollouts.forget(client.ObjectKeyFromObject(deployment))
+	}
+	restartsTotal.WithLabelValues("Deployment", deployment.Namespace, result).Inc()
+}
+
+// RestartDurationReconciler observes traktor_restart_duration_seconds once the rollout
+// of a restarted Deployment completes. Rollouts are tracked in memory, those in
+// progress while the operator restarts aren't observed.
+type RestartDurationReconciler struct {
+	client.Client
+}
+
+// Reconcile observes the duration of a completed rollout
+func (r *RestartDurationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	startedAt, ok := restartRollouts.startedAt(req.NamespacedName)
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, req.NamespacedName, deployment); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			restartRollouts.forget(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !rolloutComplete(deployment) {
+		return ctrl.Result{RequeueAfter: rolloutRecheckInterval}, nil
+	}
+
+	restartDurationSeconds.Observe(time.Since(startedAt).Seconds())
+	restartRollouts.forget(req.NamespacedName)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RestartDurationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	restarted := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := restartRollouts.startedAt(client.ObjectKeyFromObject(obj))
+		return ok
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appsv1.Deployment{}, builder.WithPredicates(restarted)).
+		Named("restartduration").
+		Complete(r)
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// storageMigrationRetryInterval is how long the storage version migration waits after a
+// failed attempt, e.g. while the conversion webhook isn't reachable yet
+const storageMigrationRetryInterval = time.Minute
+
+// migratedCRDs are the CRDs whose objects the StorageVersionMigrator rewrites
+var migratedCRDs = []struct {
+	name    string
+	newList func() client.ObjectList
+}{
+	{"secretsrefreshes." + traktorv1beta1.GroupVersion.Group, func() client.ObjectList { return &traktorv1beta1.SecretsRefreshList{} }},
+	{"clustersecretsrefreshes." + traktorv1beta1.GroupVersion.Group, func() client.ObjectList { return &traktorv1beta1.ClusterSecretsRefreshList{} }},
+}
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get,resourceNames=secretsrefreshes.traktor.gdxcloud.net;clustersecretsrefreshes.traktor.gdxcloud.net
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=get;update;patch,resourceNames=secretsrefreshes.traktor.gdxcloud.net;clustersecretsrefreshes.traktor.gdxcloud.net
+
+// StorageVersionMigrator rewrites every SecretsRefresh and ClusterSecretsRefresh once
+// the operator runs, so the API server stores all of them as v1beta1, and then removes
+// older versions from status.storedVersions of their CRDs. Once storedVersions only
+// lists v1beta1, v1alpha1 can be dropped from the CRDs.
+type StorageVersionMigrator struct {
+	Client client.Client
+	// APIReader reads the CRDs and objects without caching them
+	APIReader client.Reader
+}
+
+// NeedLeaderElection makes only the leader migrate
+func (m *StorageVersionMigrator) NeedLeaderElection() bool {
+	return true
+}
+
+// Start migrates until it succeeds, it never stops the operator
+func (m *StorageVersionMigrator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("storage-version-migration")
+	for {
+		err := m.Migrate(ctx)
+		if err == nil {
+			return nil
+		}
+		logger.Error(err, "Failed to migrate stored versions, retrying", "retryAfter", storageMigrationRetryInterval)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(storageMigrationRetryInterval):
+		}
+	}
+}
+
+// Migrate rewrites the objects of every CRD that still lists an older stored version
+func (m *StorageVersionMigrator) Migrate(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+	storageVersion := traktorv1beta1.GroupVersion.Version
+
+	for _, migrated := range migratedCRDs {
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := m.APIReader.Get(ctx, client.ObjectKey{Name: migrated.name}, crd); err != nil {
+			return fmt.Errorf("failed to get CRD %s: %w", migrated.name, err)
+		}
+		if slices.Equal(crd.Status.StoredVersions, []string{storageVersion}) {
+			continue
+		}
+
+		// Writing an object unchanged makes the API server store it in the storage version
+		list := migrated.newList()
+		if err := m.APIReader.List(ctx, list); err != nil {
+			return fmt.Errorf("failed to list %s: %w", migrated.name, err)
+		}
+		objects, err := objectsOf(list)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objects {
+			if err := m.rewrite(ctx, obj); err != nil {
+				return fmt.Errorf("failed to migrate %s %s: %w", migrated.name, client.ObjectKeyFromObject(obj), err)
+			}
+		}
+
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if err := m.APIReader.Get(ctx, client.ObjectKey{Name: migrated.name}, crd); err != nil {
+				return err
+			}
+			crd.Status.StoredVersions = []string{storageVersion}
+			return m.Client.Status().Update(ctx, crd)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update stored versions of CRD %s: %w", migrated.name, err)
+		}
+		logger.Info("Migrated stored versions", "crd", migrated.name, "objects", len(objects), "storedVersion", storageVersion)
+	}
+	return nil
+}
+
+// rewrite writes the object back unchanged, refetching it on conflicts
+func (m *StorageVersionMigrator) rewrite(ctx context.Context, obj client.Object) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := m.APIReader.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			return err
+		}
+		return m.Client.Update(ctx, obj)
+	})
+	return client.IgnoreNotFound(err)
+}
+
+// objectsOf returns the items of a SecretsRefresh or ClusterSecretsRefresh list
+func objectsOf(list client.ObjectList) ([]client.Object, error) {
+	var objects []client.Object
+	switch list := list.(type) {
+	case *traktorv1beta1.SecretsRefreshList:
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	case *traktorv1beta1.ClusterSecretsRefreshList:
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	default:
+		return nil, fmt.Errorf("unexpected list %T", list)
+	}
+	return objects, nil
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// ReasonNotificationFailed is the event reason for notifications a webhook didn't accept
+const ReasonNotificationFailed = "NotificationFailed"
+
+// notificationTimeout bounds how long a notification webhook may take to answer, so a
+// slow receiver doesn't hold up the notifications queued after it
+const notificationTimeout = 5 * time.Second
+
+// notificationQueueSize bounds the notifications waiting for delivery
+const notificationQueueSize = 100
+
+// notificationClient posts the notifications
+var notificationClient = &http.Client{Timeout: notificationTimeout}
+
+// Notifier posts notifications in the background, so a slow or unreachable webhook
+// never holds up restarts. Notifications that don't fit into its queue are dropped and
+// reported like failed ones.
+type Notifier struct {
+	Recorder record.EventRecorder
+
+	once  sync.Once
+	queue chan notificationDelivery
+}
+
+// notificationDelivery is a notification waiting to be posted to a webhook
+type notificationDelivery struct {
+	sr   *traktorv1beta1.SecretsRefresh
+	hook traktorv1beta1.NotificationWebhook
+	body []byte
+}
+
+func (n *Notifier) init() {
+	n.once.Do(func() {
+		n.queue = make(chan notificationDelivery, notificationQueueSize)
+	})
+}
+
+// Start delivers queued notifications until the context is cancelled
+func (n *Notifier) Start(ctx context.Context) error {
+	n.init()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d := <-n.queue:
+			n.deliver(ctx, d)
+		}
+	}
+}
+
+// enqueue queues the notification without blocking, it reports whether it fit
+func (n *Notifier) enqueue(d notificationDelivery) bool {
+	n.init()
+	select {
+	case n.queue <- d:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver posts a notification and reports a failure as event, it's never retried
+func (n *Notifier) deliver(ctx context.Context, d notificationDelivery) {
+	if err := postNotification(ctx, d.hook.URL, d.body); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to send notification", "secretsRefresh", d.sr.Name, "webhook", d.hook.Name)
+		n.reportFailure(d, err)
+	}
+}
+
+func (n *Notifier) reportFailure(d notificationDelivery, err error) {
+	if n.Recorder == nil {
+		return
+	}
+	n.Recorder.Event(secretsRefreshObject(d.sr), corev1.EventTypeWarning, ReasonNotificationFailed,
+		fmt.Sprintf("Notification webhook %s failed: %v", d.hook.Name, err))
+}
+
+// Notification is the JSON body posted to the notification webhooks of a SecretsRefresh
+type Notification struct {
+	// Kind is SecretsRefresh or ClusterSecretsRefresh
+	Kind string `json:"kind"`
+	// Name is the name of the SecretsRefresh
+	Name string `json:"name"`
+	// Namespace is the namespace of the SecretsRefresh, empty for a ClusterSecretsRefresh
+	Namespace string `json:"namespace,omitempty"`
+	// Refresh is the refresh as recorded in status.refreshHistory
+	Refresh traktorv1beta1.RefreshRecord `json:"refresh"`
+}
+
+// notify queues the refresh for the notification webhooks of a ClusterSecretsRefresh and
+// the notifiers of the TraktorConfig whose result filter matches. Failures are reported
+// as events and never retried, the refresh itself has already happened.
+func (r *SecretsRefreshReconciler) notify(ctx context.Context, sr *traktorv1beta1.SecretsRefresh, record traktorv1beta1.RefreshRecord) {
+	logger := log.FromContext(ctx)
+	if r.Notifier == nil {
+		return
+	}
+
+	// Namespaced SecretsRefresh objects may not choose where the operator posts to, the
+	// webhook rejects their notifications but it may be disabled
+	var hooks []traktorv1beta1.NotificationWebhook
+	if sr.Spec.Notifications != nil && isClusterSecretsRefresh(sr) {
+		hooks = sr.Spec.Notifications.Webhooks
+	}
+	hooks = append(slices.Clone(hooks), r.Config.notifiers()...)
+	if len(hooks) == 0 {
+		return
+	}
+
+	notification := Notification{
+		Kind:      "SecretsRefresh",
+		Name:      sr.Name,
+		Namespace: sr.Namespace,
+		Refresh:   record,
+	}
+	if isClusterSecretsRefresh(sr) {
+		notification.Kind = "ClusterSecretsRefresh"
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		logger.Error(err, "Failed to encode notification", "secretsRefresh", sr.Name)
+		return
+	}
+
+	for _, hook := range hooks {
+		if len(hook.Results) > 0 && !slices.Contains(hook.Results, record.Result) {
+			continue
+		}
+		d := notificationDelivery{sr: sr.DeepCopy(), hook: hook, body: body}
+		if !r.Notifier.enqueue(d) {
+			logger.Info("Notification queue full, dropping notification", "secretsRefresh", sr.Name, "webhook", hook.Name)
+			r.Notifier.reportFailure(d, errors.New("notification queue is full"))
+		}
+	}
+}
+
+// postNotification posts the JSON body to the URL and expects a 2xx answer
+func postNotification(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := notificationClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// ReasonLowerPriority is the reason of the Conflicting condition of a SecretsRefresh
+// overridden by one with a higher priority
+const ReasonLowerPriority = "LowerPriority"
+
+// When several SecretsRefresh objects select a secret, only those with the highest
+// spec.priority govern it, the others are overridden. Namespaced SecretsRefresh objects
+// belong to tenants and must not switch off the guardrails of the cluster, so the
+// ClusterSecretsRefresh objects with the highest priority among them always govern as
+// well, whatever priority a namespaced one sets. The policies of the governing ones are
+// merged: one restartWhen allowing the restart is enough, the most conservative
+// disruption policy and the longest cooldown apply, and a single one in dry run or
+// requiring approval decides for all. Tenants can't loosen the guardrails of a governing
+// ClusterSecretsRefresh though: one of the ClusterSecretsRefresh objects must allow the
+// restart as well, and only they decide about dry run. Settings only one of them can
+// provide, like the restart strategy, come from the first one in precedence order.
+
+// sortByPrecedence sorts SecretsRefresh objects by descending priority, then
+// ClusterSecretsRefresh objects before SecretsRefresh objects and by namespace and
+// name, so merged policies are applied in the same order every time
+func sortByPrecedence(srs []traktorv1beta1.SecretsRefresh) {
+	slices.SortStableFunc(srs, func(a, b traktorv1beta1.SecretsRefresh) int {
+		return cmp.Or(
+			cmp.Compare(b.Spec.Priority, a.Spec.Priority),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+}
+
+// splitByPrecedence returns the SecretsRefresh objects governing a secret they all
+// select, those with the highest priority together with the highest priority
+// ClusterSecretsRefresh objects, and the overridden ones
+func splitByPrecedence(srs []traktorv1beta1.SecretsRefresh) ([]traktorv1beta1.SecretsRefresh, []traktorv1beta1.SecretsRefresh) {
+	sortByPrecedence(srs)
+	if len(srs) == 0 {
+		return nil, nil
+	}
+	highest := srs[0].Spec.Priority
+	clusterIndex := slices.IndexFunc(srs, func(sr traktorv1beta1.SecretsRefresh) bool {
+		return isClusterSecretsRefresh(&sr)
+	})
+
+	var governing, overridden []traktorv1beta1.SecretsRefresh
+	for i := range srs {
+		priority := srs[i].Spec.Priority
+		if priority == highest || (clusterIndex >= 0 && isClusterSecretsRefresh(&srs[i]) && priority == srs[clusterIndex].Spec.Priority) {
+			governing = append(governing, srs[i])
+		} else {
+			overridden = append(overridden, srs[i])
+		}
+	}
+	return governing, overridden
+}
+
+// overrides reports whether the SecretsRefresh takes precedence over the other one for
+// the secrets both select. Only a ClusterSecretsRefresh overrides a ClusterSecretsRefresh.
+func overrides(sr, other *traktorv1beta1.SecretsRefresh) bool {
+	if isClusterSecretsRefresh(other) && !isClusterSecretsRefresh(sr) {
+		return false
+	}
+	return sr.Spec.Priority > other.Spec.Priority
+}
+
+// secretsRefreshReference returns the reference to the SecretsRefresh recorded in status
+func secretsRefreshReference(sr *traktorv1beta1.SecretsRefresh) traktorv1beta1.SecretsRefreshReference {
+	if isClusterSecretsRefresh(sr) {
+		return traktorv1beta1.SecretsRefreshReference{Kind: "ClusterSecretsRefresh", Name: sr.Name}
+	}
+	return traktorv1beta1.SecretsRefreshReference{Kind: "SecretsRefresh", Name: sr.Name, Namespace: sr.Namespace}
+}
+
+// governingReferences returns the references to the SecretsRefresh objects of the policies
+func governingReferences(policies []restartPolicy) []traktorv1beta1.SecretsRefreshReference {
+	refs := make([]traktorv1beta1.SecretsRefreshReference, 0, len(policies))
+	for _, p := range policies {
+		refs = append(refs, secretsRefreshReference(p.secretsRefresh))
+	}
+	return refs
+}
+
+// formatReferences describes SecretsRefresh references for condition messages
+func formatReferences(refs []traktorv1beta1.SecretsRefreshReference) string {
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if ref.Namespace == "" {
+			names = append(names, ref.Kind+" "+ref.Name)
+			continue
+		}
+		names = append(names, ref.Kind+" "+ref.Namespace+"/"+ref.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// secretMatcher matches secrets against the namespace and secret selectors of a
+// SecretsRefresh, resolving its namespaces only once
+type secretMatcher struct {
+	namespaces map[string]bool
+	selector   labels.Selector
+}
+
+// newSecretMatcher returns the secretMatcher of the SecretsRefresh
+func newSecretMatcher(ctx context.Context, c client.Reader, sr *traktorv1beta1.SecretsRefresh) (*secretMatcher, error) {
+	namespaces, err := filteredNamespaces(ctx, c, sr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filtered namespaces: %w", err)
+	}
+
+	m := &secretMatcher{namespaces: make(map[string]bool, len(namespaces)), selector: labels.Everything()}
+	for _, ns := range namespaces {
+		m.namespaces[ns.Name] = true
+	}
+	if sr.Spec.Targets.SecretSelector != nil {
+		if m.selector, err = metav1.LabelSelectorAsSelector(sr.Spec.Targets.SecretSelector); err != nil {
+			return nil, fmt.Errorf("invalid secret selector: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// matches reports whether the secret is selected
+func (m *secretMatcher) matches(secret client.Object) bool {
+	return m.namespaces[secret.GetNamespace()] && m.selector.Matches(labels.Set(secret.GetLabels()))
+}
+
+// overridingSecretsRefreshes returns the SecretsRefresh objects overriding the
+// SecretsRefresh that select some of the secrets the SecretsRefresh selects, and how many of its
+// secrets they govern
+func overridingSecretsRefreshes(ctx context.Context, c client.Reader, sr *traktorv1beta1.SecretsRefresh) ([]traktorv1beta1.SecretsRefreshReference, int, error) {
+	srs, err := listSecretsRefreshes(ctx, c)
+	if err != nil {
+		return nil, 0, err
+	}
+	sortByPrecedence(srs)
+
+	var higher []*traktorv1beta1.SecretsRefresh
+	var matchers []*secretMatcher
+	for i := range srs {
+		other := &srs[i]
+		if !overrides(other, sr) || other.Spec.Suspend || !other.DeletionTimestamp.IsZero() {
+			continue
+		}
+		m, err := newSecretMatcher(ctx, c, other)
+		if err != nil {
+			// Invalid selectors are reported on the other SecretsRefresh, it matches nothing
+			continue
+		}
+		higher = append(higher, other)
+		matchers = append(matchers, m)
+	}
+	if len(higher) == 0 {
+		return nil, 0, nil
+	}
+
+	secrets, err := selectedSecrets(ctx, c, sr)
+	if err != nil {
+		return nil, 0, err
+	}
+	overriding := make([]bool, len(higher))
+	count := 0
+	for i := range secrets {
+		overridden := false
+		for j, m := range matchers {
+			if m.matches(&secrets[i]) {
+				overriding[j] = true
+				overridden = true
+			}
+		}
+		if overridden {
+			count++
+		}
+	}
+
+	var refs []traktorv1beta1.SecretsRefreshReference
+	for j, other := range higher {
+		if overriding[j] {
+			refs = append(refs, secretsRefreshReference(other))
+		}
+	}
+	return refs, count, nil
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// ReasonProtectedWorkload is the event reason for restarts skipped because the
+// workload is protected by the TraktorConfig
+const ReasonProtectedWorkload = "ProtectedWorkload"
+
+// ProtectedWorkloadError is returned when a restart of a protected workload is attempted
+type ProtectedWorkloadError struct {
+	// Reason describes why the workload is protected
+	Reason string
+}
+
+func (e *ProtectedWorkloadError) Error() string {
+	return "workload is protected: " + e.Reason
+}
+
+// protection returns why the workload must never be restarted, empty if it may be.
+// The operator's own namespace is always protected to prevent restarting itself.
+func (c *OperatorConfig) protection(kind string, workload metav1.Object) string {
+	namespace := workload.GetNamespace()
+	if namespace == operatorNamespace() {
+		return fmt.Sprintf("namespace %s runs the operator", namespace)
+	}
+	protected := c.get().Protected
+	if slices.Contains(protected.Namespaces, namespace) {
+		return fmt.Sprintf("namespace %s is protected", namespace)
+	}
+	for _, ref := range protected.Workloads {
+		if ref.Kind == kind && ref.Name == workload.GetName() && ref.Namespace == namespace {
+			return fmt.Sprintf("%s %s/%s is protected", kind, namespace, ref.Name)
+		}
+	}
+	if protected.Selector != nil {
+		// The selector was validated before the configuration was applied
+		selector, err := metav1.LabelSelectorAsSelector(protected.Selector)
+		if err == nil && selector.Matches(labels.Set(workload.GetLabels())) {
+			return fmt.Sprintf("labels match the protected selector %s", selector)
+		}
+	}
+	return ""
+}
+
+// skipProtected reports whether the Deployment is protected. Skipped restarts are
+// reported as events on the SecretsRefresh objects whose policies selected it, so
+// their owners see why the workload isn't restarted.
+func (r *SecretsRefreshReconciler) skipProtected(ctx context.Context, policies []restartPolicy, deployment *appsv1.Deployment, secret *corev1.Secret) bool {
+	reason := r.Config.protection("Deployment", deployment)
+	if reason == "" {
+		return false
+	}
+	log.FromContext(ctx).Info("Restart skipped, deployment is protected",
+		"deployment", deployment.Name,
+		"namespace", deployment.Namespace,
+		"reason", reason)
+	blockedRestartsTotal.WithLabelValues(ReasonProtectedWorkload).Inc()
+
+	// The operator's own namespace is skipped silently, it never selects anything useful
+	if deployment.Namespace == operatorNamespace() {
+		return true
+	}
+	message := fmt.Sprintf("Restart of Deployment %s/%s for Secret %s skipped: %s",
+		deployment.Namespace, deployment.Name, secret.Name, reason)
+	for _, p := range policies {
+		r.recordEvent(secretsRefreshObject(p.secretsRefresh), corev1.EventTypeWarning, ReasonProtectedWorkload, message)
+	}
+	return true
+}
+
+// validateProtection returns the problems of the protected workloads
+func validateProtection(protected *traktorv1beta1.Protection) []string {
+	var problems []string
+	for _, namespace := range protected.Namespaces {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			problems = append(problems, fmt.Sprintf("protected namespace %q: %s", namespace, strings.Join(errs, ", ")))
+		}
+	}
+	for _, ref := range protected.Workloads {
+		if ref.Kind != "Deployment" {
+			problems = append(problems, fmt.Sprintf("protected workload %s/%s: unsupported kind %q", ref.Namespace, ref.Name, ref.Kind))
+		}
+		if ref.Namespace == "" {
+			problems = append(problems, fmt.Sprintf("protected workload %s: namespace is required", ref.Name))
+		}
+	}
+	if protected.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(protected.Selector); err != nil {
+			problems = append(problems, fmt.Sprintf("protected selector: %v", err))
+		}
+	}
+	return problems
+}
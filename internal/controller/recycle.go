@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// recycleInterval is how often a Deployment is checked while its pods are recycled or bounced
+const recycleInterval = 10 * time.Second
+
+// PodRecycleReconciler drives the restart strategies that take more than one step:
+// it evicts the pods of Deployments marked by DeletePods one at a time and scales
+// Deployments bounced by ScaleBounce back up
+type PodRecycleReconciler struct {
+	client.Client
+	// APIReader lists pods without caching every pod of the cluster
+	APIReader client.Reader
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+
+// Reconcile advances the pod recycling or scale bounce of a Deployment
+func (r *PodRecycleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, req.NamespacedName, deployment); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if raw, ok := deployment.Annotations[traktorv1beta1.ScaleBounceReplicasAnnotation]; ok {
+		return r.finishScaleBounce(ctx, deployment, raw)
+	}
+	if raw, ok := deployment.Annotations[traktorv1beta1.RecycleBeforeAnnotation]; ok {
+		return r.recyclePods(ctx, deployment, raw)
+	}
+	return ctrl.Result{}, nil
+}
+
+// finishScaleBounce scales the Deployment back up once all of its pods are gone
+func (r *PodRecycleReconciler) finishScaleBounce(ctx context.Context, deployment *appsv1.Deployment, raw string) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	replicas, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil || deploymentReplicas(deployment) != 0 {
+		// Someone else scaled the Deployment in the meantime, leave it to them
+		return ctrl.Result{}, r.removeAnnotation(ctx, deployment, traktorv1beta1.ScaleBounceReplicasAnnotation, nil)
+	}
+
+	if deployment.Status.ObservedGeneration < deployment.Generation || deployment.Status.Replicas > 0 {
+		return ctrl.Result{RequeueAfter: recycleInterval}, nil
+	}
+
+	if err := r.removeAnnotation(ctx, deployment, traktorv1beta1.ScaleBounceReplicasAnnotation, func(d *appsv1.Deployment) {
+		count := int32(replicas)
+		d.Spec.Replicas = &count
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("Scaled deployment back up after restart",
+		"deployment", deployment.Name,
+		"namespace", deployment.Namespace,
+		"replicas", replicas)
+	return ctrl.Result{}, nil
+}
+
+// recyclePods evicts the oldest pod created before the recycle time whenever the
+// Deployment is fully available, until no such pod is left
+func (r *PodRecycleReconciler) recyclePods(ctx context.Context, deployment *appsv1.Deployment, raw string) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	before, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		logger.Error(err, "Invalid recycle annotation", "deployment", deployment.Name, "namespace", deployment.Namespace)
+		return ctrl.Result{}, r.removeAnnotation(ctx, deployment, traktorv1beta1.RecycleBeforeAnnotation, nil)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	pods := &corev1.PodList{}
+	if err := r.APIReader.List(ctx, pods, client.InNamespace(deployment.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var old []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			// Wait for the previous eviction to finish
+			return ctrl.Result{RequeueAfter: recycleInterval}, nil
+		}
+		if pod.CreationTimestamp.Time.Before(before) {
+			old = append(old, pod)
+		}
+	}
+
+	if len(old) == 0 {
+		logger.Info("Recycled all pods of deployment", "deployment", deployment.Name, "namespace", deployment.Namespace)
+		return ctrl.Result{}, r.removeAnnotation(ctx, deployment, traktorv1beta1.RecycleBeforeAnnotation, nil)
+	}
+
+	// Respect readiness: only take a pod away while every replica is available
+	status := deployment.Status
+	if status.ObservedGeneration < deployment.Generation || status.AvailableReplicas < deploymentReplicas(deployment) {
+		return ctrl.Result{RequeueAfter: recycleInterval}, nil
+	}
+
+	sort.Slice(old, func(i, j int) bool {
+		return old[i].CreationTimestamp.Before(&old[j].CreationTimestamp)
+	})
+	pod := &old[0]
+	eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+	if err := r.SubResource("eviction").Create(ctx, pod, eviction); err != nil {
+		if apierrors.IsTooManyRequests(err) {
+			// Blocked by a PodDisruptionBudget
+			logger.Info("Pod eviction blocked, retrying", "pod", pod.Name, "namespace", pod.Namespace)
+			return ctrl.Result{RequeueAfter: recycleInterval}, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+
+	logger.Info("Evicted pod to pick up changed secret", "pod", pod.Name, "namespace", pod.Namespace)
+	return ctrl.Result{RequeueAfter: recycleInterval}, nil
+}
+
+// removeAnnotation removes a Traktor annotation from the Deployment, applying an optional
+// spec mutation in the same patch
+func (r *PodRecycleReconciler) removeAnnotation(ctx context.Context, deployment *appsv1.Deployment, annotation string, mutate func(*appsv1.Deployment)) error {
+	patched := deployment.DeepCopy()
+	delete(patched.Annotations, annotation)
+	if mutate != nil {
+		mutate(patched)
+	}
+	return client.IgnoreNotFound(r.Patch(ctx, patched, client.MergeFrom(deployment)))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PodRecycleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	inProgress := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		annotations := obj.GetAnnotations()
+		_, recycling := annotations[traktorv1beta1.RecycleBeforeAnnotation]
+		_, bouncing := annotations[traktorv1beta1.ScaleBounceReplicasAnnotation]
+		return recycling || bouncing
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appsv1.Deployment{}, builder.WithPredicates(inProgress)).
+		Named("podrecycle").
+		Complete(r)
+}
@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// refreshNowToken returns the refresh-now token of the new object if it was set or
+// changed by the update, empty otherwise
+func refreshNowToken(oldObj, newObj client.Object) string {
+	token := newObj.GetAnnotations()[traktorv1beta1.RefreshNowAnnotation]
+	if token == oldObj.GetAnnotations()[traktorv1beta1.RefreshNowAnnotation] {
+		return ""
+	}
+	return token
+}
+
+// findRefreshNowForSecret maps a Secret whose refresh-now token changed to a manual
+// refresh of its consumers, none if no SecretsRefresh watches it
+func (r *SecretsRefreshReconciler) findRefreshNowForSecret(ctx context.Context, oldSecret, newSecret *corev1.Secret) []SecretRequest {
+	token := refreshNowToken(oldSecret, newSecret)
+	if token == "" {
+		return nil
+	}
+	requests := r.findSecretsRefreshForSecret(ctx, newSecret)
+	for i := range requests {
+		requests[i].RefreshNow = token
+	}
+	return requests
+}
+
+// requestsForSecretsRefresh maps a SecretsRefresh to its pending secret changes and
+// to a manual refresh of all its secrets if a new refresh-now token was set on it
+func (r *SecretsRefreshReconciler) requestsForSecretsRefresh(ctx context.Context, sr *traktorv1beta1.SecretsRefresh) []SecretRequest {
+	requests := r.pendingSecretsForSecretsRefresh(ctx, sr)
+
+	token := sr.Annotations[traktorv1beta1.RefreshNowAnnotation]
+	if token != "" && token != sr.Status.LastRefreshNow && !sr.Spec.Suspend && sr.DeletionTimestamp.IsZero() {
+		requests = append(requests, SecretRequest{
+			SecretsRefreshes: client.ObjectKeyFromObject(sr).String(),
+			RefreshNow:       token,
+		})
+	}
+	return requests
+}
+
+// refreshNow restarts the consumers of a secret, or of every secret of a SecretsRefresh,
+// once for the refresh-now token of the request. Manual refreshes are explicit, so
+// maintenance windows, cooldowns, restartWhen, approval and actions don't apply to
+// them. Suspended SecretsRefresh objects and the disruption policy are still honoured.
+func (r *SecretsRefreshReconciler) refreshNow(ctx context.Context, req SecretRequest) error {
+	if req.Name == "" {
+		return r.refreshSecretsRefreshNow(ctx, req)
+	}
+	return r.refreshSecretNow(ctx, req)
+}
+
+// refreshSecretNow handles a refresh-now token set on a secret
+func (r *SecretsRefreshReconciler) refreshSecretNow(ctx context.Context, req SecretRequest) error {
+	logger := log.FromContext(ctx)
+
+	if req.Namespace == operatorNamespace() {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, req.NamespacedName, secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	// A newer token supersedes this request
+	if secret.Annotations[traktorv1beta1.RefreshNowAnnotation] != req.RefreshNow {
+		return nil
+	}
+
+	policies, overridden, err := r.restartPoliciesForSecret(ctx, req, secret)
+	if err != nil {
+		return err
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+
+	logger.Info("Refresh requested on secret",
+		"secret", secret.Name,
+		"namespace", secret.Namespace,
+		"token", req.RefreshNow)
+
+	restarted, problems, err := r.restartConsumers(ctx, policies, secret, req.RefreshNow, map[client.ObjectKey]bool{})
+	if err != nil {
+		return err
+	}
+	return r.recordRefreshNow(ctx, policies, overridden, secret, restarted, problems, req.RefreshNow)
+}
+
+// refreshSecretsRefreshNow handles a refresh-now token set on a SecretsRefresh
+func (r *SecretsRefreshReconciler) refreshSecretsRefreshNow(ctx context.Context, req SecretRequest) error {
+	logger := log.FromContext(ctx)
+
+	keys := req.secretsRefreshKeys()
+	if len(keys) != 1 {
+		return nil
+	}
+	sr := &traktorv1beta1.SecretsRefresh{}
+	if err := getSecretsRefresh(ctx, r.Client, keys[0], sr); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if sr.Spec.Suspend || !sr.DeletionTimestamp.IsZero() ||
+		sr.Annotations[traktorv1beta1.RefreshNowAnnotation] != req.RefreshNow ||
+		sr.Status.LastRefreshNow == req.RefreshNow {
+		return nil
+	}
+
+	secrets, err := selectedSecrets(ctx, r.Client, sr)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Refresh requested on SecretsRefresh",
+		"secretsRefresh", sr.Name,
+		"namespace", sr.Namespace,
+		"token", req.RefreshNow,
+		"secrets", len(secrets))
+
+	// Deployments consuming several of the secrets restart only once
+	policies := []restartPolicy{{secretsRefresh: sr}}
+	done := map[client.ObjectKey]bool{}
+	for i := range secrets {
+		secret := &secrets[i]
+		restarted, problems, err := r.restartConsumers(ctx, policies, secret, req.RefreshNow, done)
+		if err != nil {
+			return err
+		}
+		if len(restarted) == 0 && len(problems) == 0 {
+			continue
+		}
+		if err := r.recordRefreshNow(ctx, policies, nil, secret, restarted, problems, req.RefreshNow); err != nil {
+			return err
+		}
+	}
+
+	// Echo the token, so it isn't handled again
+	return r.updateStatus(ctx, sr, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+		if status.LastRefreshNow == req.RefreshNow {
+			return false
+		}
+		status.LastRefreshNow = req.RefreshNow
+		return true
+	})
+}
+
+// restartConsumers restarts the Deployments in the namespace of the secret that
+// reference it and weren't restarted for the token yet. Restarted Deployments are
+// added to done and remember the token, so a retried request doesn't restart them again.
+func (r *SecretsRefreshReconciler) restartConsumers(ctx context.Context, policies []restartPolicy, secret *corev1.Secret, token string, done map[client.ObjectKey]bool) ([]*appsv1.Deployment, []string, error) {
+	logger := log.FromContext(ctx)
+
+	if !r.Config.workloadKindEnabled("Deployment") {
+		return nil, nil, nil
+	}
+
+	deploymentList := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deploymentList, client.InNamespace(secret.Namespace)); err != nil {
+		return nil, nil, err
+	}
+
+	disruptionPolicy := disruptionPolicyFor(policies)
+	dryRun, dryRunSRs := r.dryRunSecretsRefreshes(policies)
+	now := time.Now()
+
+	var restarted []*appsv1.Deployment
+	var problems []string
+	for i := range deploymentList.Items {
+		deployment := &deploymentList.Items[i]
+		key := client.ObjectKeyFromObject(deployment)
+		if done[key] || deployment.Annotations[traktorv1beta1.RefreshedNowAnnotation] == token {
+			continue
+		}
+		if len(r.deploymentUsesSecret(deployment, secret.Name)) == 0 {
+			continue
+		}
+		if r.skipProtected(ctx, policies, deployment, secret) {
+			continue
+		}
+
+		// Nothing runs to restart, and a paused Deployment would only roll out on resume
+		if deploymentReplicas(deployment) == 0 || deployment.Spec.Paused {
+			continue
+		}
+
+		strategyType, _ := r.restartStrategyFor(deployment, policies, secretReferences(deployment, secret.Name))
+		if risk := disruptionRisk(deployment, strategyType); risk != "" && disruptionPolicy == traktorv1beta1.DisruptionPolicySkip {
+			r.recordEvent(deployment, corev1.EventTypeWarning, "RestartSkipped",
+				fmt.Sprintf("Refresh of Secret %s skipped by disruption policy: %s", secret.Name, risk))
+			blockedRestartsTotal.WithLabelValues(ReasonDisruptionSkipped).Inc()
+			continue
+		}
+
+		done[key] = true
+		if dryRun {
+			if err := r.recordDryRunRestart(ctx, dryRunSRs, deployment, secret, nil, now); err != nil {
+				logger.Error(err, "Failed to record dry run restart",
+					"deployment", deployment.Name,
+					"namespace", deployment.Namespace)
+			}
+			continue
+		}
+
+		if err := r.restartDeployment(ctx, deployment, policies, secret, nil, disruptionPolicy); err != nil {
+			logger.Error(err, "Failed to refresh deployment",
+				"deployment", deployment.Name,
+				"namespace", deployment.Namespace)
+			problems = append(problems, fmt.Sprintf("Deployment %s: %v", deployment.Name, err))
+			continue
+		}
+		if err := patchDeploymentAnnotations(ctx, r.Client, deployment,
+			map[string]string{traktorv1beta1.RefreshedNowAnnotation: token}, nil); err != nil {
+			return nil, nil, err
+		}
+
+		logger.Info("Deployment refreshed",
+			"deployment", deployment.Name,
+			"namespace", deployment.Namespace,
+			"token", token)
+		restarted = append(restarted, deployment)
+	}
+
+	return restarted, problems, nil
+}
+
+// recordRefreshNow records a manual refresh in the refresh history, dry runs are
+// only recorded as dry run restarts
+func (r *SecretsRefreshReconciler) recordRefreshNow(ctx context.Context, policies []restartPolicy, overridden []*traktorv1beta1.SecretsRefresh, secret *corev1.Secret, restarted []*appsv1.Deployment, problems []string, token string) error {
+	if dryRun, _ := r.dryRunSecretsRefreshes(policies); dryRun {
+		return nil
+	}
+	result := traktorv1beta1.RefreshResultSucceeded
+	if len(problems) > 0 {
+		result = traktorv1beta1.RefreshResultFailed
+	}
+	return r.recordRefresh(ctx, policies, overridden, secret, restarted, problems, result, token, time.Now())
+}
+
+// selectedSecrets returns the secrets selected by the SecretsRefresh, outside of the
+// operator namespace
+func selectedSecrets(ctx context.Context, c client.Reader, sr *traktorv1beta1.SecretsRefresh) ([]corev1.Secret, error) {
+	namespaces, err := filteredNamespaces(ctx, c, sr)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := labels.Everything()
+	if sr.Spec.Targets.SecretSelector != nil {
+		selector, err = metav1.LabelSelectorAsSelector(sr.Spec.Targets.SecretSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret selector: %w", err)
+		}
+	}
+
+	var secrets []corev1.Secret
+	for _, ns := range namespaces {
+		if ns.Name == operatorNamespace() {
+			continue
+		}
+		secretList := &corev1.SecretList{}
+		if err := c.List(ctx, secretList, client.InNamespace(ns.Name), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secretList.Items...)
+	}
+	return secrets, nil
+}
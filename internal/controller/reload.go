@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// restartStrategyReload calls the reload URL of a workload instead of restarting it.
+// It is chosen by annotation only and therefore not part of the API.
+const restartStrategyReload traktorv1beta1.RestartStrategyType = "Reload"
+
+// kubeletSyncDelay is how long the kubelet may take to update a mounted secret:
+// its sync period of one minute plus the cache propagation delay
+const kubeletSyncDelay = 90 * time.Second
+
+// reloadTimeout bounds a single reload request
+const reloadTimeout = 10 * time.Second
+
+// reloadBackoff retries failed reload requests before falling back to a restart
+var reloadBackoff = wait.Backoff{
+	Steps:    3,
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+}
+
+// reloadable reports whether the Deployment can reload the secret instead of being
+// restarted: it has a reload URL and only mounts the secret, so the kubelet updates
+// the files in place
+func reloadable(deployment *appsv1.Deployment, references []string) bool {
+	if deployment.Annotations[traktorv1beta1.ReloadURLAnnotation] == "" || len(references) == 0 {
+		return false
+	}
+	for _, reference := range references {
+		if reference != ReferenceVolume {
+			return false
+		}
+	}
+	return true
+}
+
+// reloadStrategy records the secret version on the Deployment and marks it for the
+// ReloadReconciler, which calls the reload URL once the kubelet has synced the secret
+type reloadStrategy struct {
+	client client.Client
+}
+
+func (s *reloadStrategy) Restart(ctx context.Context, deployment *appsv1.Deployment, secret *corev1.Secret) error {
+	versions, err := secretVersionsAnnotation(deployment.Annotations, secret)
+	if err != nil {
+		return err
+	}
+	return patchDeploymentAnnotations(ctx, s.client, deployment, map[string]string{
+		traktorv1beta1.SecretVersionsAnnotation: versions,
+		traktorv1beta1.ReloadAfterAnnotation:    time.Now().Add(kubeletSyncDelay).Format(time.RFC3339),
+	}, nil)
+}
+
+// ReloadReconciler calls the reload URL of every ready pod of Deployments marked by
+// the reload strategy and restarts them when the reload fails
+type ReloadReconciler struct {
+	client.Client
+	// APIReader lists pods without caching every pod of the cluster
+	APIReader client.Reader
+	Recorder  record.EventRecorder
+	// HTTPClient sends the reload requests, a client with reloadTimeout when nil
+	HTTPClient *http.Client
+}
+
+// Reconcile reloads the pods of a Deployment once the kubelet sync delay has passed
+func (r *ReloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, req.NamespacedName, deployment); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	raw, ok := deployment.Annotations[traktorv1beta1.ReloadAfterAnnotation]
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+	if after, err := time.Parse(time.RFC3339, raw); err == nil {
+		if wait := time.Until(after); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	if err := r.reloadPods(ctx, deployment); err != nil {
+		logger.Error(err, "Reload failed, restarting deployment instead",
+			"deployment", deployment.Name,
+			"namespace", deployment.Namespace)
+		r.recordEvent(deployment, corev1.EventTypeWarning, "ReloadFailed",
+			fmt.Sprintf("Reload of Deployment %s/%s failed, restarting it instead: %v", deployment.Namespace, deployment.Name, err))
+		return ctrl.Result{}, r.fallbackRestart(ctx, deployment)
+	}
+
+	logger.Info("Reloaded deployment to pick up changed secret",
+		"deployment", deployment.Name,
+		"namespace", deployment.Namespace)
+	r.recordEvent(deployment, corev1.EventTypeNormal, "Reloaded",
+		fmt.Sprintf("Called the reload URL of Deployment %s/%s", deployment.Namespace, deployment.Name))
+	return ctrl.Result{}, r.finishReload(ctx, deployment, nil)
+}
+
+// reloadPods POSTs to the reload URL of every ready pod of the Deployment. It fails
+// when there is no ready pod, so the Deployment is restarted instead.
+func (r *ReloadReconciler) reloadPods(ctx context.Context, deployment *appsv1.Deployment) error {
+	target, err := url.Parse(deployment.Annotations[traktorv1beta1.ReloadURLAnnotation])
+	if err != nil || target.Scheme == "" {
+		return fmt.Errorf("invalid reload URL %q", deployment.Annotations[traktorv1beta1.ReloadURLAnnotation])
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	pods := &corev1.PodList{}
+	if err := r.APIReader.List(ctx, pods, client.InNamespace(deployment.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+
+	var errs []error
+	reloaded := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || pod.Status.PodIP == "" || !podReady(pod) {
+			continue
+		}
+		reloaded++
+		podURL := *target
+		podURL.Host = pod.Status.PodIP
+		if port := target.Port(); port != "" {
+			podURL.Host = net.JoinHostPort(pod.Status.PodIP, port)
+		}
+
+		err := retry.OnError(reloadBackoff, func(error) bool { return ctx.Err() == nil }, func() error {
+			return r.reload(ctx, podURL.String())
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pod %s: %w", pod.Name, err))
+		}
+	}
+	if reloaded == 0 {
+		// Nothing picked up the change, the secret version must not be recorded as reloaded
+		return errors.New("no ready pod to reload")
+	}
+	return errors.Join(errs...)
+}
+
+// reload sends a single reload request
+func (r *ReloadReconciler) reload(ctx context.Context, target string) error {
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: reloadTimeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("reload returned %s", resp.Status)
+	}
+	return nil
+}
+
+// fallbackRestart restarts the Deployment through its pod template, recording the
+// secret versions the reload was meant to deliver
+func (r *ReloadReconciler) fallbackRestart(ctx context.Context, deployment *appsv1.Deployment) error {
+	versions := secretVersions(deployment)
+	for name, version := range stampedVersions(deployment.Annotations) {
+		versions[name] = version
+	}
+	raw, err := json.Marshal(versions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secret versions: %w", err)
+	}
+
+	return r.finishReload(ctx, deployment, func(d *appsv1.Deployment) {
+		if d.Spec.Template.Annotations == nil {
+			d.Spec.Template.Annotations = map[string]string{}
+		}
+		d.Spec.Template.Annotations[traktorv1beta1.RestartedAtAnnotation] = time.Now().Format(time.RFC3339)
+		d.Spec.Template.Annotations[traktorv1beta1.SecretVersionsAnnotation] = string(raw)
+	})
+}
+
+// finishReload removes the reload mark from the Deployment, applying an optional
+// spec mutation in the same patch
+func (r *ReloadReconciler) finishReload(ctx context.Context, deployment *appsv1.Deployment, mutate func(*appsv1.Deployment)) error {
+	patched := deployment.DeepCopy()
+	delete(patched.Annotations, traktorv1beta1.ReloadAfterAnnotation)
+	if mutate != nil {
+		mutate(patched)
+	}
+	return client.IgnoreNotFound(r.Patch(ctx, patched, client.MergeFrom(deployment)))
+}
+
+// recordEvent emits an event if a recorder is configured
+func (r *ReloadReconciler) recordEvent(obj client.Object, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(obj, eventType, reason, message)
+}
+
+// podReady reports whether the pod passes its readiness checks
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ReloadReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	reloading := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := obj.GetAnnotations()[traktorv1beta1.ReloadAfterAnnotation]
+		return ok
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appsv1.Deployment{}, builder.WithPredicates(reloading)).
+		Named("reload").
+		Complete(r)
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// SecretRequest is a change of a secret to reconcile together with the SecretsRefresh
+// objects that matched it when the change was observed
+type SecretRequest struct {
+	// NamespacedName is the key of the changed secret
+	types.NamespacedName
+
+	// SecretsRefreshes are the sorted, comma separated keys of the matching SecretsRefresh
+	// objects. Work queue items must be comparable, so they can't be a slice. Empty means
+	// the matching SecretsRefresh objects are looked up again, e.g. when retrying pending restarts.
+	SecretsRefreshes string
+
+	// RefreshNow is the traktor.gdxcloud.net/refresh-now token of a manual refresh, which
+	// restarts the consumers of the secret once even though its data didn't change. Without
+	// a secret name it refreshes every secret of the single SecretsRefresh of the request.
+	RefreshNow string
+}
+
+// newSecretRequest returns the request for a change of the secret matched by the SecretsRefresh objects
+func newSecretRequest(secret client.Object, srs []traktorv1beta1.SecretsRefresh) SecretRequest {
+	keys := make([]string, 0, len(srs))
+	for i := range srs {
+		keys = append(keys, client.ObjectKeyFromObject(&srs[i]).String())
+	}
+	slices.Sort(keys)
+	return SecretRequest{
+		NamespacedName:   client.ObjectKeyFromObject(secret),
+		SecretsRefreshes: strings.Join(slices.Compact(keys), ","),
+	}
+}
+
+// secretsRefreshKeys returns the keys of the SecretsRefresh objects of the request
+func (req SecretRequest) secretsRefreshKeys() []types.NamespacedName {
+	if req.SecretsRefreshes == "" {
+		return nil
+	}
+	var keys []types.NamespacedName
+	for _, key := range strings.Split(req.SecretsRefreshes, ",") {
+		namespace, name, _ := strings.Cut(key, "/")
+		keys = append(keys, types.NamespacedName{Namespace: namespace, Name: name})
+	}
+	return keys
+}
+
+// secretsRefreshesForRequest returns the SecretsRefresh objects whose policies apply to
+// the secret change: those named by the request that still exist and still match the
+// secret, or all matching ones if the request names none
+func (r *SecretsRefreshReconciler) secretsRefreshesForRequest(ctx context.Context, req SecretRequest, secret client.Object) ([]traktorv1beta1.SecretsRefresh, error) {
+	logger := log.FromContext(ctx)
+
+	keys := req.secretsRefreshKeys()
+	if len(keys) == 0 {
+		return r.secretsRefreshesForSecret(ctx, secret)
+	}
+
+	matched := make([]traktorv1beta1.SecretsRefresh, 0, len(keys))
+	for _, key := range keys {
+		sr := &traktorv1beta1.SecretsRefresh{}
+		if err := getSecretsRefresh(ctx, r.Client, key, sr); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+			continue
+		}
+		matches, err := r.secretsRefreshMatches(ctx, sr, secret)
+		if err != nil {
+			logger.Error(err, "Failed to match secret", "secretsRefresh", sr.Name)
+			continue
+		}
+		if matches {
+			matched = append(matched, *sr)
+		}
+	}
+	return matched, nil
+}
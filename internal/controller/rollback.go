@@ -0,0 +1,404 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// maxRollbackHistory bounds status.rollbackHistory
+const maxRollbackHistory = 20
+
+// snapshotSuffix is appended to the name of a secret to name its snapshot
+const snapshotSuffix = "-traktor-snapshot"
+
+// rollbackSecretsRefreshes returns the matching SecretsRefresh objects with rollback enabled
+func rollbackSecretsRefreshes(policies []restartPolicy) []*traktorv1beta1.SecretsRefresh {
+	var srs []*traktorv1beta1.SecretsRefresh
+	for _, p := range policies {
+		if p.secretsRefresh.Spec.Rollout.Rollback != nil {
+			srs = append(srs, p.secretsRefresh)
+		}
+	}
+	return srs
+}
+
+// rollbackTimeout returns how long restarted workloads of the SecretsRefresh have to roll out
+func rollbackTimeout(sr *traktorv1beta1.SecretsRefresh) time.Duration {
+	if rb := sr.Spec.Rollout.Rollback; rb != nil && rb.Timeout != nil && rb.Timeout.Duration > 0 {
+		return rb.Timeout.Duration
+	}
+	return traktorv1beta1.DefaultRollbackTimeout
+}
+
+// rollbackThreshold returns the percentage of failed workloads that triggers a rollback
+func rollbackThreshold(sr *traktorv1beta1.SecretsRefresh) int {
+	if rb := sr.Spec.Rollout.Rollback; rb != nil && rb.FailureThreshold > 0 {
+		return int(rb.FailureThreshold)
+	}
+	return traktorv1beta1.DefaultRollbackThreshold
+}
+
+// snapshotName returns the name of the snapshot Secret of a secret
+func snapshotName(secret *corev1.Secret) string {
+	name := secret.Name
+	if maxLen := 253 - len(snapshotSuffix); len(name) > maxLen {
+		name = strings.TrimRight(name[:maxLen], ".-")
+	}
+	return name + snapshotSuffix
+}
+
+// isRollback reports whether the secret currently holds data restored by a rollback,
+// which is never tracked for another rollback
+func isRollback(secret *corev1.Secret) bool {
+	return secret.Annotations[traktorv1beta1.RolledBackToAnnotation] == secretVersion(secret)
+}
+
+// snapshotSecret keeps the previous data of the secret in a snapshot Secret controlled
+// by it and returns its name. When no snapshot can be taken it returns an empty name and
+// why: the previous data is unknown, e.g. because the operator restarted since the
+// change, or a Secret the operator doesn't own already has the name of the snapshot.
+func (r *SecretsRefreshReconciler) snapshotSecret(ctx context.Context, secret, oldSecret *corev1.Secret) (string, string, error) {
+	snapshot := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: snapshotName(secret), Namespace: secret.Namespace},
+	}
+	version := secretVersion(secret)
+
+	err := r.Get(ctx, client.ObjectKeyFromObject(snapshot), snapshot)
+	switch {
+	case err == nil && !isSnapshotOf(snapshot, secret):
+		// Never overwrite a Secret that merely has the name of the snapshot
+		return "", fmt.Sprintf("Secret %s/%s exists and is not a snapshot of Secret %s, the change can't be rolled back",
+			snapshot.Namespace, snapshot.Name, secret.Name), nil
+	case err == nil && snapshot.Annotations[traktorv1beta1.SnapshotForVersionAnnotation] == version:
+		// Already taken by an earlier attempt for this change
+		return snapshot.Name, "", nil
+	case err != nil && !apierrors.IsNotFound(err):
+		return "", "", err
+	}
+	if oldSecret == nil {
+		return "", fmt.Sprintf("Previous data of Secret %s/%s is unknown, the change can't be rolled back",
+			secret.Namespace, secret.Name), nil
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, snapshot, func() error {
+		if snapshot.Annotations == nil {
+			snapshot.Annotations = map[string]string{}
+		}
+		snapshot.Annotations[traktorv1beta1.SnapshotOfAnnotation] = secret.Name
+		snapshot.Annotations[traktorv1beta1.SnapshotForVersionAnnotation] = version
+		snapshot.Type = corev1.SecretTypeOpaque
+		snapshot.Data = oldSecret.Data
+		return controllerutil.SetControllerReference(secret, snapshot, r.Scheme)
+	}); err != nil {
+		return "", "", fmt.Errorf("failed to snapshot secret: %w", err)
+	}
+	return snapshot.Name, "", nil
+}
+
+// isSnapshotOf reports whether the Secret is the snapshot of the secret: it names the
+// secret and is controlled by it
+func isSnapshotOf(snapshot, secret *corev1.Secret) bool {
+	owner := metav1.GetControllerOf(snapshot)
+	return snapshot.Annotations[traktorv1beta1.SnapshotOfAnnotation] == secret.Name &&
+		owner != nil && owner.Kind == "Secret" && owner.UID == secret.UID
+}
+
+// trackRollout records the restarted workloads, so the RollbackReconciler can roll the
+// secret back if they fail to roll out
+func (r *SecretsRefreshReconciler) trackRollout(ctx context.Context, srs []*traktorv1beta1.SecretsRefresh, secret *corev1.Secret, snapshot string, restarted []*appsv1.Deployment, now time.Time) error {
+	workloads := make([]traktorv1beta1.WorkloadReference, 0, len(restarted))
+	for _, deployment := range restarted {
+		workloads = append(workloads, traktorv1beta1.WorkloadReference{
+			Kind:      "Deployment",
+			Name:      deployment.Name,
+			Namespace: deployment.Namespace,
+		})
+	}
+
+	for _, sr := range srs {
+		check := traktorv1beta1.RolloutCheck{
+			SecretName:      secret.Name,
+			SecretNamespace: secret.Namespace,
+			Version:         secretVersion(secret),
+			SnapshotName:    snapshot,
+			Workloads:       workloads,
+			Deadline:        metav1.NewTime(now.Add(rollbackTimeout(sr))),
+		}
+		err := r.updateStatus(ctx, sr, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+			for i := range status.RolloutChecks {
+				existing := &status.RolloutChecks[i]
+				if existing.SecretName != secret.Name || existing.SecretNamespace != secret.Namespace {
+					continue
+				}
+				// Workloads restarted later for the same change, e.g. after a deferral, join the check
+				if existing.Version == check.Version {
+					for _, workload := range existing.Workloads {
+						if !slices.Contains(check.Workloads, workload) {
+							check.Workloads = append(check.Workloads, workload)
+						}
+					}
+				}
+				*existing = check
+				return true
+			}
+			status.RolloutChecks = append(status.RolloutChecks, check)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rolloutOutcome is the state of the workloads restarted for a secret change
+type rolloutOutcome int
+
+const (
+	rolloutPending rolloutOutcome = iota
+	rolloutSucceeded
+	rolloutFailed
+	// rolloutObsolete means the check no longer applies, e.g. the secret changed again
+	rolloutObsolete
+)
+
+// RollbackReconciler watches the rollout of workloads restarted for secret changes
+// and restores the previous secret data when too many of them fail
+type RollbackReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+}
+
+// Reconcile evaluates the rollout checks of a SecretsRefresh
+func (r *RollbackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	sr := &traktorv1beta1.SecretsRefresh{}
+	if err := getSecretsRefresh(ctx, r.Client, req.NamespacedName, sr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	now := time.Now()
+	waiting := false
+	var resolved []traktorv1beta1.RolloutCheck
+	var records []traktorv1beta1.RollbackRecord
+	for _, check := range sr.Status.RolloutChecks {
+		outcome, failed, err := r.checkRollout(ctx, sr, &check, now)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		switch outcome {
+		case rolloutPending:
+			waiting = true
+			continue
+		case rolloutFailed:
+			restored, err := r.rollBack(ctx, sr, &check, failed)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if restored {
+				records = append(records, traktorv1beta1.RollbackRecord{
+					SecretName:      check.SecretName,
+					SecretNamespace: check.SecretNamespace,
+					Version:         check.Version,
+					SnapshotName:    check.SnapshotName,
+					FailedWorkloads: failed,
+					Time:            metav1.NewTime(now),
+				})
+			}
+		case rolloutSucceeded:
+			logger.Info("Restarted workloads rolled out, keeping secret change",
+				"secret", check.SecretName,
+				"namespace", check.SecretNamespace)
+		}
+		resolved = append(resolved, check)
+	}
+
+	if len(resolved) > 0 {
+		err := updateSecretsRefreshStatus(ctx, r.Client, sr, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+			status.RolloutChecks = slices.DeleteFunc(status.RolloutChecks, func(check traktorv1beta1.RolloutCheck) bool {
+				return slices.ContainsFunc(resolved, func(done traktorv1beta1.RolloutCheck) bool {
+					return done.SecretName == check.SecretName && done.SecretNamespace == check.SecretNamespace && done.Version == check.Version
+				})
+			})
+			status.RollbackHistory = append(status.RollbackHistory, records...)
+			if overflow := len(status.RollbackHistory) - maxRollbackHistory; overflow > 0 {
+				status.RollbackHistory = status.RollbackHistory[overflow:]
+			}
+			return true
+		})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if waiting {
+		return ctrl.Result{RequeueAfter: rolloutRecheckInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// checkRollout decides whether the restarted workloads of a check rolled out, failed
+// past the threshold of the SecretsRefresh, or are still rolling out
+func (r *RollbackReconciler) checkRollout(ctx context.Context, sr *traktorv1beta1.SecretsRefresh, check *traktorv1beta1.RolloutCheck, now time.Time) (rolloutOutcome, []traktorv1beta1.WorkloadReference, error) {
+	if sr.Spec.Rollout.Rollback == nil {
+		return rolloutObsolete, nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: check.SecretNamespace, Name: check.SecretName}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return rolloutObsolete, nil, nil
+		}
+		return rolloutPending, nil, err
+	}
+	if secretVersion(secret) != check.Version {
+		// Changed again since, the new change is tracked on its own
+		return rolloutObsolete, nil, nil
+	}
+
+	total, pending := 0, 0
+	var failed []traktorv1beta1.WorkloadReference
+	for _, workload := range check.Workloads {
+		deployment := &appsv1.Deployment{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: workload.Namespace, Name: workload.Name}, deployment); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return rolloutPending, nil, err
+		}
+		total++
+
+		switch {
+		case progressDeadlineExceeded(deployment):
+			failed = append(failed, workload)
+		case rolloutComplete(deployment):
+		case !now.Before(check.Deadline.Time):
+			failed = append(failed, workload)
+		default:
+			pending++
+		}
+	}
+
+	switch {
+	case total == 0:
+		return rolloutObsolete, nil, nil
+	case len(failed) > 0 && len(failed)*100 >= rollbackThreshold(sr)*total:
+		return rolloutFailed, failed, nil
+	case pending > 0:
+		return rolloutPending, nil, nil
+	default:
+		return rolloutSucceeded, nil, nil
+	}
+}
+
+// rollBack restores the secret from its snapshot. The secret change restarts the
+// workloads again. It reports whether the secret was restored.
+func (r *RollbackReconciler) rollBack(ctx context.Context, sr *traktorv1beta1.SecretsRefresh, check *traktorv1beta1.RolloutCheck, failed []traktorv1beta1.WorkloadReference) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: check.SecretNamespace, Name: check.SecretName}, secret); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	snapshot := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: check.SecretNamespace, Name: check.SnapshotName}, snapshot); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+		r.recordEvent(sr, corev1.EventTypeWarning, "RollbackFailed",
+			fmt.Sprintf("Cannot roll back Secret %s/%s: snapshot %s not found", secret.Namespace, secret.Name, check.SnapshotName))
+		return false, nil
+	}
+	if !isSnapshotOf(snapshot, secret) {
+		r.recordEvent(sr, corev1.EventTypeWarning, "RollbackFailed",
+			fmt.Sprintf("Cannot roll back Secret %s/%s: %s is not its snapshot", secret.Namespace, secret.Name, check.SnapshotName))
+		return false, nil
+	}
+
+	restored := secret.DeepCopy()
+	restored.Data = snapshot.Data
+	restored.StringData = nil
+	if restored.Annotations == nil {
+		restored.Annotations = map[string]string{}
+	}
+	restored.Annotations[traktorv1beta1.RolledBackToAnnotation] = secretVersion(restored)
+	if err := r.Update(ctx, restored); err != nil {
+		if !apierrors.IsInvalid(err) {
+			return false, err
+		}
+		// e.g. an immutable secret
+		r.recordEvent(sr, corev1.EventTypeWarning, "RollbackFailed",
+			fmt.Sprintf("Cannot roll back Secret %s/%s: %v", secret.Namespace, secret.Name, err))
+		return false, nil
+	}
+
+	names := make([]string, 0, len(failed))
+	for _, workload := range failed {
+		names = append(names, workload.Namespace+"/"+workload.Name)
+	}
+	message := fmt.Sprintf("Rolled back Secret %s/%s to snapshot %s because %d of %d restarted workload(s) failed to roll out: %s",
+		secret.Namespace, secret.Name, check.SnapshotName, len(failed), len(check.Workloads), strings.Join(names, ", "))
+	logger.Info("Rolled back secret after failed rollout",
+		"secret", secret.Name,
+		"namespace", secret.Namespace,
+		"snapshot", check.SnapshotName,
+		"failed", names)
+	r.recordEvent(sr, corev1.EventTypeWarning, "SecretRolledBack", message)
+	r.recordEvent(secret, corev1.EventTypeWarning, "SecretRolledBack", message)
+	for _, workload := range failed {
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: workload.Name, Namespace: workload.Namespace}}
+		r.recordEvent(deployment, corev1.EventTypeWarning, "SecretRolledBack", message)
+	}
+	return true, nil
+}
+
+// recordEvent emits an event if a recorder is configured
+func (r *RollbackReconciler) recordEvent(obj client.Object, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	if sr, ok := obj.(*traktorv1beta1.SecretsRefresh); ok {
+		obj = secretsRefreshObject(sr)
+	}
+	r.Recorder.Event(obj, eventType, reason, message)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RollbackReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	tracking := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		switch sr := obj.(type) {
+		case *traktorv1beta1.SecretsRefresh:
+			return len(sr.Status.RolloutChecks) > 0
+		case *traktorv1beta1.ClusterSecretsRefresh:
+			return len(sr.Status.RolloutChecks) > 0
+		}
+		return false
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&traktorv1beta1.SecretsRefresh{}, builder.WithPredicates(tracking)).
+		Watches(&traktorv1beta1.ClusterSecretsRefresh{}, &handler.EnqueueRequestForObject{}, builder.WithPredicates(tracking)).
+		Named("rollback").
+		Complete(r)
+}
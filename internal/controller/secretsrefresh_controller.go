@@ -3,20 +3,23 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -24,48 +27,188 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	traktorv1alpha1 "github.com/GDXbsv/traktor/api/v1alpha1"
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+	"github.com/GDXbsv/traktor/internal/policy"
 )
 
-// SecretsRefreshReconciler reconciles a SecretsRefresh object
+// SecretsRefreshReconciler handles changes of the secrets selected by SecretsRefresh
+// objects and restarts the workloads using them. The SecretsRefresh objects themselves
+// are reconciled by SecretsRefreshStatusReconciler.
 type SecretsRefreshReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+
+	// DryRun records the restarts that would happen without patching any workload
+	DryRun bool
+
+	// Config is the operator-wide configuration from the TraktorConfig
+	Config *OperatorConfig
+
+	// APIReader lists the pods and workloads that may still use old secret versions
+	// without caching them, the Client is used when unset
+	APIReader client.Reader
+
+	// Notifier delivers refresh notifications in the background, none are sent when unset
+	Notifier *Notifier
+
+	// restartWhen caches compiled spec.restartWhen expressions
+	restartWhen policy.Cache
+	// previousSecrets keeps the last seen version of changed secrets until they are reconciled
+	previousSecrets secretHistory
+	// secretChanges remembers when secrets changed for flap detection
+	secretChanges secretChanges
+}
+
+// secretHistory remembers the previous version of a Secret between the update
+// event and the reconciliation, so restart policies can compare old and new data
+type secretHistory struct {
+	secrets sync.Map
+}
+
+func (h *secretHistory) store(secret *corev1.Secret) {
+	h.secrets.Store(client.ObjectKeyFromObject(secret), secret.DeepCopy())
+}
+
+func (h *secretHistory) load(key types.NamespacedName) *corev1.Secret {
+	if secret, ok := h.secrets.Load(key); ok {
+		return secret.(*corev1.Secret)
+	}
+	return nil
+}
+
+func (h *secretHistory) forget(key types.NamespacedName) {
+	h.secrets.Delete(key)
+}
+
+// restartPolicy is a restartWhen expression together with the SecretsRefresh it comes from.
+// A nil restartWhen allows every restart, an invalid one denies every restart.
+type restartPolicy struct {
+	secretsRefresh *traktorv1beta1.SecretsRefresh
+	restartWhen    *policy.RestartWhen
+	invalid        bool
 }
 
 // +kubebuilder:rbac:groups=traktor.gdxcloud.net,resources=secretsrefreshes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=traktor.gdxcloud.net,resources=secretsrefreshes/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=traktor.gdxcloud.net,resources=secretsrefreshes/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=traktor.gdxcloud.net,resources=clustersecretsrefreshes,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=traktor.gdxcloud.net,resources=clustersecretsrefreshes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=traktor.gdxcloud.net,resources=clustersecretsrefreshes/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=apps,resources=replicasets;statefulsets;daemonsets,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// The req.Name contains the Secret's name and req.Namespace contains the Secret's namespace
-func (r *SecretsRefreshReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+// The req.Name contains the Secret's name and req.Namespace contains the Secret's namespace,
+// req.SecretsRefreshes the SecretsRefresh objects whose policies apply
+func (r *SecretsRefreshReconciler) Reconcile(ctx context.Context, req SecretRequest) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	// This reconcile is triggered by a Secret change (via SetupWithManager Watches)
 	// The Secret has already been filtered by namespace and label selectors
 	// req.Name contains the Secret's name, req.Namespace contains the Secret's namespace
 
+	// Manual refreshes restart the consumers without a change of the secret
+	if req.RefreshNow != "" {
+		return ctrl.Result{}, r.refreshNow(ctx, req)
+	}
+
 	secretNamespace := req.Namespace
 	secretName := req.Name
 
 	// Safety check: Skip if this is the operator's own namespace to prevent self-restart loop
-	operatorNamespace := os.Getenv("POD_NAMESPACE")
-	if operatorNamespace == "" {
-		operatorNamespace = "traktor-system" // fallback to default
+	if secretNamespace == operatorNamespace() {
+		logger.Info("Skipping operator's own namespace to prevent self-restart", "namespace", secretNamespace)
+		return ctrl.Result{}, nil
 	}
 
-	if secretNamespace == operatorNamespace {
-		logger.Info("Skipping operator's own namespace to prevent self-restart", "namespace", secretNamespace)
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, req.NamespacedName, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("Secret no longer exists, nothing to restart", "secret", secretName, "namespace", secretNamespace)
+			r.previousSecrets.forget(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get secret", "secret", secretName, "namespace", secretNamespace)
+		return ctrl.Result{}, err
+	}
+	oldSecret := r.previousSecrets.load(req.NamespacedName)
+
+	policies, overridden, err := r.restartPoliciesForSecret(ctx, req, secret)
+	if err != nil {
+		logger.Error(err, "Failed to resolve restart policies", "secret", secretName, "namespace", secretNamespace)
+		return ctrl.Result{}, err
+	}
+	if len(policies) == 0 && len(req.secretsRefreshKeys()) > 0 {
+		// Every matching SecretsRefresh was deleted or suspended since the change was observed
+		logger.Info("No SecretsRefresh applies to the secret change", "secret", secretName, "namespace", secretNamespace)
+		r.previousSecrets.forget(req.NamespacedName)
 		return ctrl.Result{}, nil
 	}
 
+	// Dry run only reports what the gates below would do, it never leaves restarts pending
+	dryRun, dryRunSRs := r.dryRunSecretsRefreshes(policies)
+	var wouldDefer *deferral
+
+	// Pause the restarts while the secret keeps changing
+	now := time.Now()
+	if f := r.flappingFor(secret, policies, now); f != nil && dryRun {
+		wouldDefer = &deferral{reason: ReasonSecretFlapping, notBefore: f.resumeAt}
+	} else if f != nil {
+		logger.Info("Secret is flapping, restarts paused",
+			"secret", secretName,
+			"namespace", secretNamespace,
+			"resumeAt", f.resumeAt)
+		blockedRestartsTotal.WithLabelValues(ReasonSecretFlapping).Inc()
+		if err := r.pauseFlappingSecret(ctx, f, secret, now); err != nil {
+			logger.Error(err, "Failed to record flapping secret", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: f.resumeAt.Sub(now)}, nil
+	}
+
+	// Defer the restarts during a change freeze or outside of maintenance windows
+	d, err := r.deferralFor(ctx, secret, policies, now)
+	if err != nil {
+		logger.Error(err, "Failed to check maintenance windows", "secret", secretName, "namespace", secretNamespace)
+		return ctrl.Result{}, err
+	}
+	if d != nil && dryRun {
+		if wouldDefer == nil {
+			wouldDefer = d
+		}
+	} else if d != nil {
+		logger.Info("Restarts deferred",
+			"secret", secretName,
+			"namespace", secretNamespace,
+			"reason", d.reason,
+			"notBefore", d.notBefore)
+		blockedRestartsTotal.WithLabelValues(d.reason).Inc()
+		if err := r.deferRestarts(ctx, d, secret, now); err != nil {
+			logger.Error(err, "Failed to record pending restart", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: d.requeueAfter(now)}, nil
+	}
+
+	// The namespace is only exposed to restartWhen expressions, so fetch it lazily
+	var namespace *corev1.Namespace
+	if hasRestartWhen(policies) {
+		namespace = &corev1.Namespace{}
+		if err := r.Get(ctx, client.ObjectKey{Name: secretNamespace}, namespace); err != nil {
+			logger.Error(err, "Failed to get namespace", "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+	}
+
 	logger.Info("Secret changed, filtering deployments that use this secret",
 		"secret", secretName,
 		"namespace", secretNamespace)
@@ -77,20 +220,230 @@ func (r *SecretsRefreshReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, err
 	}
 
-	// Filter and restart only deployments that use the changed secret
-	restartedCount := 0
+	// Versions of a versioned secret never change, workloads are only switched to the newest one
+	series, err := r.versionSeriesFor(ctx, policies, secret)
+	if err != nil {
+		logger.Error(err, "Failed to resolve secret versions", "secret", secretName, "namespace", secretNamespace)
+		return ctrl.Result{}, err
+	}
+
+	// Filter deployments that use the changed secret and are allowed to restart
+	disruptionPolicy := disruptionPolicyFor(policies)
+	cooldown := cooldownFor(policies)
+	targets := make([]*appsv1.Deployment, 0, len(deploymentList.Items))
+	var scaledToZero, deferred []*appsv1.Deployment
+	deferReason := ""
+	var retryAfter time.Duration
+	deferWorkload := func(deployment *appsv1.Deployment, reason string, after time.Duration) {
+		logger.Info("Restart deferred",
+			"deployment", deployment.Name,
+			"namespace", deployment.Namespace,
+			"reason", reason,
+			"retryAfter", after)
+		blockedRestartsTotal.WithLabelValues(reason).Inc()
+		if deferReason == "" {
+			deferReason = reason
+		}
+		if retryAfter == 0 || after < retryAfter {
+			retryAfter = after
+		}
+		deferred = append(deferred, deployment)
+		// Dry run leaves nothing pending, but reports what would have been deferred
+		if dryRun {
+			d := &deferral{reason: reason}
+			if after > 0 {
+				d.notBefore = now.Add(after)
+			}
+			if err := r.recordDryRunRestart(ctx, dryRunSRs, deployment, secret, d, now); err != nil {
+				logger.Error(err, "Failed to record dry run restart",
+					"deployment", deployment.Name,
+					"namespace", deployment.Namespace)
+			}
+		}
+	}
+	deploymentsEnabled := r.Config.workloadKindEnabled("Deployment")
 	for i := range deploymentList.Items {
 		deployment := &deploymentList.Items[i]
+		if !deploymentsEnabled {
+			break
+		}
 
 		// Check if deployment uses the changed secret
-		if !r.deploymentUsesSecret(deployment, secretName) {
+		references := r.deploymentUsesSecret(deployment, secretName)
+		if series != nil {
+			if len(series.referencedBy(deployment)) == 0 {
+				continue
+			}
+		} else if len(references) == 0 {
+			continue
+		}
+
+		// Protected workloads are never restarted, whatever selects them
+		if r.skipProtected(ctx, policies, deployment, secret) {
+			continue
+		}
+
+		// Workloads that reload mounted secrets themselves only need a restart for
+		// references the kubelet doesn't update in place
+		if series == nil && !restartRequired(deployment, references) {
+			logger.Info("Restart skipped, deployment hot-reloads the mounted secret",
+				"deployment", deployment.Name,
+				"namespace", deployment.Namespace)
+			continue
+		}
+
+		// Check if restart policies allow restarting this deployment
+		if !r.restartAllowed(ctx, policies, policy.RestartWhenInput{
+			OldSecret: oldSecret,
+			NewSecret: secret,
+			Workload:  deployment,
+			Namespace: namespace,
+			Now:       now,
+		}) {
+			logger.Info("Restart skipped by restartWhen policy",
+				"deployment", deployment.Name,
+				"namespace", deployment.Namespace)
 			continue
 		}
 
-		if err := r.restartDeployment(ctx, deployment); err != nil {
+		// Already restarted for this version of the secret, e.g. by an earlier attempt
+		if series == nil && secretVersionApplied(deployment, secret) {
+			continue
+		}
+
+		// Nothing runs to restart, the pods it starts later read the new secret anyway
+		if series == nil && deploymentReplicas(deployment) == 0 {
+			scaledToZero = append(scaledToZero, deployment)
+			continue
+		}
+
+		// Paused or rolling out workloads are restarted once they are ready for it
+		if blocker := rolloutBlocker(deployment); blocker != "" {
+			deferWorkload(deployment, blocker, rolloutRecheckInterval)
+			continue
+		}
+
+		// Recently restarted workloads wait for their cooldown
+		if remaining := cooldownRemaining(deployment, cooldown, now); remaining > 0 {
+			deferWorkload(deployment, ReasonCooldown, remaining)
+			continue
+		}
+
+		// Leave workloads alone whose restart would cause downtime, if asked to
+		strategyType, _ := r.restartStrategyForVersion(deployment, policies, secretName, series)
+		if risk := disruptionRisk(deployment, strategyType); risk != "" && disruptionPolicy == traktorv1beta1.DisruptionPolicySkip {
+			logger.Info("Restart skipped by disruption policy",
+				"deployment", deployment.Name,
+				"namespace", deployment.Namespace,
+				"risk", risk)
+			r.recordEvent(deployment, corev1.EventTypeWarning, "RestartSkipped",
+				fmt.Sprintf("Restart due to Secret %s skipped by disruption policy: %s", secretName, risk))
+			blockedRestartsTotal.WithLabelValues(ReasonDisruptionSkipped).Inc()
+			continue
+		}
+
+		targets = append(targets, deployment)
+	}
+
+	// Wait for a human to approve the restart plan where required
+	actionsAllowed := !dryRun
+	if !dryRun {
+		approval, err := r.checkApproval(ctx, policies, secret, targets, now)
+		if err != nil {
+			logger.Error(err, "Failed to check restart approval", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+		if approval.waiting {
+			logger.Info("Restart plan awaiting approval",
+				"secret", secretName,
+				"namespace", secretNamespace,
+				"plan", approval.planID)
+			blockedRestartsTotal.WithLabelValues(ReasonAwaitingApproval).Inc()
+			return ctrl.Result{RequeueAfter: approval.requeueAfter}, nil
+		}
+		approved := approval.filter(targets)
+		// Actions belong to the change a human refused as much as the restarts do
+		if approval.refused || len(approved) == 0 && len(targets) > 0 {
+			actionsAllowed = false
+		}
+		targets = approved
+	}
+
+	// Run the actions ordered before the restarts, a failed one blocks them
+	blocked := false
+	var problems []string
+	if actionsAllowed {
+		actions, err := r.runActions(ctx, policies, secret, traktorv1beta1.ActionOrderBefore)
+		if err != nil {
+			logger.Error(err, "Failed to run actions before restart", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+		if actions.running {
+			logger.Info("Waiting for actions before restarting",
+				"secret", secretName,
+				"namespace", secretNamespace)
+			return ctrl.Result{RequeueAfter: actionRecheckInterval}, nil
+		}
+		if len(actions.failed) > 0 {
+			logger.Info("Restarts blocked by failed actions",
+				"secret", secretName,
+				"namespace", secretNamespace,
+				"actions", actions.failed)
+			blocked = true
+			blockedRestartsTotal.WithLabelValues(ReasonActionFailed).Inc()
+			problems = append(problems, "failed actions "+strings.Join(actions.failed, ", "))
+			targets, scaledToZero, deferred = nil, nil, nil
+		}
+	}
+
+	// Keep the previous data of the secret, so a change that breaks its consumers can be rolled back
+	var rollbackSRs []*traktorv1beta1.SecretsRefresh
+	if r.Config.rollbackEnabled() {
+		rollbackSRs = rollbackSecretsRefreshes(policies)
+	}
+	snapshot := ""
+	if !dryRun && series == nil && len(targets) > 0 && len(rollbackSRs) > 0 && !isRollback(secret) {
+		var unavailable string
+		var err error
+		snapshot, unavailable, err = r.snapshotSecret(ctx, secret, oldSecret)
+		if err != nil {
+			logger.Error(err, "Failed to snapshot secret", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+		if snapshot == "" {
+			for _, sr := range rollbackSRs {
+				r.recordEvent(sr, corev1.EventTypeWarning, "RollbackUnavailable", unavailable)
+			}
+		}
+	}
+
+	// Restart the remaining deployments
+	restartedCount := 0
+	var restarted []*appsv1.Deployment
+	for _, deployment := range targets {
+		if dryRun {
+			if err := r.recordDryRunRestart(ctx, dryRunSRs, deployment, secret, wouldDefer, now); err != nil {
+				logger.Error(err, "Failed to record dry run restart",
+					"deployment", deployment.Name,
+					"namespace", deployment.Namespace)
+			}
+			logger.Info("Dry run: deployment would be restarted",
+				"deployment", deployment.Name,
+				"namespace", deployment.Namespace)
+			continue
+		}
+
+		// Stay within the restart budget of the whole operator
+		if wait := r.Config.reserveRestart(time.Now()); wait > 0 {
+			deferWorkload(deployment, ReasonRateLimited, wait)
+			continue
+		}
+
+		if err := r.restartDeployment(ctx, deployment, policies, secret, series, disruptionPolicy); err != nil {
 			logger.Error(err, "Failed to restart deployment",
 				"deployment", deployment.Name,
 				"namespace", deployment.Namespace)
+			problems = append(problems, fmt.Sprintf("Deployment %s: %v", deployment.Name, err))
 			continue
 		}
 
@@ -98,51 +451,270 @@ func (r *SecretsRefreshReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			"deployment", deployment.Name,
 			"namespace", deployment.Namespace)
 		restartedCount++
+		restarted = append(restarted, deployment)
+	}
+
+	if snapshot != "" && len(restarted) > 0 {
+		if err := r.trackRollout(ctx, rollbackSRs, secret, snapshot, restarted, now); err != nil {
+			logger.Error(err, "Failed to track rollout for rollback", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !dryRun {
+		for _, deployment := range scaledToZero {
+			if err := r.stampSecretVersion(ctx, deployment, secret); err != nil {
+				logger.Error(err, "Failed to stamp secret version on scaled down deployment",
+					"deployment", deployment.Name,
+					"namespace", deployment.Namespace)
+			}
+		}
+	}
+
+	if !dryRun && (len(restarted) > 0 || len(problems) > 0) {
+		result := traktorv1beta1.RefreshResultSucceeded
+		switch {
+		case blocked:
+			result = traktorv1beta1.RefreshResultBlocked
+		case len(problems) > 0:
+			result = traktorv1beta1.RefreshResultFailed
+		}
+		if err := r.recordRefresh(ctx, policies, overridden, secret, restarted, problems, result, "", now); err != nil {
+			logger.Error(err, "Failed to record refresh", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
 	}
 
 	logger.Info("Completed deployment restart",
 		"secret", secretName,
 		"namespace", secretNamespace,
 		"restartedCount", restartedCount,
+		"deferredCount", len(deferred),
+		"dryRun", dryRun,
 		"totalDeployments", len(deploymentList.Items))
 
+	// Keep the change pending until the deferred workloads have been restarted
+	if len(deferred) > 0 && !dryRun {
+		if err := r.deferWorkloads(ctx, policies, secret, deferred, deferReason, now); err != nil {
+			logger.Error(err, "Failed to record pending restart", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	// Run the actions ordered after the restarts, their Jobs report back when they finish
+	if actionsAllowed && !blocked {
+		if _, err := r.runActions(ctx, policies, secret, traktorv1beta1.ActionOrderAfter); err != nil {
+			logger.Error(err, "Failed to run actions after restart", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.clearPendingRestarts(ctx, policies, secret); err != nil {
+		logger.Error(err, "Failed to clear pending restart", "secret", secretName, "namespace", secretNamespace)
+		return ctrl.Result{}, err
+	}
+	r.previousSecrets.forget(req.NamespacedName)
+
+	// Delete old versions of the secret once nothing references them anymore
+	if series != nil && !dryRun {
+		consumers, err := r.secretVersionConsumers(ctx, secretNamespace, deploymentList.Items)
+		if err != nil {
+			logger.Error(err, "Failed to list consumers of old secret versions", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+		retry, err := r.collectSecretVersions(ctx, secret, series, consumers, now)
+		if err != nil {
+			logger.Error(err, "Failed to delete old secret versions", "secret", secretName, "namespace", secretNamespace)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: retry}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
-// restartDeployment restarts a deployment using Strategic Merge Patch,
-// similar to 'kubectl rollout restart deployment'
-func (r *SecretsRefreshReconciler) restartDeployment(ctx context.Context, deployment *appsv1.Deployment) error {
-	// Create a patch that adds/updates the restartedAt annotation
-	patch := map[string]interface{}{
-		"spec": map[string]interface{}{
-			"template": map[string]interface{}{
-				"metadata": map[string]interface{}{
-					"annotations": map[string]interface{}{
-						"traktor.gdxcloud.net/restartedAt": time.Now().Format(time.RFC3339),
-					},
-				},
-			},
-		},
+// restartDeployment restarts the Deployment with the strategy of the matching
+// SecretsRefresh objects, surging or warning about disruptive restarts as the
+// disruption policy asks
+func (r *SecretsRefreshReconciler) restartDeployment(ctx context.Context, deployment *appsv1.Deployment, policies []restartPolicy, secret *corev1.Secret, series *secretSeries, disruptionPolicy traktorv1beta1.DisruptionPolicy) error {
+	// Callers skip protected workloads before, this guards every restart strategy
+	if reason := r.Config.protection("Deployment", deployment); reason != "" {
+		return &ProtectedWorkloadError{Reason: reason}
+	}
+	strategyType, strategy := r.restartStrategyForVersion(deployment, policies, secret.Name, series)
+	if risk := disruptionRisk(deployment, strategyType); risk != "" {
+		switch {
+		case disruptionPolicy == traktorv1beta1.DisruptionPolicySurgeTemporarily &&
+			strategyType == traktorv1beta1.RestartStrategyTemplateAnnotation:
+			strategy = &surgeStrategy{client: r.Client}
+		case disruptionPolicy != traktorv1beta1.DisruptionPolicyAllow:
+			// Strategies that can't surge fall back to a warning
+			r.warnDisruption(deployment, risk)
+		}
 	}
 
-	patchBytes, err := json.Marshal(patch)
+	// Time the rollout from before the patch, its first update event may come right away
+	restartRollouts.start(client.ObjectKeyFromObject(deployment), time.Now())
+	err := strategy.Restart(ctx, deployment, secret)
+	var conflict *GitOpsConflictError
+	if errors.As(err, &conflict) {
+		// Leave the pod template to the GitOps tool and recycle the pods instead
+		r.reportGitOpsConflict(policies, deployment, conflict)
+		err = (&deletePodsStrategy{client: r.Client}).Restart(ctx, deployment, secret)
+	}
+	observeRestart(deployment, err)
+	return err
+}
+
+// restartPoliciesForSecret returns the restart policies of the SecretsRefresh objects
+// governing the secret, in precedence order, and the matching SecretsRefresh objects
+// they override
+func (r *SecretsRefreshReconciler) restartPoliciesForSecret(ctx context.Context, req SecretRequest, secret *corev1.Secret) ([]restartPolicy, []*traktorv1beta1.SecretsRefresh, error) {
+	logger := log.FromContext(ctx)
+
+	srs, err := r.secretsRefreshesForRequest(ctx, req, secret)
 	if err != nil {
-		return fmt.Errorf("failed to marshal patch: %w", err)
+		return nil, nil, err
 	}
+	governing, overridden := splitByPrecedence(srs)
 
-	// Apply the patch using StrategicMergePatchType
-	return r.Patch(ctx, deployment, client.RawPatch(types.StrategicMergePatchType, patchBytes))
+	policies := make([]restartPolicy, 0, len(governing))
+	for i := range governing {
+		sr := &governing[i]
+		p := restartPolicy{secretsRefresh: sr}
+
+		if sr.Spec.Triggers.RestartWhen != "" {
+			compiled, err := r.restartWhen.Get(sr.Spec.Triggers.RestartWhen)
+			if err != nil {
+				// Invalid expressions are rejected at admission, but objects created
+				// while the webhook was disabled may still carry one. Never restart for them.
+				logger.Error(err, "Invalid restartWhen expression", "secretsRefresh", sr.Name)
+				r.recordEvent(sr, corev1.EventTypeWarning, "InvalidRestartWhen", err.Error())
+				p.invalid = true
+			}
+			p.restartWhen = compiled
+		}
+
+		policies = append(policies, p)
+	}
+
+	overriddenSRs := make([]*traktorv1beta1.SecretsRefresh, 0, len(overridden))
+	for i := range overridden {
+		overriddenSRs = append(overriddenSRs, &overridden[i])
+	}
+	if len(overriddenSRs) > 0 {
+		logger.Info("SecretsRefresh objects with a higher priority govern the secret",
+			"secret", secret.Name,
+			"namespace", secret.Namespace,
+			"governing", formatReferences(governingReferences(policies)))
+	}
+
+	return policies, overriddenSRs, nil
 }
 
-// deploymentUsesSecret checks if a deployment references the specified secret
-// in volumes, environment variables, or envFrom
-func (r *SecretsRefreshReconciler) deploymentUsesSecret(deployment *appsv1.Deployment, secretName string) bool {
-	podSpec := &deployment.Spec.Template.Spec
+// hasRestartWhen reports whether any policy carries a restartWhen expression
+func hasRestartWhen(policies []restartPolicy) bool {
+	for _, p := range policies {
+		if p.restartWhen != nil || p.invalid {
+			return true
+		}
+	}
+	return false
+}
+
+// restartAllowed evaluates restart policies for a single workload.
+// A restart is allowed when no policy applies, or when at least one matching
+// SecretsRefresh allows it. ClusterSecretsRefresh objects are guardrails tenants
+// can't loosen: when one governs, one of them must allow the restart as well as one
+// of the namespaced SecretsRefresh objects, if any. Evaluation errors deny the restart.
+func (r *SecretsRefreshReconciler) restartAllowed(ctx context.Context, policies []restartPolicy, input policy.RestartWhenInput) bool {
+	if len(policies) == 0 {
+		return true
+	}
 
-	// Check volumes
+	var cluster, namespaced []restartPolicy
+	for _, p := range policies {
+		if isClusterSecretsRefresh(p.secretsRefresh) {
+			cluster = append(cluster, p)
+		} else {
+			namespaced = append(namespaced, p)
+		}
+	}
+	if len(cluster) > 0 && !r.anyAllows(ctx, cluster, input) {
+		return false
+	}
+	return len(namespaced) == 0 || r.anyAllows(ctx, namespaced, input)
+}
+
+// anyAllows reports whether one of the policies allows the restart
+func (r *SecretsRefreshReconciler) anyAllows(ctx context.Context, policies []restartPolicy, input policy.RestartWhenInput) bool {
+	logger := log.FromContext(ctx)
+
+	for _, p := range policies {
+		if p.invalid {
+			continue
+		}
+		if p.restartWhen == nil {
+			return true
+		}
+
+		allowed, err := p.restartWhen.Evaluate(input)
+		if err != nil {
+			logger.Error(err, "Failed to evaluate restartWhen expression", "secretsRefresh", p.secretsRefresh.Name)
+			r.recordEvent(p.secretsRefresh, corev1.EventTypeWarning, "RestartWhenFailed", err.Error())
+			continue
+		}
+		if allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordEvent emits an event if an event recorder is configured
+func (r *SecretsRefreshReconciler) recordEvent(obj runtime.Object, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	if sr, ok := obj.(*traktorv1beta1.SecretsRefresh); ok {
+		obj = secretsRefreshObject(sr)
+	}
+	r.Recorder.Event(obj, eventType, reason, message)
+}
+
+// Ways a pod template can reference a secret
+const (
+	ReferenceVolume          = "volume"
+	ReferenceSubPath         = "subPath"
+	ReferenceEnv             = "env"
+	ReferenceEnvFrom         = "envFrom"
+	ReferenceImagePullSecret = "imagePullSecret"
+)
+
+// deploymentUsesSecret reports how a deployment consumes the specified secret
+// through volumes, subPath mounts, environment variables, envFrom or image pull
+// secrets, and returns nothing when it doesn't use the secret at all
+func (r *SecretsRefreshReconciler) deploymentUsesSecret(deployment *appsv1.Deployment, secretName string) []string {
+	return secretReferences(deployment, secretName)
+}
+
+// secretReferences returns how a deployment references the specified secret,
+// each kind of reference at most once and in a stable order
+func secretReferences(deployment *appsv1.Deployment, secretName string) []string {
+	return podSpecSecretReferences(&deployment.Spec.Template.Spec, secretName)
+}
+
+// podSpecSecretReferences returns how a pod spec references the specified secret
+func podSpecSecretReferences(podSpec *corev1.PodSpec, secretName string) []string {
+	found := map[string]bool{}
+
+	// Check volumes, which are refined by how the containers mount them below
+	secretVolumes := map[string]bool{}
 	for _, volume := range podSpec.Volumes {
 		if volume.Secret != nil && volume.Secret.SecretName == secretName {
-			return true
+			secretVolumes[volume.Name] = false
 		}
 	}
 
@@ -151,132 +723,216 @@ func (r *SecretsRefreshReconciler) deploymentUsesSecret(deployment *appsv1.Deplo
 	allContainers = append(allContainers, podSpec.Containers...)
 
 	for _, container := range allContainers {
-		// Check envFrom
-		for _, envFrom := range container.EnvFrom {
-			if envFrom.SecretRef != nil && envFrom.SecretRef.Name == secretName {
-				return true
-			}
-		}
-
-		// Check env
-		for _, env := range container.Env {
-			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
-				if env.ValueFrom.SecretKeyRef.Name == secretName {
-					return true
-				}
-			}
-		}
+		checkContainerEnv(container.EnvFrom, container.Env, secretName, found)
+		checkContainerMounts(container.VolumeMounts, secretVolumes, found)
 	}
 
 	// Check ephemeral containers separately (different type)
 	for _, container := range podSpec.EphemeralContainers {
-		// Check envFrom
-		for _, envFrom := range container.EnvFrom {
-			if envFrom.SecretRef != nil && envFrom.SecretRef.Name == secretName {
-				return true
-			}
-		}
+		checkContainerEnv(container.EnvFrom, container.Env, secretName, found)
+		checkContainerMounts(container.VolumeMounts, secretVolumes, found)
+	}
 
-		// Check env
-		for _, env := range container.Env {
-			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
-				if env.ValueFrom.SecretKeyRef.Name == secretName {
-					return true
-				}
-			}
+	// Volumes that no container mounts still count as volume references
+	for _, mounted := range secretVolumes {
+		if !mounted {
+			found[ReferenceVolume] = true
 		}
 	}
 
 	// Check imagePullSecrets
 	for _, imagePullSecret := range podSpec.ImagePullSecrets {
 		if imagePullSecret.Name == secretName {
-			return true
+			found[ReferenceImagePullSecret] = true
 		}
 	}
 
-	return false
+	references := make([]string, 0, len(found))
+	for _, kind := range []string{ReferenceVolume, ReferenceSubPath, ReferenceEnv, ReferenceEnvFrom, ReferenceImagePullSecret} {
+		if found[kind] {
+			references = append(references, kind)
+		}
+	}
+	return references
+}
+
+// checkContainerEnv records env and envFrom references to the secret
+func checkContainerEnv(envFromSources []corev1.EnvFromSource, envVars []corev1.EnvVar, secretName string, found map[string]bool) {
+	// Check envFrom
+	for _, envFrom := range envFromSources {
+		if envFrom.SecretRef != nil && envFrom.SecretRef.Name == secretName {
+			found[ReferenceEnvFrom] = true
+		}
+	}
+
+	// Check env
+	for _, env := range envVars {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			if env.ValueFrom.SecretKeyRef.Name == secretName {
+				found[ReferenceEnv] = true
+			}
+		}
+	}
+}
+
+// checkContainerMounts records mounts of the secret volumes. The kubelet refreshes
+// mounted secrets in place, except for files mounted through subPath.
+func checkContainerMounts(mounts []corev1.VolumeMount, secretVolumes map[string]bool, found map[string]bool) {
+	for _, mount := range mounts {
+		if _, ok := secretVolumes[mount.Name]; !ok {
+			continue
+		}
+		secretVolumes[mount.Name] = true
+		if mount.SubPath != "" || mount.SubPathExpr != "" {
+			found[ReferenceSubPath] = true
+		} else {
+			found[ReferenceVolume] = true
+		}
+	}
 }
 
 // getFilteredNamespaces returns namespaces that match the selector
-func (r *SecretsRefreshReconciler) getFilteredNamespaces(ctx context.Context, sr *traktorv1alpha1.SecretsRefresh) ([]corev1.Namespace, error) {
+func (r *SecretsRefreshReconciler) getFilteredNamespaces(ctx context.Context, sr *traktorv1beta1.SecretsRefresh) ([]corev1.Namespace, error) {
+	return filteredNamespaces(ctx, r.Client, sr)
+}
+
+// filteredNamespaces implements getFilteredNamespaces for every controller that
+// resolves the namespaces of a SecretsRefresh
+func filteredNamespaces(ctx context.Context, c client.Reader, sr *traktorv1beta1.SecretsRefresh) ([]corev1.Namespace, error) {
 	namespaceList := &corev1.NamespaceList{}
 
-	// If no selector is specified, return all namespaces
-	if sr.Spec.NamespaceSelector == nil {
-		if err := r.List(ctx, namespaceList); err != nil {
-			return nil, err
-		}
-		return namespaceList.Items, nil
+	if selectsNoNamespace(sr) {
+		return nil, nil
 	}
 
-	// Convert label selector to labels.Selector
-	selector, err := metav1.LabelSelectorAsSelector(sr.Spec.NamespaceSelector)
-	if err != nil {
-		return nil, fmt.Errorf("invalid namespace selector: %w", err)
+	// If no selector is specified, select all namespaces the SecretsRefresh may access
+	selector := labels.Everything()
+	if sr.Spec.Targets.NamespaceSelector != nil {
+		// Convert label selector to labels.Selector
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(sr.Spec.Targets.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector: %w", err)
+		}
 	}
 
 	// List all namespaces
-	if err := r.List(ctx, namespaceList); err != nil {
+	if err := c.List(ctx, namespaceList); err != nil {
 		return nil, err
 	}
 
 	// Filter namespaces by selector
-	var filteredNamespaces []corev1.Namespace
+	var matched []corev1.Namespace
 	for _, ns := range namespaceList.Items {
-		if selector.Matches(labels.Set(ns.Labels)) {
-			filteredNamespaces = append(filteredNamespaces, ns)
+		if selector.Matches(labels.Set(ns.Labels)) && namespaceAllowed(sr, &ns) {
+			matched = append(matched, ns)
 		}
 	}
 
-	return filteredNamespaces, nil
+	return matched, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SecretsRefreshReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// Create predicates to filter only real secret updates
-	secretPredicates := predicate.Funcs{
-		// Ignore Create events (including initial cache sync on controller restart)
-		CreateFunc: func(e event.CreateEvent) bool {
-			return false
+	secretPredicates := predicate.TypedFuncs[*corev1.Secret]{
+		// Ignore Create events (including initial cache sync on controller restart),
+		// except for secrets carrying the series label of versioned secrets
+		CreateFunc: func(e event.TypedCreateEvent[*corev1.Secret]) bool {
+			return r.hasSeriesLabel(context.Background(), e.Object)
 		},
-		// Only process Update events where data actually changed
-		UpdateFunc: func(e event.UpdateEvent) bool {
-			oldSecret, oldOk := e.ObjectOld.(*corev1.Secret)
-			newSecret, newOk := e.ObjectNew.(*corev1.Secret)
-
-			if !oldOk || !newOk {
-				return false
-			}
-
+		// Only process Update events where data actually changed or a refresh was requested
+		UpdateFunc: func(e event.TypedUpdateEvent[*corev1.Secret]) bool {
 			// Check if the secret data or stringData actually changed
-			// This prevents reconciliation on metadata-only updates
-			oldDataHash := hashSecretData(oldSecret)
-			newDataHash := hashSecretData(newSecret)
+			// This prevents reconciliation on other metadata-only updates
+			oldDataHash := hashSecretData(e.ObjectOld)
+			newDataHash := hashSecretData(e.ObjectNew)
 
-			return oldDataHash != newDataHash
+			return oldDataHash != newDataHash || refreshNowToken(e.ObjectOld, e.ObjectNew) != ""
 		},
 		// Ignore Delete events - we don't need to restart deployments when secrets are deleted
-		DeleteFunc: func(e event.DeleteEvent) bool {
+		DeleteFunc: func(e event.TypedDeleteEvent[*corev1.Secret]) bool {
 			return false
 		},
 		// Process Generic events (can happen with informer resync)
-		GenericFunc: func(e event.GenericEvent) bool {
+		GenericFunc: func(e event.TypedGenericEvent[*corev1.Secret]) bool {
 			return false
 		},
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&traktorv1alpha1.SecretsRefresh{}).
+	return builder.TypedControllerManagedBy[SecretRequest](mgr).
+		// Retry pending restarts when a SecretsRefresh is (re)loaded or changed, and
+		// handle refreshes requested on it
+		WatchesRawSource(source.TypedKind(
+			mgr.GetCache(),
+			&traktorv1beta1.SecretsRefresh{},
+			handler.TypedEnqueueRequestsFromMapFunc(r.requestsForSecretsRefresh),
+		)).
+		WatchesRawSource(source.TypedKind(
+			mgr.GetCache(),
+			&traktorv1beta1.ClusterSecretsRefresh{},
+			handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, csr *traktorv1beta1.ClusterSecretsRefresh) []SecretRequest {
+				return r.requestsForSecretsRefresh(ctx, fromClusterSecretsRefresh(csr))
+			}),
+		)).
 		// Watch for changes to Secrets in all namespaces with predicates
-		Watches(
+		WatchesRawSource(source.TypedKind(
+			mgr.GetCache(),
 			&corev1.Secret{},
-			handler.EnqueueRequestsFromMapFunc(r.findSecretsRefreshForSecret),
-			builder.WithPredicates(secretPredicates),
-		).
+			handler.TypedFuncs[*corev1.Secret, SecretRequest]{
+				CreateFunc: func(ctx context.Context, e event.TypedCreateEvent[*corev1.Secret], q workqueue.TypedRateLimitingInterface[SecretRequest]) {
+					for _, req := range r.findVersionedSecretsRefreshForSecret(ctx, e.Object) {
+						q.Add(req)
+					}
+				},
+				UpdateFunc: func(ctx context.Context, e event.TypedUpdateEvent[*corev1.Secret], q workqueue.TypedRateLimitingInterface[SecretRequest]) {
+					for _, req := range r.findRefreshNowForSecret(ctx, e.ObjectOld, e.ObjectNew) {
+						q.Add(req)
+					}
+					if hashSecretData(e.ObjectOld) == hashSecretData(e.ObjectNew) {
+						return
+					}
+					for _, req := range r.secretChanged(ctx, e.ObjectOld, e.ObjectNew) {
+						q.Add(req)
+					}
+				},
+			},
+			secretPredicates,
+		)).
+		// Reconcile the secret change again when a Job started by an action finishes
+		WatchesRawSource(source.TypedKind(
+			mgr.GetCache(),
+			&batchv1.Job{},
+			handler.TypedEnqueueRequestsFromMapFunc(secretForActionJob),
+			predicate.NewTypedPredicateFuncs(func(job *batchv1.Job) bool {
+				_, ok := job.Labels[traktorv1beta1.ActionLabel]
+				return ok
+			}),
+		)).
 		Named("secretsrefresh").
 		Complete(r)
 }
 
+// secretChanged records a data change of the secret and maps it to the SecretsRefresh
+// objects selecting it
+func (r *SecretsRefreshReconciler) secretChanged(ctx context.Context, oldSecret, newSecret *corev1.Secret) []SecretRequest {
+	srs, err := r.secretsRefreshesForSecret(ctx, newSecret)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list SecretsRefresh objects")
+		return nil
+	}
+	if len(srs) == 0 {
+		return nil
+	}
+
+	// Remember the previous version so restartWhen can compare old and new secret,
+	// only for selected secrets as it is kept until the change is reconciled
+	r.previousSecrets.store(oldSecret)
+	r.secretChanges.record(client.ObjectKeyFromObject(newSecret), time.Now(), flapWindow(srs))
+	secretChangesTotal.WithLabelValues(newSecret.Namespace).Inc()
+	return []SecretRequest{newSecretRequest(newSecret, srs)}
+}
+
 // hashSecretData creates a hash of secret data for comparison
 func hashSecretData(secret *corev1.Secret) string {
 	if secret == nil {
@@ -301,60 +957,58 @@ func hashSecretData(secret *corev1.Secret) string {
 	return b.String()
 }
 
-// findSecretsRefreshForSecret maps a Secret to SecretsRefresh objects that should watch it
-func (r *SecretsRefreshReconciler) findSecretsRefreshForSecret(ctx context.Context, secret client.Object) []ctrl.Request {
+// findSecretsRefreshForSecret maps a Secret to a request carrying the SecretsRefresh
+// objects that watch it, none if no SecretsRefresh does
+func (r *SecretsRefreshReconciler) findSecretsRefreshForSecret(ctx context.Context, secret client.Object) []SecretRequest {
 	logger := log.FromContext(ctx)
 
-	// List all SecretsRefresh objects
-	srList := &traktorv1alpha1.SecretsRefreshList{}
-	if err := r.List(ctx, srList); err != nil {
+	srs, err := r.secretsRefreshesForSecret(ctx, secret)
+	if err != nil {
 		logger.Error(err, "Failed to list SecretsRefresh objects")
-		return []ctrl.Request{}
+		return nil
+	}
+	if len(srs) == 0 {
+		return nil
 	}
 
-	requests := make([]ctrl.Request, 0, len(srList.Items))
-	for _, sr := range srList.Items {
-		// Check if this secret's namespace matches the SecretsRefresh namespace selector
-		namespaces, err := r.getFilteredNamespaces(ctx, &sr)
-		if err != nil {
-			logger.Error(err, "Failed to get filtered namespaces", "secretsRefresh", sr.Name)
-			continue
-		}
+	return []SecretRequest{newSecretRequest(secret, srs)}
+}
 
-		// Check if secret's namespace is in filtered list
-		namespaceMatches := false
-		for _, ns := range namespaces {
-			if ns.Name == secret.GetNamespace() {
-				namespaceMatches = true
-				break
-			}
-		}
+// secretsRefreshesForSecret returns the SecretsRefresh objects whose selectors match the secret
+func (r *SecretsRefreshReconciler) secretsRefreshesForSecret(ctx context.Context, secret client.Object) ([]traktorv1beta1.SecretsRefresh, error) {
+	logger := log.FromContext(ctx)
 
-		if !namespaceMatches {
+	// List all SecretsRefresh and ClusterSecretsRefresh objects
+	srs, err := listSecretsRefreshes(ctx, r.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]traktorv1beta1.SecretsRefresh, 0, len(srs))
+	for _, sr := range srs {
+		matches, err := r.secretsRefreshMatches(ctx, &sr, secret)
+		if err != nil {
+			logger.Error(err, "Failed to match secret", "secretsRefresh", sr.Name)
 			continue
 		}
-
-		// Check if secret matches the secret selector
-		if sr.Spec.SecretSelector != nil {
-			selector, err := metav1.LabelSelectorAsSelector(sr.Spec.SecretSelector)
-			if err != nil {
-				logger.Error(err, "Invalid secret selector", "secretsRefresh", sr.Name)
-				continue
-			}
-			if !selector.Matches(labels.Set(secret.GetLabels())) {
-				continue
-			}
+		if matches {
+			matched = append(matched, sr)
 		}
+	}
 
-		// This SecretsRefresh should be reconciled
-		// Pass the Secret's name and namespace properly
-		requests = append(requests, ctrl.Request{
-			NamespacedName: client.ObjectKey{
-				Name:      secret.GetName(),      // Secret's name
-				Namespace: secret.GetNamespace(), // Secret's namespace
-			},
-		})
+	return matched, nil
+}
+
+// secretsRefreshMatches reports whether the selectors of the SecretsRefresh match the
+// secret. SecretsRefresh objects being deleted or suspended match nothing.
+func (r *SecretsRefreshReconciler) secretsRefreshMatches(ctx context.Context, sr *traktorv1beta1.SecretsRefresh, secret client.Object) (bool, error) {
+	if !sr.DeletionTimestamp.IsZero() || sr.Spec.Suspend {
+		return false, nil
 	}
 
-	return requests
+	m, err := newSecretMatcher(ctx, r.Client, sr)
+	if err != nil {
+		return false, err
+	}
+	return m.matches(secret), nil
 }
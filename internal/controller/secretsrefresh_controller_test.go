@@ -18,18 +18,36 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	appsv1alpha1 "github.com/GDXbsv/traktor/api/v1alpha1"
+	appsv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+	"github.com/GDXbsv/traktor/internal/policy"
 )
 
 var _ = Describe("SecretsRefresh Controller", func() {
@@ -49,7 +67,7 @@ var _ = Describe("SecretsRefresh Controller", func() {
 		ctx := context.Background()
 
 		namespace := &corev1.Namespace{}
-		secretsRefresh := &appsv1alpha1.SecretsRefresh{}
+		secretsRefresh := &appsv1beta1.SecretsRefresh{}
 		deployment := &appsv1.Deployment{}
 		secret := &corev1.Secret{}
 
@@ -69,6 +87,10 @@ var _ = Describe("SecretsRefresh Controller", func() {
 						"environment": "test",
 						"team":        "platform",
 					},
+					// Grant the SecretsRefresh objects of the tests, which live in default
+					Annotations: map[string]string{
+						appsv1beta1.AllowSecretsRefreshFromAnnotation: "default",
+					},
 				},
 			}
 			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
@@ -117,6 +139,7 @@ var _ = Describe("SecretsRefresh Controller", func() {
 				},
 			}
 			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+			markRolledOut(ctx, client.ObjectKeyFromObject(deployment))
 
 			By("Creating a test secret with labels")
 			secret = &corev1.Secret{
@@ -135,20 +158,22 @@ var _ = Describe("SecretsRefresh Controller", func() {
 			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
 
 			By("Creating the SecretsRefresh resource")
-			secretsRefresh = &appsv1alpha1.SecretsRefresh{
+			secretsRefresh = &appsv1beta1.SecretsRefresh{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      secretsRefreshName,
 					Namespace: "default",
 				},
-				Spec: appsv1alpha1.SecretsRefreshSpec{
-					NamespaceSelector: &metav1.LabelSelector{
-						MatchLabels: map[string]string{
-							"environment": "test",
+				Spec: appsv1beta1.SecretsRefreshSpec{
+					Targets: appsv1beta1.Targets{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								"environment": "test",
+							},
 						},
-					},
-					SecretSelector: &metav1.LabelSelector{
-						MatchLabels: map[string]string{
-							"auto-refresh": "enabled",
+						SecretSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								"auto-refresh": "enabled",
+							},
 						},
 					},
 				},
@@ -167,7 +192,7 @@ var _ = Describe("SecretsRefresh Controller", func() {
 			}
 
 			// Delete SecretsRefresh
-			resource := &appsv1alpha1.SecretsRefresh{}
+			resource := &appsv1beta1.SecretsRefresh{}
 			err = k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, resource)
 			if err == nil {
 				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
@@ -181,7 +206,7 @@ var _ = Describe("SecretsRefresh Controller", func() {
 				Scheme: k8sClient.Scheme(),
 			}
 
-			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
 				NamespacedName: types.NamespacedName{
 					Name:      secretName,
 					Namespace: testNamespace,
@@ -240,6 +265,7 @@ var _ = Describe("SecretsRefresh Controller", func() {
 				},
 			}
 			Expect(k8sClient.Create(ctx, deployment2)).To(Succeed())
+			markRolledOut(ctx, client.ObjectKeyFromObject(deployment2))
 
 			By("Reconciling with the secret name and namespace")
 			controllerReconciler := &SecretsRefreshReconciler{
@@ -247,7 +273,7 @@ var _ = Describe("SecretsRefresh Controller", func() {
 				Scheme: k8sClient.Scheme(),
 			}
 
-			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
 				NamespacedName: types.NamespacedName{
 					Name:      secretName,
 					Namespace: testNamespace,
@@ -347,6 +373,7 @@ var _ = Describe("SecretsRefresh Controller", func() {
 				},
 			}
 			Expect(k8sClient.Create(ctx, deployment3)).To(Succeed())
+			markRolledOut(ctx, client.ObjectKeyFromObject(deployment3))
 
 			By("Creating additional secrets")
 			secret2 := &corev1.Secret{
@@ -377,7 +404,7 @@ var _ = Describe("SecretsRefresh Controller", func() {
 				Scheme: k8sClient.Scheme(),
 			}
 
-			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
 				NamespacedName: types.NamespacedName{
 					Name:      secretName3, // Change secrets-terraform
 					Namespace: testNamespace,
@@ -402,68 +429,2975 @@ var _ = Describe("SecretsRefresh Controller", func() {
 			}, timeout, interval).Should(BeTrue())
 		})
 
-		It("should filter namespaces correctly based on selector", func() {
-			By("Getting filtered namespaces")
+		It("should only restart deployments allowed by the restartWhen expression", func() {
+			By("Setting a restartWhen expression on the SecretsRefresh")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Triggers.RestartWhen = `has(newSecret.metadata.annotations) && ` +
+				`newSecret.metadata.annotations['rotation-id'] == 'approved'`
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
 			controllerReconciler := &SecretsRefreshReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
 			}
 
-			namespaces, err := controllerReconciler.getFilteredNamespaces(ctx, secretsRefresh)
+			By("Reconciling a secret change the expression rejects")
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
 			Expect(err).NotTo(HaveOccurred())
 
-			By("Verifying test namespace is included")
-			found := false
-			for _, ns := range namespaces {
-				if ns.Name == testNamespace {
-					found = true
-					break
-				}
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			By("Annotating the secret so the expression allows the restart")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+			controllerReconciler.previousSecrets.store(secret)
+			secret.Annotations = map[string]string{"rotation-id": "approved"}
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			By("Verifying the previous secret version is forgotten after reconciliation")
+			Expect(controllerReconciler.previousSecrets.load(types.NamespacedName{Name: secretName, Namespace: testNamespace})).To(BeNil())
+		})
+
+		It("should defer restarts outside of maintenance windows and record them as pending", func() {
+			By("Restricting restarts to a window that is not open now")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.MaintenanceWindows = []appsv1beta1.MaintenanceWindow{{
+				Schedule: "0 0 29 2 *",
+				Duration: metav1.Duration{Duration: time.Minute},
+			}}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
 			}
-			Expect(found).To(BeTrue())
+
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			By("Verifying the deployment was not restarted")
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			By("Verifying the change is pending in status")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			pending := secretsRefresh.Status.PendingRestarts[0]
+			Expect(pending.SecretName).To(Equal(secretName))
+			Expect(pending.SecretNamespace).To(Equal(testNamespace))
+			Expect(pending.Reason).To(Equal(ReasonOutsideMaintenanceWindow))
+			Expect(pending.NotBefore).NotTo(BeNil())
+			Expect(pending.NotBefore.Month()).To(Equal(time.February))
+			Expect(pending.NotBefore.Day()).To(Equal(29))
+
+			By("Mapping the SecretsRefresh back to its pending secret")
+			Expect(controllerReconciler.pendingSecretsForSecretsRefresh(ctx, secretsRefresh)).To(ConsistOf(
+				SecretRequest{NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace}},
+			))
+
+			By("Marking the secret as urgent")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+			secret.Annotations = map[string]string{appsv1beta1.UrgentAnnotation: "true"}
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			result, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			By("Verifying the urgent change restarted the deployment and cleared the pending entry")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey("traktor.gdxcloud.net/restartedAt"))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
 		})
 
-		It("should correctly hash secret data for comparison", func() {
-			By("Creating two secrets with identical data")
-			secret1 := &corev1.Secret{
-				Data: map[string][]byte{
-					"key1": []byte("value1"),
-					"key2": []byte("value2"),
-				},
+		It("should defer restarts while the namespace is frozen", func() {
+			By("Freezing the test namespace")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: testNamespace}, namespace)).To(Succeed())
+			namespace.Annotations[appsv1beta1.FreezeAnnotation] = "true"
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
 			}
-			secret2 := &corev1.Secret{
-				Data: map[string][]byte{
-					"key2": []byte("value2"),
-					"key1": []byte("value1"),
-				},
+
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(freezeRecheckInterval))
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			Expect(secretsRefresh.Status.PendingRestarts[0].Reason).To(Equal(ReasonChangeFreeze))
+			Expect(secretsRefresh.Status.PendingRestarts[0].NotBefore).To(BeNil())
+
+			By("Lifting the freeze")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: testNamespace}, namespace)).To(Succeed())
+			delete(namespace.Annotations, appsv1beta1.FreezeAnnotation)
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey("traktor.gdxcloud.net/restartedAt"))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+		})
+
+		It("should hold restarts until the restart plan is approved", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Approval.Required = true
+			secretsRefresh.Spec.Rollout.Approval.TTL = &metav1.Duration{Duration: time.Hour}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			request := SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
 			}
 
-			hash1 := hashSecretData(secret1)
-			hash2 := hashSecretData(secret2)
+			result, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(time.Hour))
 
-			By("Verifying hashes are identical regardless of key order")
-			Expect(hash1).To(Equal(hash2))
+			By("Verifying the plan is pending and nothing was restarted")
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
 
-			By("Creating a secret with different data")
-			secret3 := &corev1.Secret{
-				Data: map[string][]byte{
-					"key1": []byte("value1"),
-					"key2": []byte("different"),
-				},
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			plan := secretsRefresh.Status.PendingRestarts[0]
+			Expect(plan.Reason).To(Equal(ReasonAwaitingApproval))
+			Expect(plan.PlanID).To(HavePrefix(secretName + "-"))
+			Expect(plan.ExpiresAt).NotTo(BeNil())
+			Expect(plan.Workloads).To(ConsistOf(appsv1beta1.WorkloadReference{
+				Kind: "Deployment", Name: deploymentName, Namespace: testNamespace,
+			}))
+
+			By("Reconciling again without a decision keeps waiting")
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			By("Approving the plan")
+			secretsRefresh.Annotations = map[string]string{appsv1beta1.ApproveAnnotation: plan.PlanID}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			result, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			By("Verifying the deployment was restarted and the approver recorded")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+			Expect(secretsRefresh.Status.ApprovalHistory).To(HaveLen(1))
+			record := secretsRefresh.Status.ApprovalHistory[0]
+			Expect(record.PlanID).To(Equal(plan.PlanID))
+			Expect(record.Decision).To(Equal(DecisionApproved))
+			Expect(record.By).NotTo(BeEmpty())
+		})
+
+		It("should hold changes by authors changedBy doesn't allow for approval", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Triggers.ChangedBy = &appsv1beta1.ChangedBy{
+				Allow: []string{"external-secrets", "vault-*"},
+				Deny:  []string{"vault-debug"},
 			}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
 
-			hash3 := hashSecretData(secret3)
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			request := SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			}
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
 
-			By("Verifying hash is different when data changes")
-			Expect(hash1).NotTo(Equal(hash3))
+			By("Holding a kubectl edit for approval")
+			Expect(k8sClient.Get(ctx, request.NamespacedName, secret)).To(Succeed())
+			secret.StringData = map[string]string{"password": "hotfix-password"}
+			Expect(k8sClient.Update(ctx, secret, client.FieldOwner("kubectl-edit"))).To(Succeed())
+			Expect(k8sClient.Get(ctx, request.NamespacedName, secret)).To(Succeed())
+			Expect(dataManager(secret)).To(Equal("kubectl-edit"))
 
-			By("Verifying nil secret returns empty hash")
-			hashNil := hashSecretData(nil)
-			Expect(hashNil).To(Equal(""))
+			_, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			Expect(secretsRefresh.Status.PendingRestarts[0].Reason).To(Equal(ReasonAwaitingApproval))
+
+			By("Restarting right away for a rotation by an allowed author")
+			secret.StringData = map[string]string{"password": "rotated-password"}
+			Expect(k8sClient.Update(ctx, secret, client.FieldOwner("vault-sync"))).To(Succeed())
+			Expect(k8sClient.Get(ctx, request.NamespacedName, secret)).To(Succeed())
+			Expect(dataManager(secret)).To(Equal("vault-sync"))
+
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Denying authors even if allowed")
+			Expect(changeAuthorAllowed(secretsRefresh.Spec.Triggers.ChangedBy, "external-secrets")).To(BeTrue())
+			Expect(changeAuthorAllowed(secretsRefresh.Spec.Triggers.ChangedBy, "vault-debug")).To(BeFalse())
+			Expect(changeAuthorAllowed(secretsRefresh.Spec.Triggers.ChangedBy, "")).To(BeFalse())
+			Expect(changeAuthorAllowed(nil, "kubectl-edit")).To(BeTrue())
 		})
-	})
-})
 
-func int32Ptr(i int32) *int32 {
-	return &i
+		It("should drop a rejected restart plan for good", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Approval.Required = true
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			request := SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(appsv1beta1.DefaultApprovalTTL))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			planID := secretsRefresh.Status.PendingRestarts[0].PlanID
+
+			By("Rejecting the plan")
+			secretsRefresh.Annotations = map[string]string{appsv1beta1.RejectAnnotation: planID}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+			Expect(secretsRefresh.Status.ApprovalHistory).To(HaveLen(1))
+			Expect(secretsRefresh.Status.ApprovalHistory[0].Decision).To(Equal(DecisionRejected))
+
+			By("Not proposing the rejected plan again, even after a metadata update of the secret")
+			delete(secretsRefresh.Annotations, appsv1beta1.RejectAnnotation)
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+			Expect(k8sClient.Get(ctx, request.NamespacedName, secret)).To(Succeed())
+			secret.Annotations = map[string]string{"example.com/owner": "payments"}
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+			Expect(k8sClient.Get(ctx, request.NamespacedName, secret)).To(Succeed())
+			Expect(restartPlanID(secret)).To(Equal(planID))
+
+			result, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+			Expect(secretsRefresh.Status.ApprovalHistory).To(HaveLen(1))
+
+			By("Proposing a new plan once the data changes again")
+			secret.StringData = map[string]string{"password": "another-password"}
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			Expect(secretsRefresh.Status.PendingRestarts[0].PlanID).NotTo(Equal(planID))
+		})
+
+		It("should skip disruptive restarts when the disruption policy is Skip", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.DisruptionPolicy = appsv1beta1.DisruptionPolicySkip
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, deployment)).To(Succeed())
+			deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			markRolledOut(ctx, client.ObjectKeyFromObject(deployment))
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+		})
+
+		It("should surge temporarily and restore the original strategy after the rollout", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.DisruptionPolicy = appsv1beta1.DisruptionPolicySurgeTemporarily
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, deployment)).To(Succeed())
+			deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			markRolledOut(ctx, client.ObjectKeyFromObject(deployment))
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Verifying the deployment was restarted with a surging rolling update")
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey("traktor.gdxcloud.net/restartedAt"))
+			Expect(updatedDeployment.Spec.Strategy.Type).To(Equal(appsv1.RollingUpdateDeploymentStrategyType))
+			Expect(updatedDeployment.Spec.Strategy.RollingUpdate.MaxUnavailable.IntValue()).To(Equal(0))
+			Expect(updatedDeployment.Spec.Strategy.RollingUpdate.MaxSurge.IntValue()).To(Equal(1))
+			Expect(updatedDeployment.Annotations).To(HaveKey(appsv1beta1.OriginalStrategyAnnotation))
+
+			restoreReconciler := &StrategyRestoreReconciler{Client: k8sClient}
+			restoreRequest := reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: deploymentName, Namespace: testNamespace},
+			}
+
+			By("Keeping the surge while the rollout is in progress")
+			result, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(rolloutRecheckInterval))
+
+			By("Restoring the strategy once the rollout has finished")
+			updatedDeployment.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: updatedDeployment.Generation,
+				Replicas:           1,
+				UpdatedReplicas:    1,
+				ReadyReplicas:      1,
+				AvailableReplicas:  1,
+			}
+			Expect(k8sClient.Status().Update(ctx, updatedDeployment)).To(Succeed())
+
+			result, err = restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Strategy.Type).To(Equal(appsv1.RecreateDeploymentStrategyType))
+			Expect(updatedDeployment.Spec.Strategy.RollingUpdate).To(BeNil())
+			Expect(updatedDeployment.Annotations).NotTo(HaveKey(appsv1beta1.OriginalStrategyAnnotation))
+		})
+
+		It("should restore the strategy of a deployment whose surged rollout failed", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.DisruptionPolicy = appsv1beta1.DisruptionPolicySurgeTemporarily
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			updatedDeployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+			Expect(k8sClient.Update(ctx, updatedDeployment)).To(Succeed())
+			markRolledOut(ctx, deploymentKey)
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Annotations).To(HaveKey(appsv1beta1.OriginalStrategyAnnotation))
+
+			restoreReconciler := &StrategyRestoreReconciler{Client: k8sClient}
+			restoreRequest := reconcile.Request{NamespacedName: deploymentKey}
+			progressDeadlineExceeded := []appsv1.DeploymentCondition{{
+				Type:   appsv1.DeploymentProgressing,
+				Status: corev1.ConditionFalse,
+				Reason: "ProgressDeadlineExceeded",
+			}}
+
+			By("Ignoring a failure the Deployment controller reported before the restart")
+			updatedDeployment.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: updatedDeployment.Generation - 1,
+				Replicas:           1,
+				Conditions:         progressDeadlineExceeded,
+			}
+			Expect(k8sClient.Status().Update(ctx, updatedDeployment)).To(Succeed())
+			result, err := restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(rolloutRecheckInterval))
+
+			By("Restoring the strategy once the rollout has failed")
+			updatedDeployment.Status.ObservedGeneration = updatedDeployment.Generation
+			updatedDeployment.Status.UpdatedReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, updatedDeployment)).To(Succeed())
+			result, err = restoreReconciler.Reconcile(ctx, restoreRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Strategy.Type).To(Equal(appsv1.RecreateDeploymentStrategyType))
+			Expect(updatedDeployment.Annotations).NotTo(HaveKey(appsv1beta1.OriginalStrategyAnnotation))
+		})
+
+		It("should defer restarts of paused and rolling out deployments", func() {
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			request := SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			}
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+
+			By("Pausing the deployment")
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Spec.Paused = true
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			markRolledOut(ctx, deploymentKey)
+
+			result, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(rolloutRecheckInterval))
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			Expect(secretsRefresh.Status.PendingRestarts[0].Reason).To(Equal(ReasonWorkloadPaused))
+			Expect(secretsRefresh.Status.PendingRestarts[0].Workloads).To(ConsistOf(appsv1beta1.WorkloadReference{
+				Kind: "Deployment", Name: deploymentName, Namespace: testNamespace,
+			}))
+
+			By("Resuming the deployment while its rollout is still in progress")
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Spec.Paused = false
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+
+			result, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(rolloutRecheckInterval))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts[0].Reason).To(Equal(ReasonRolloutInProgress))
+
+			By("Restarting once the rollout has finished")
+			markRolledOut(ctx, deploymentKey)
+			result, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey("traktor.gdxcloud.net/restartedAt"))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+
+			By("Not restarting again for the same version of the secret")
+			restartedAt := updatedDeployment.Spec.Template.Annotations["traktor.gdxcloud.net/restartedAt"]
+			markRolledOut(ctx, deploymentKey)
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations["traktor.gdxcloud.net/restartedAt"]).To(Equal(restartedAt))
+		})
+
+		It("should restart fully updated deployments whose pods aren't available", func() {
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			markRolledOut(ctx, deploymentKey)
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Status.ReadyReplicas = 0
+			deployment.Status.AvailableReplicas = 0
+			deployment.Status.UnavailableReplicas = deployment.Status.Replicas
+			deployment.Status.Conditions = []appsv1.DeploymentCondition{{
+				Type:   appsv1.DeploymentProgressing,
+				Status: corev1.ConditionTrue,
+				Reason: "ReplicaSetUpdated",
+			}}
+			Expect(k8sClient.Status().Update(ctx, deployment)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+		})
+
+		It("should only stamp the secret version on deployments scaled to zero", func() {
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Spec.Replicas = int32Ptr(0)
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+			Expect(secretVersions(updatedDeployment)).To(HaveKeyWithValue(secretName, secretVersion(secret)))
+		})
+
+		It("should defer restarts of recently restarted deployments until the cooldown passed", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Cooldown = &metav1.Duration{Duration: time.Hour}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			lastRestart := time.Now().Add(-5 * time.Minute).Format(time.RFC3339)
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Spec.Template.Annotations = map[string]string{appsv1beta1.RestartedAtAnnotation: lastRestart}
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			markRolledOut(ctx, deploymentKey)
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically("~", 55*time.Minute, time.Minute))
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKeyWithValue(appsv1beta1.RestartedAtAnnotation, lastRestart))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			Expect(secretsRefresh.Status.PendingRestarts[0].Reason).To(Equal(ReasonCooldown))
+		})
+
+		It("should pause restarts for a flapping secret until it is stable", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Triggers.FlapDetection = &appsv1beta1.FlapDetection{
+				MaxChanges: 2,
+				Window:     metav1.Duration{Duration: time.Minute},
+			}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			secretKey := types.NamespacedName{Name: secretName, Namespace: testNamespace}
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+
+			By("Changing the secret more often than allowed")
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			for range 3 {
+				controllerReconciler.secretChanges.record(secretKey, time.Now(), time.Minute)
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{NamespacedName: secretKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically("~", time.Minute, 5*time.Second))
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			Expect(secretsRefresh.Status.PendingRestarts[0].Reason).To(Equal(ReasonSecretFlapping))
+			Expect(meta.IsStatusConditionTrue(secretsRefresh.Status.Conditions, ConditionSecretFlapping)).To(BeTrue())
+
+			By("Resuming once the secret has been stable for a whole window")
+			controllerReconciler = &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			for range 3 {
+				controllerReconciler.secretChanges.record(secretKey, time.Now().Add(-2*time.Minute), time.Minute)
+			}
+
+			result, err = controllerReconciler.Reconcile(ctx, SecretRequest{NamespacedName: secretKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+			condition := meta.FindStatusCondition(secretsRefresh.Status.Conditions, ConditionSecretFlapping)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(ReasonSecretStable))
+		})
+
+		It("should restart with server-side apply under Traktor's field manager", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Strategy = appsv1beta1.RestartStrategyServerSideApply
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			managers := []string{}
+			for _, entry := range updatedDeployment.ManagedFields {
+				if entry.Operation == metav1.ManagedFieldsOperationApply {
+					managers = append(managers, entry.Manager)
+				}
+			}
+			Expect(managers).To(ConsistOf(appsv1beta1.DefaultFieldManager))
+		})
+
+		It("should recycle pods one at a time when the workload asks for DeletePods", func() {
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Annotations = map[string]string{
+				appsv1beta1.RestartStrategyAnnotation: string(appsv1beta1.RestartStrategyDeletePods),
+			}
+			deployment.Spec.Replicas = int32Ptr(2)
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			markRolledOut(ctx, deploymentKey)
+
+			By("Creating the pods of the deployment")
+			for i := range 2 {
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("%s-%d", deploymentName, i),
+						Namespace: testNamespace,
+						Labels:    map[string]string{"app": "test"},
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "nginx", Image: "nginx:alpine"}},
+					},
+				}
+				Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			}
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Verifying the pod template was left alone")
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+			Expect(updatedDeployment.Annotations).To(HaveKey(appsv1beta1.RecycleBeforeAnnotation))
+			Expect(updatedDeployment.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Evicting one pod per reconcile")
+			// Annotation changes bump the generation of a Deployment
+			markRolledOut(ctx, deploymentKey)
+			recycleReconciler := &PodRecycleReconciler{Client: k8sClient, APIReader: k8sClient}
+			podCount := func() int {
+				pods := &corev1.PodList{}
+				Expect(k8sClient.List(ctx, pods, client.InNamespace(testNamespace))).To(Succeed())
+				return len(pods.Items)
+			}
+			for remaining := 1; remaining >= 0; remaining-- {
+				result, err := recycleReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentKey})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(Equal(recycleInterval))
+				Eventually(podCount, timeout, interval).Should(Equal(remaining))
+			}
+
+			result, err := recycleReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Annotations).NotTo(HaveKey(appsv1beta1.RecycleBeforeAnnotation))
+		})
+
+		It("should scale the deployment to zero and back with ScaleBounce", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Strategy = appsv1beta1.RestartStrategyScaleBounce
+			secretsRefresh.Spec.Rollout.DisruptionPolicy = appsv1beta1.DisruptionPolicyAllow
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(*updatedDeployment.Spec.Replicas).To(BeZero())
+			Expect(updatedDeployment.Annotations).To(HaveKeyWithValue(appsv1beta1.ScaleBounceReplicasAnnotation, "1"))
+
+			By("Scaling back up once all pods are gone")
+			markRolledOut(ctx, deploymentKey)
+			recycleReconciler := &PodRecycleReconciler{Client: k8sClient, APIReader: k8sClient}
+			_, err = recycleReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(*updatedDeployment.Spec.Replicas).To(Equal(int32(1)))
+			Expect(updatedDeployment.Annotations).NotTo(HaveKey(appsv1beta1.ScaleBounceReplicasAnnotation))
+		})
+
+		It("should restart under the GitOps field manager in GitOps mode", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.GitOps = &appsv1beta1.GitOps{FieldManager: "traktor-gitops"}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey("traktor.gdxcloud.net/restartedAt"))
+			Expect(restartAnnotationOwners(updatedDeployment, []string{"traktor-gitops"})).To(ConsistOf("traktor-gitops"))
+		})
+
+		It("should recycle pods when the GitOps tool owns the restart annotation", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.GitOps = &appsv1beta1.GitOps{}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			By("Applying the restart annotation as Argo CD would when it is committed to Git")
+			apply := &unstructured.Unstructured{}
+			apply.SetAPIVersion("apps/v1")
+			apply.SetKind("Deployment")
+			apply.SetName(deploymentName)
+			apply.SetNamespace(testNamespace)
+			Expect(unstructured.SetNestedStringMap(apply.Object, map[string]string{
+				appsv1beta1.RestartedAtAnnotation: "2020-01-01T00:00:00Z",
+			}, "spec", "template", "metadata", "annotations")).To(Succeed())
+			Expect(k8sClient.Patch(ctx, apply, client.Apply, client.FieldOwner("argocd-controller"))).To(Succeed())
+			markRolledOut(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace})
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKeyWithValue(appsv1beta1.RestartedAtAnnotation, "2020-01-01T00:00:00Z"))
+			Expect(updatedDeployment.Annotations).To(HaveKey(appsv1beta1.RecycleBeforeAnnotation))
+		})
+
+		It("should call the reload URL of ready pods instead of restarting", func() {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost && r.URL.Path == "/-/reload" {
+					calls.Add(1)
+				}
+			}))
+			defer server.Close()
+
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			makeReloadable(ctx, deploymentKey, secretName, server)
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Waiting for the kubelet sync delay before reloading")
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(updatedDeployment.Annotations).To(HaveKey(appsv1beta1.SecretVersionsAnnotation))
+
+			reloadReconciler := &ReloadReconciler{Client: k8sClient, APIReader: k8sClient}
+			result, err := reloadReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", time.Minute))
+			Expect(calls.Load()).To(BeZero())
+
+			By("Reloading once the delay has passed")
+			expireReloadDelay(ctx, deploymentKey)
+			_, err = reloadReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(calls.Load()).To(Equal(int32(1)))
+
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Annotations).NotTo(HaveKey(appsv1beta1.ReloadAfterAnnotation))
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+		})
+
+		It("should restart the deployment when the reload keeps failing", func() {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer server.Close()
+
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			makeReloadable(ctx, deploymentKey, secretName, server)
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			expireReloadDelay(ctx, deploymentKey)
+
+			reloadReconciler := &ReloadReconciler{Client: k8sClient, APIReader: k8sClient}
+			_, err = reloadReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(calls.Load()).To(BeNumerically("==", reloadBackoff.Steps))
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Annotations).NotTo(HaveKey(appsv1beta1.ReloadAfterAnnotation))
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.SecretVersionsAnnotation))
+		})
+
+		It("should restart the deployment when no pod is ready to reload", func() {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+			}))
+			defer server.Close()
+
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			makeReloadable(ctx, deploymentKey, secretName, server)
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName + "-reload", Namespace: testNamespace}, pod)).To(Succeed())
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			expireReloadDelay(ctx, deploymentKey)
+
+			recorder := record.NewFakeRecorder(10)
+			reloadReconciler := &ReloadReconciler{Client: k8sClient, APIReader: k8sClient, Recorder: recorder}
+			_, err = reloadReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(calls.Load()).To(BeZero())
+			Expect(recorder.Events).To(Receive(ContainSubstring("no ready pod to reload")))
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Annotations).NotTo(HaveKey(appsv1beta1.ReloadAfterAnnotation))
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+		})
+
+		It("should only reload deployments that mount the secret", func() {
+			d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{appsv1beta1.ReloadURLAnnotation: "http://:8080/-/reload"},
+			}}
+			Expect(reloadable(d, []string{ReferenceVolume})).To(BeTrue())
+			Expect(reloadable(d, []string{ReferenceVolume, ReferenceEnv})).To(BeFalse())
+			Expect(reloadable(d, []string{ReferenceSubPath})).To(BeFalse())
+			Expect(reloadable(&appsv1.Deployment{}, []string{ReferenceVolume})).To(BeFalse())
+		})
+
+		It("should run actions before and after the restarts", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Actions = []appsv1beta1.Action{
+				{
+					Name:   "sync-users",
+					Order:  appsv1beta1.ActionOrderBefore,
+					RunJob: &appsv1beta1.RunJobAction{JobTemplate: actionJobTemplate()},
+				},
+				{
+					Name:   "prime-cache",
+					Order:  appsv1beta1.ActionOrderAfter,
+					RunJob: &appsv1beta1.RunJobAction{JobTemplate: actionJobTemplate()},
+				},
+			}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: jobTemplatesConfig(),
+			}
+			request := SecretRequest{NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace}}
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+
+			By("Waiting for the Job ordered before the restarts")
+			result, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(actionRecheckInterval))
+
+			before := actionJobs(ctx, testNamespace, "sync-users")
+			Expect(before).To(HaveLen(1))
+			Expect(before[0].Annotations).To(HaveKeyWithValue(appsv1beta1.ActionSecretAnnotation, testNamespace+"/"+secretName))
+			Expect(actionJobs(ctx, testNamespace, "prime-cache")).To(BeEmpty())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Restarting once it succeeded and starting the Job ordered after the restarts")
+			finishJob(ctx, &before[0], true)
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(actionJobs(ctx, testNamespace, "sync-users")).To(HaveLen(1))
+			Expect(actionJobs(ctx, testNamespace, "prime-cache")).To(HaveLen(1))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			results := map[string]string{}
+			for _, run := range secretsRefresh.Status.ActionRuns {
+				results[run.Action] = run.Result
+			}
+			Expect(results).To(Equal(map[string]string{
+				"sync-users":  ActionResultSucceeded,
+				"prime-cache": ActionResultRunning,
+			}))
+		})
+
+		It("should block the restarts when an action before them fails", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Actions = []appsv1beta1.Action{
+				{
+					Name:   "sync-users",
+					Order:  appsv1beta1.ActionOrderBefore,
+					RunJob: &appsv1beta1.RunJobAction{JobTemplate: actionJobTemplate()},
+				},
+				{
+					Name:   "prime-cache",
+					RunJob: &appsv1beta1.RunJobAction{JobTemplate: actionJobTemplate()},
+				},
+			}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: jobTemplatesConfig(),
+			}
+			request := SecretRequest{NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace}}
+			_, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			before := actionJobs(ctx, testNamespace, "sync-users")
+			Expect(before).To(HaveLen(1))
+			finishJob(ctx, &before[0], false)
+			result, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(actionJobs(ctx, testNamespace, "prime-cache")).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.ActionRuns).To(HaveLen(1))
+			Expect(secretsRefresh.Status.ActionRuns[0].Result).To(Equal(ActionResultFailed))
+			Expect(secretsRefresh.Status.ActionRuns[0].Message).To(ContainSubstring("BackoffLimitExceeded"))
+		})
+
+		It("should not run actions for a rejected restart plan", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Approval.Required = true
+			secretsRefresh.Spec.Actions = []appsv1beta1.Action{
+				{
+					Name:   "sync-users",
+					Order:  appsv1beta1.ActionOrderBefore,
+					RunJob: &appsv1beta1.RunJobAction{JobTemplate: actionJobTemplate()},
+				},
+				{
+					Name:   "prime-cache",
+					Order:  appsv1beta1.ActionOrderAfter,
+					RunJob: &appsv1beta1.RunJobAction{JobTemplate: actionJobTemplate()},
+				},
+			}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: jobTemplatesConfig(),
+			}
+			request := SecretRequest{NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace}}
+
+			_, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+
+			By("Rejecting the plan")
+			secretsRefresh.Annotations = map[string]string{appsv1beta1.RejectAnnotation: secretsRefresh.Status.PendingRestarts[0].PlanID}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actionJobs(ctx, testNamespace, "sync-users")).To(BeEmpty())
+			Expect(actionJobs(ctx, testNamespace, "prime-cache")).To(BeEmpty())
+
+			By("Not running them when the rejected change is handled again")
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actionJobs(ctx, testNamespace, "sync-users")).To(BeEmpty())
+			Expect(actionJobs(ctx, testNamespace, "prime-cache")).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.ActionRuns).To(BeEmpty())
+		})
+
+		It("should instantiate the job template of a CronJob in the namespace of the SecretsRefresh", func() {
+			cronJob := &batchv1.CronJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "db-sync", Namespace: "default"},
+				Spec: batchv1.CronJobSpec{
+					Schedule:    "0 3 * * *",
+					JobTemplate: *actionJobTemplate(),
+				},
+			}
+			Expect(k8sClient.Create(ctx, cronJob)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, cronJob)).To(Succeed())
+				Expect(k8sClient.DeleteAllOf(ctx, &batchv1.Job{}, client.InNamespace("default"),
+					client.MatchingLabels{appsv1beta1.ActionLabel: "db-sync"}, client.PropagationPolicy(metav1.DeletePropagationBackground))).To(Succeed())
+			})
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Actions = []appsv1beta1.Action{{
+				Name:   "db-sync",
+				RunJob: &appsv1beta1.RunJobAction{CronJobRef: &appsv1beta1.CronJobReference{Name: "db-sync"}},
+			}}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Resolving the CronJob in the namespace of the SecretsRefresh, not of the secret")
+			Expect(actionJobs(ctx, testNamespace, "db-sync")).To(BeEmpty())
+			jobs := actionJobs(ctx, "default", "db-sync")
+			Expect(jobs).To(HaveLen(1))
+			Expect(jobs[0].Annotations).To(HaveKeyWithValue("cronjob.kubernetes.io/instantiate", "manual"))
+			Expect(jobs[0].OwnerReferences).To(HaveLen(1))
+			Expect(jobs[0].OwnerReferences[0].Name).To(Equal("db-sync"))
+		})
+
+		It("should refuse inline job templates of namespaced SecretsRefresh objects by default", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Actions = []appsv1beta1.Action{{
+				Name:   "sync-users",
+				Order:  appsv1beta1.ActionOrderBefore,
+				RunJob: &appsv1beta1.RunJobAction{JobTemplate: actionJobTemplate()},
+			}}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actionJobs(ctx, testNamespace, "sync-users")).To(BeEmpty())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.ActionRuns).To(HaveLen(1))
+			Expect(secretsRefresh.Status.ActionRuns[0].Result).To(Equal(ActionResultFailed))
+			Expect(secretsRefresh.Status.ActionRuns[0].Message).To(ContainSubstring("not permitted"))
+		})
+
+		It("should not overwrite a Secret that has the name of the snapshot", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Rollback = &appsv1beta1.Rollback{}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			foreign := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        secretName + snapshotSuffix,
+					Namespace:   testNamespace,
+					Annotations: map[string]string{appsv1beta1.SnapshotOfAnnotation: secretName},
+				},
+				StringData: map[string]string{"token": "someone-elses"},
+			}
+			Expect(k8sClient.Create(ctx, foreign)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, foreign))).To(Succeed())
+			})
+
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+			}
+			secretKey := types.NamespacedName{Name: secretName, Namespace: testNamespace}
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			controllerReconciler.previousSecrets.store(secret)
+			secret.Data["password"] = []byte("rotated-password")
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{NamespacedName: secretKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning RollbackUnavailable")))
+
+			By("Leaving the foreign Secret alone and restarting without a rollback")
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(foreign), foreign)).To(Succeed())
+			Expect(foreign.Data).To(Equal(map[string][]byte{"token": []byte("someone-elses")}))
+			Expect(foreign.Annotations).NotTo(HaveKey(appsv1beta1.SnapshotForVersionAnnotation))
+			Expect(foreign.OwnerReferences).To(BeEmpty())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.RolloutChecks).To(BeEmpty())
+		})
+
+		It("should roll back a secret change whose restarted workloads fail", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Rollback = &appsv1beta1.Rollback{}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			secretKey := types.NamespacedName{Name: secretName, Namespace: testNamespace}
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+
+			By("Rotating the secret to data that breaks the deployment")
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			controllerReconciler.previousSecrets.store(secret)
+			secret.Data["password"] = []byte("broken-password")
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{NamespacedName: secretKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			snapshot := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName + snapshotSuffix, Namespace: testNamespace}, snapshot)).To(Succeed())
+			Expect(snapshot.Data).To(HaveKeyWithValue("password", []byte("initial-password")))
+			Expect(metav1.GetControllerOf(snapshot)).NotTo(BeNil())
+			Expect(metav1.GetControllerOf(snapshot).Name).To(Equal(secretName))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.RolloutChecks).To(HaveLen(1))
+
+			By("Failing the rollout of the restarted deployment")
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: deployment.Generation,
+				Replicas:           1,
+				UpdatedReplicas:    1,
+				Conditions: []appsv1.DeploymentCondition{{
+					Type:   appsv1.DeploymentProgressing,
+					Status: corev1.ConditionFalse,
+					Reason: "ProgressDeadlineExceeded",
+				}},
+			}
+			Expect(k8sClient.Status().Update(ctx, deployment)).To(Succeed())
+
+			rollbackReconciler := &RollbackReconciler{Client: k8sClient}
+			_, err = rollbackReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: secretsRefreshName, Namespace: "default"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Verifying the previous data was restored and the snapshot kept")
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			Expect(secret.Data).To(HaveKeyWithValue("password", []byte("initial-password")))
+			Expect(secret.Annotations).To(HaveKey(appsv1beta1.RolledBackToAnnotation))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(snapshot), snapshot)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.RolloutChecks).To(BeEmpty())
+			Expect(secretsRefresh.Status.RollbackHistory).To(HaveLen(1))
+			Expect(secretsRefresh.Status.RollbackHistory[0].FailedWorkloads).To(ConsistOf(appsv1beta1.WorkloadReference{
+				Kind: "Deployment", Name: deploymentName, Namespace: testNamespace,
+			}))
+
+			By("Restarting again for the restored data without tracking the rollback")
+			markRolledOut(ctx, deploymentKey)
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			brokenRestart := deployment.Spec.Template.Annotations[appsv1beta1.SecretVersionsAnnotation]
+			_, err = controllerReconciler.Reconcile(ctx, SecretRequest{NamespacedName: secretKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Annotations[appsv1beta1.SecretVersionsAnnotation]).NotTo(Equal(brokenRestart))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.RolloutChecks).To(BeEmpty())
+		})
+
+		It("should keep a secret change whose restarted workloads roll out", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Rollback = &appsv1beta1.Rollback{}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			secretKey := types.NamespacedName{Name: secretName, Namespace: testNamespace}
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			controllerReconciler.previousSecrets.store(secret)
+			secret.Data["password"] = []byte("rotated-password")
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{NamespacedName: secretKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			rollbackReconciler := &RollbackReconciler{Client: k8sClient}
+			srKey := types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}
+			result, err := rollbackReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: srKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(rolloutRecheckInterval))
+
+			markRolledOut(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace})
+			result, err = rollbackReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: srKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			Expect(secret.Data).To(HaveKeyWithValue("password", []byte("rotated-password")))
+			Expect(k8sClient.Get(ctx, srKey, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.RolloutChecks).To(BeEmpty())
+			Expect(secretsRefresh.Status.RollbackHistory).To(BeEmpty())
+		})
+
+		It("should classify the disruption risk of deployment strategies", func() {
+			maxSurgeZero := intstr.FromInt32(0)
+			maxUnavailableOne := intstr.FromInt32(1)
+			withStrategy := func(replicas int32, strategy appsv1.DeploymentStrategy) *appsv1.Deployment {
+				return &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: int32Ptr(replicas), Strategy: strategy}}
+			}
+			risk := func(d *appsv1.Deployment) string {
+				return disruptionRisk(d, appsv1beta1.RestartStrategyTemplateAnnotation)
+			}
+
+			Expect(risk(withStrategy(3, appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}))).
+				To(ContainSubstring("Recreate"))
+			Expect(risk(withStrategy(1, appsv1.DeploymentStrategy{
+				Type:          appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{MaxUnavailable: &maxUnavailableOne},
+			}))).To(ContainSubstring("maxUnavailable"))
+			Expect(risk(withStrategy(3, appsv1.DeploymentStrategy{
+				Type:          appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: &maxSurgeZero},
+			}))).To(ContainSubstring("maxSurge"))
+			Expect(risk(withStrategy(1, appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType}))).
+				To(BeEmpty())
+			Expect(risk(withStrategy(0, appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}))).
+				To(BeEmpty())
+
+			By("Classifying strategies that don't roll out the pod template")
+			rolling := withStrategy(3, appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType})
+			Expect(disruptionRisk(rolling, appsv1beta1.RestartStrategyScaleBounce)).To(ContainSubstring("ScaleBounce"))
+			Expect(disruptionRisk(rolling, appsv1beta1.RestartStrategyDeletePods)).To(BeEmpty())
+			Expect(disruptionRisk(withStrategy(1, appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType}),
+				appsv1beta1.RestartStrategyDeletePods)).To(ContainSubstring("only pod"))
+		})
+
+		It("should only record restarts when the SecretsRefresh is in dry run", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.DryRun = true
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Verifying the deployment was not patched")
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			By("Verifying the would-be restart is recorded in status")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.DryRunRestarts).To(HaveLen(1))
+			restart := secretsRefresh.Status.DryRunRestarts[0]
+			Expect(restart.Kind).To(Equal("Deployment"))
+			Expect(restart.Name).To(Equal(deploymentName))
+			Expect(restart.Namespace).To(Equal(testNamespace))
+			Expect(restart.SecretName).To(Equal(secretName))
+			Expect(restart.References).To(Equal([]string{ReferenceEnv}))
+		})
+
+		It("should not patch anything when dry run is enabled globally", func() {
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				DryRun: true,
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.DryRunRestarts).To(HaveLen(1))
+		})
+
+		It("should only record deferrals in dry run, without leaving restarts pending", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.DryRun = true
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			By("Freezing the test namespace")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: testNamespace}, namespace)).To(Succeed())
+			namespace.Annotations[appsv1beta1.FreezeAnnotation] = "true"
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey("traktor.gdxcloud.net/restartedAt"))
+
+			By("Verifying the deferral is recorded as a dry run restart only")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+			Expect(secretsRefresh.Status.DryRunRestarts).To(HaveLen(1))
+			Expect(secretsRefresh.Status.DryRunRestarts[0].Name).To(Equal(deploymentName))
+			Expect(secretsRefresh.Status.DryRunRestarts[0].DeferredBy).To(Equal(ReasonChangeFreeze))
+			Expect(secretsRefresh.Status.DryRunRestarts[0].NotBefore).To(BeNil())
+
+			By("Verifying a flapping secret isn't paused either")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: testNamespace}, namespace)).To(Succeed())
+			delete(namespace.Annotations, appsv1beta1.FreezeAnnotation)
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Triggers.FlapDetection = &appsv1beta1.FlapDetection{
+				MaxChanges: 1,
+				Window:     metav1.Duration{Duration: time.Minute},
+			}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			key := types.NamespacedName{Name: secretName, Namespace: testNamespace}
+			now := time.Now()
+			controllerReconciler.secretChanges.record(key, now.Add(-2*time.Second), time.Minute)
+			controllerReconciler.secretChanges.record(key, now.Add(-time.Second), time.Minute)
+
+			result, err = controllerReconciler.Reconcile(ctx, SecretRequest{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+			Expect(meta.FindStatusCondition(secretsRefresh.Status.Conditions, ConditionSecretFlapping)).To(BeNil())
+			Expect(secretsRefresh.Status.DryRunRestarts).To(HaveLen(2))
+			Expect(secretsRefresh.Status.DryRunRestarts[1].DeferredBy).To(Equal(ReasonSecretFlapping))
+			Expect(secretsRefresh.Status.DryRunRestarts[1].NotBefore).NotTo(BeNil())
+		})
+
+		It("should record workloads a dry run would defer for their cooldown", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.DryRun = true
+			secretsRefresh.Spec.Rollout.Cooldown = &metav1.Duration{Duration: time.Hour}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			lastRestart := time.Now().Add(-5 * time.Minute).Format(time.RFC3339)
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Spec.Template.Annotations = map[string]string{appsv1beta1.RestartedAtAnnotation: lastRestart}
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			markRolledOut(ctx, deploymentKey)
+
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+			}
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(BeEmpty())
+			Expect(secretsRefresh.Status.DryRunRestarts).To(HaveLen(1))
+			Expect(secretsRefresh.Status.DryRunRestarts[0].Name).To(Equal(deploymentName))
+			Expect(secretsRefresh.Status.DryRunRestarts[0].DeferredBy).To(Equal(ReasonCooldown))
+			Expect(secretsRefresh.Status.DryRunRestarts[0].NotBefore).NotTo(BeNil())
+			Expect(secretsRefresh.Status.DryRunRestarts[0].NotBefore.Time).To(BeTemporally("~", time.Now().Add(55*time.Minute), time.Minute))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Would defer restart of Deployment " + testNamespace + "/" + deploymentName)))
+		})
+
+		It("should report every way a deployment references a secret", func() {
+			d := &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Volumes: []corev1.Volume{{
+								Name:         "creds",
+								VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "db"}},
+							}},
+							InitContainers: []corev1.Container{{
+								Name: "init",
+								EnvFrom: []corev1.EnvFromSource{{
+									SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}},
+								}},
+							}},
+							ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+						},
+					},
+				},
+			}
+
+			Expect(secretReferences(d, "db")).To(Equal([]string{ReferenceVolume, ReferenceEnvFrom}))
+			Expect(secretReferences(d, "registry")).To(Equal([]string{ReferenceImagePullSecret}))
+			Expect(secretReferences(d, "other")).To(BeEmpty())
+		})
+
+		It("should tell subPath mounts apart from volumes the kubelet refreshes", func() {
+			d := &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Volumes: []corev1.Volume{
+								{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "tls"}}},
+								{Name: "config", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "config"}}},
+							},
+							Containers: []corev1.Container{{
+								Name: "app",
+								VolumeMounts: []corev1.VolumeMount{
+									{Name: "tls", MountPath: "/etc/tls"},
+									{Name: "config", MountPath: "/etc/app/config.yaml", SubPath: "config.yaml"},
+								},
+							}},
+						},
+					},
+				},
+			}
+
+			Expect(secretReferences(d, "tls")).To(Equal([]string{ReferenceVolume}))
+			Expect(secretReferences(d, "config")).To(Equal([]string{ReferenceSubPath}))
+
+			By("Restarting hot-reloading workloads only for references that don't update in place")
+			Expect(restartRequired(d, []string{ReferenceVolume})).To(BeTrue())
+			d.Annotations = map[string]string{appsv1beta1.HotReloadAnnotation: "true"}
+			Expect(restartRequired(d, []string{ReferenceVolume})).To(BeFalse())
+			Expect(restartRequired(d, []string{ReferenceSubPath})).To(BeTrue())
+			Expect(restartRequired(d, []string{ReferenceVolume, ReferenceEnv})).To(BeTrue())
+		})
+
+		It("should not restart hot-reloading deployments for volume mounted secrets", func() {
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, deployment)).To(Succeed())
+			deployment.Annotations = map[string]string{appsv1beta1.HotReloadAnnotation: "true"}
+			deployment.Spec.Template.Spec.Containers[0].Env = nil
+			deployment.Spec.Template.Spec.Volumes = []corev1.Volume{{
+				Name:         "secret",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretName}},
+			}}
+			deployment.Spec.Template.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: "secret", MountPath: "/etc/secret"}}
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			markRolledOut(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace})
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Restarting once the secret is mounted through subPath")
+			updatedDeployment.Spec.Template.Spec.Containers[0].VolumeMounts[0].SubPath = "password"
+			Expect(k8sClient.Update(ctx, updatedDeployment)).To(Succeed())
+			markRolledOut(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace})
+
+			_, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+		})
+
+		It("should switch workloads to the newest version of a versioned secret", func() {
+			sr := &appsv1beta1.SecretsRefresh{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, sr)).To(Succeed())
+			sr.Spec.Triggers.VersionedSecrets = &appsv1beta1.VersionedSecrets{Retention: &metav1.Duration{Duration: time.Hour}}
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+
+			immutable := true
+			versions := make([]string, 0, 2)
+			for _, suffix := range []string{"-v1", "-v2"} {
+				version := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName + suffix,
+						Namespace: testNamespace,
+						Labels: map[string]string{
+							"auto-refresh":                 "enabled",
+							appsv1beta1.DefaultSeriesLabel: "app-db",
+						},
+					},
+					StringData: map[string]string{"password": "password" + suffix},
+					Immutable:  &immutable,
+				}
+				Expect(k8sClient.Create(ctx, version)).To(Succeed())
+				versions = append(versions, version.Name)
+			}
+
+			By("Referencing the old version from the deployment")
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, deployment)).To(Succeed())
+			deployment.Spec.Template.Spec.Containers[0].Env[0].ValueFrom.SecretKeyRef.Name = versions[0]
+			deployment.Spec.Template.Spec.Volumes = []corev1.Volume{{
+				Name:         "db",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: versions[0]}},
+			}}
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			markRolledOut(ctx, client.ObjectKeyFromObject(deployment))
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("Only watching the creation of secrets with the series label")
+			Expect(controllerReconciler.hasSeriesLabel(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{appsv1beta1.DefaultSeriesLabel: "app-db"},
+			}})).To(BeTrue())
+			Expect(controllerReconciler.hasSeriesLabel(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"auto-refresh": "enabled"},
+			}})).To(BeFalse())
+
+			By("Leaving the deployment alone for the old version")
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: versions[0], Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Rewriting the references to the newest version")
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: versions[1], Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Env[0].ValueFrom.SecretKeyRef.Name).To(Equal(versions[1]))
+			Expect(deployment.Spec.Template.Spec.Volumes[0].Secret.SecretName).To(Equal(versions[1]))
+			Expect(deployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Keeping the old version during the retention period")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: versions[0], Namespace: testNamespace}, &corev1.Secret{})).To(Succeed())
+
+			By("Deleting the unreferenced old version once the retention period has passed")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, sr)).To(Succeed())
+			sr.Spec.Triggers.VersionedSecrets.Retention = &metav1.Duration{}
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+			result, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: versions[1], Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: versions[0], Namespace: testNamespace}, &corev1.Secret{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: versions[1], Namespace: testNamespace}, &corev1.Secret{})).To(Succeed())
+		})
+
+		It("should keep old secret versions other workloads still use", func() {
+			sr := &appsv1beta1.SecretsRefresh{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, sr)).To(Succeed())
+			sr.Spec.Triggers.VersionedSecrets = &appsv1beta1.VersionedSecrets{Retention: &metav1.Duration{}}
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+
+			immutable := true
+			versions := make([]string, 0, 2)
+			for _, suffix := range []string{"-v1", "-v2"} {
+				version := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName + suffix,
+						Namespace: testNamespace,
+						Labels: map[string]string{
+							"auto-refresh":                 "enabled",
+							appsv1beta1.DefaultSeriesLabel: "app-db",
+						},
+					},
+					StringData: map[string]string{"password": "password" + suffix},
+					Immutable:  &immutable,
+				}
+				Expect(k8sClient.Create(ctx, version)).To(Succeed())
+				versions = append(versions, version.Name)
+			}
+
+			By("Referencing the old version from a StatefulSet")
+			statefulSet := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: testNamespace},
+				Spec: appsv1.StatefulSetSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "db", Image: "postgres"}},
+							Volumes: []corev1.Volume{{
+								Name:         "db",
+								VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: versions[0]}},
+							}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, statefulSet)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client:    k8sClient,
+				Scheme:    k8sClient.Scheme(),
+				APIReader: k8sClient,
+			}
+			result, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: versions[1], Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(versionRecheckInterval))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: versions[0], Namespace: testNamespace}, &corev1.Secret{})).To(Succeed())
+
+			By("Deleting the old version once the StatefulSet is gone")
+			Expect(k8sClient.Delete(ctx, statefulSet)).To(Succeed())
+			result, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: versions[1], Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: versions[0], Namespace: testNamespace}, &corev1.Secret{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should report conditions, matched counts and refreshes in the status", func() {
+			sr := &appsv1beta1.SecretsRefresh{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, sr)).To(Succeed())
+			sr.Spec.Targets.NamespaceSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{"kubernetes.io/metadata.name": testNamespace},
+			}
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+
+			statusReconciler := &SecretsRefreshStatusReconciler{Client: k8sClient}
+			srKey := types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}
+			result, err := statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: srKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(statusResyncInterval))
+
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			Expect(sr.Status.ObservedGeneration).To(Equal(sr.Generation))
+			Expect(sr.Status.MatchedNamespaces).To(BeEquivalentTo(1))
+			Expect(sr.Status.MatchedSecrets).To(BeEquivalentTo(1))
+			Expect(sr.Status.MatchedWorkloads).To(BeEquivalentTo(1))
+			Expect(meta.IsStatusConditionTrue(sr.Status.Conditions, appsv1beta1.ConditionReady)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(sr.Status.Conditions, appsv1beta1.ConditionDegraded)).To(BeTrue())
+
+			By("Recording the refresh of a secret change")
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			Expect(sr.Status.LastRefreshTime).NotTo(BeNil())
+			Expect(sr.Status.RefreshHistory).To(HaveLen(1))
+			Expect(sr.Status.RefreshHistory[0].Result).To(Equal(appsv1beta1.RefreshResultSucceeded))
+			Expect(sr.Status.RefreshHistory[0].Workloads).To(ConsistOf(appsv1beta1.WorkloadReference{
+				Kind: "Deployment", Name: deploymentName, Namespace: testNamespace,
+			}))
+
+			By("Not recording the same change twice")
+			_, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			Expect(sr.Status.RefreshHistory).To(HaveLen(1))
+
+			By("Degrading on an invalid spec")
+			sr.Spec.Triggers.RestartWhen = "workload.metadata.name =="
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+			_, err = statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: srKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			Expect(sr.Status.ObservedGeneration).To(Equal(sr.Generation))
+			Expect(meta.IsStatusConditionFalse(sr.Status.Conditions, appsv1beta1.ConditionReady)).To(BeTrue())
+			degraded := meta.FindStatusCondition(sr.Status.Conditions, appsv1beta1.ConditionDegraded)
+			Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal(ReasonInvalidRestartWhen))
+		})
+
+		It("should post refreshes to the notifiers of the TraktorConfig", func() {
+			var received atomic.Pointer[Notification]
+			var tenantPosted atomic.Bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/all":
+					notification := &Notification{}
+					if json.NewDecoder(r.Body).Decode(notification) == nil {
+						received.Store(notification)
+					}
+				case "/tenant":
+					tenantPosted.Store(true)
+				case "/broken":
+					w.WriteHeader(http.StatusInternalServerError)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			sr := &appsv1beta1.SecretsRefresh{}
+			srKey := types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			sr.Spec.Targets.NamespaceSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{"kubernetes.io/metadata.name": testNamespace},
+			}
+			// Only a ClusterSecretsRefresh may choose where the operator posts to
+			sr.Spec.Notifications = &appsv1beta1.Notifications{
+				Webhooks: []appsv1beta1.NotificationWebhook{{Name: "tenant", URL: server.URL + "/tenant"}},
+			}
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+
+			operatorConfig := &OperatorConfig{}
+			operatorConfig.set(&appsv1beta1.TraktorConfigSpec{
+				Notifiers: []appsv1beta1.NotificationWebhook{
+					{Name: "all", URL: server.URL + "/all"},
+					{Name: "failures", URL: server.URL + "/failures", Results: []string{appsv1beta1.RefreshResultFailed}},
+					{Name: "broken", URL: server.URL + "/broken"},
+				},
+			})
+			recorder := record.NewFakeRecorder(10)
+			notifier := &Notifier{Recorder: recorder}
+			notifierCtx, stopNotifier := context.WithCancel(ctx)
+			DeferCleanup(stopNotifier)
+			go func() {
+				defer GinkgoRecover()
+				Expect(notifier.Start(notifierCtx)).To(Succeed())
+			}()
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+				Config:   operatorConfig,
+				Notifier: notifier,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(received.Load, timeout, interval).ShouldNot(BeNil())
+			notification := received.Load()
+			Expect(notification.Kind).To(Equal("SecretsRefresh"))
+			Expect(notification.Name).To(Equal(secretsRefreshName))
+			Expect(notification.Namespace).To(Equal("default"))
+			Expect(notification.Refresh.SecretName).To(Equal(secretName))
+			Expect(notification.Refresh.Result).To(Equal(appsv1beta1.RefreshResultSucceeded))
+			Expect(notification.Refresh.Workloads).To(ConsistOf(appsv1beta1.WorkloadReference{
+				Kind: "Deployment", Name: deploymentName, Namespace: testNamespace,
+			}))
+			Expect(tenantPosted.Load()).To(BeFalse())
+
+			By("Reporting the webhook that didn't accept it, but not the filtered one")
+			var events []string
+			Eventually(func() []string {
+				for len(recorder.Events) > 0 {
+					events = append(events, <-recorder.Events)
+				}
+				return events
+			}, timeout, interval).Should(ContainElement(HavePrefix("Warning " + ReasonNotificationFailed + " Notification webhook broken failed")))
+			Expect(events).NotTo(ContainElement(ContainSubstring("webhook failures")))
+		})
+
+		It("should not hold up restarts while a notifier is slow", func() {
+			release := make(chan struct{})
+			var posted atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+				posted.Add(1)
+			}))
+			DeferCleanup(server.Close)
+			DeferCleanup(func() { close(release) })
+
+			operatorConfig := &OperatorConfig{}
+			operatorConfig.set(&appsv1beta1.TraktorConfigSpec{
+				Notifiers: []appsv1beta1.NotificationWebhook{{Name: "slow", URL: server.URL}},
+			})
+			notifier := &Notifier{}
+			notifierCtx, stopNotifier := context.WithCancel(ctx)
+			DeferCleanup(stopNotifier)
+			go func() {
+				defer GinkgoRecover()
+				Expect(notifier.Start(notifierCtx)).To(Succeed())
+			}()
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Config:   operatorConfig,
+				Notifier: notifier,
+			}
+
+			started := time.Now()
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(time.Since(started)).To(BeNumerically("<", notificationTimeout))
+
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(posted.Load()).To(BeZero())
+
+			By("Dropping notifications once the queue is full instead of blocking")
+			for range notificationQueueSize + 1 {
+				notifier.enqueue(notificationDelivery{sr: secretsRefresh, hook: appsv1beta1.NotificationWebhook{Name: "slow", URL: server.URL}})
+			}
+			Expect(notifier.enqueue(notificationDelivery{sr: secretsRefresh})).To(BeFalse())
+		})
+
+		It("should apply only the policies of the SecretsRefresh objects carried by the request", func() {
+			other := &appsv1beta1.SecretsRefresh{
+				ObjectMeta: metav1.ObjectMeta{Name: secretsRefreshName + "-dry", Namespace: "default"},
+				Spec: appsv1beta1.SecretsRefreshSpec{
+					Targets: appsv1beta1.Targets{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"kubernetes.io/metadata.name": testNamespace},
+						},
+					},
+					Rollout: appsv1beta1.Rollout{DryRun: true},
+				},
+			}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, other))).To(Succeed())
+			})
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("Mapping the secret to one request carrying every matching SecretsRefresh")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+			requests := controllerReconciler.findSecretsRefreshForSecret(ctx, secret)
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].NamespacedName).To(Equal(client.ObjectKeyFromObject(secret)))
+			Expect(requests[0].secretsRefreshKeys()).To(ContainElements(
+				types.NamespacedName{Name: secretsRefreshName, Namespace: "default"},
+				client.ObjectKeyFromObject(other),
+			))
+
+			By("Restarting for a request that only carries the SecretsRefresh without dry run")
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName:   client.ObjectKeyFromObject(secret),
+				SecretsRefreshes: "default/" + secretsRefreshName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+		})
+
+		It("should stop running action Jobs when a SecretsRefresh is deleted", func() {
+			sr := &appsv1beta1.SecretsRefresh{}
+			srKey := types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			sr.Spec.Actions = []appsv1beta1.Action{{
+				Name:   "sync-users",
+				Order:  appsv1beta1.ActionOrderAfter,
+				RunJob: &appsv1beta1.RunJobAction{JobTemplate: actionJobTemplate()},
+			}}
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+
+			statusReconciler := &SecretsRefreshStatusReconciler{Client: k8sClient}
+			_, err := statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: srKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			Expect(sr.Finalizers).To(ContainElement(SecretsRefreshFinalizer))
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: jobTemplatesConfig(),
+			}
+			_, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actionJobs(ctx, testNamespace, "sync-users")).To(HaveLen(1))
+
+			By("Deleting the running Job before removing the finalizer")
+			Expect(k8sClient.Delete(ctx, sr)).To(Succeed())
+			_, err = statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: srKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actionJobs(ctx, testNamespace, "sync-users")).To(BeEmpty())
+			err = k8sClient.Get(ctx, srKey, sr)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should restrict SecretsRefresh objects to namespaces granting them access", func() {
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			srKey := types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}
+			matches := func() bool {
+				GinkgoHelper()
+				sr := &appsv1beta1.SecretsRefresh{}
+				Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+				matched, err := controllerReconciler.secretsRefreshMatches(ctx, sr, secret)
+				Expect(err).NotTo(HaveOccurred())
+				return matched
+			}
+			grant := func(value string) {
+				GinkgoHelper()
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: testNamespace}, namespace)).To(Succeed())
+				namespace.Annotations[appsv1beta1.AllowSecretsRefreshFromAnnotation] = value
+				Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+			}
+
+			Expect(matches()).To(BeTrue())
+
+			By("Ignoring namespaces that don't grant access")
+			grant("")
+			Expect(matches()).To(BeFalse())
+			grant("kube-system, default/" + secretsRefreshName + "-other")
+			Expect(matches()).To(BeFalse())
+
+			By("Matching namespaces that grant access to the SecretsRefresh by name")
+			grant("kube-system, default/" + secretsRefreshName)
+			Expect(matches()).To(BeTrue())
+		})
+
+		It("should let a ClusterSecretsRefresh select any namespace", func() {
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: testNamespace}, namespace)).To(Succeed())
+			delete(namespace.Annotations, appsv1beta1.AllowSecretsRefreshFromAnnotation)
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+
+			csr := &appsv1beta1.ClusterSecretsRefresh{
+				ObjectMeta: metav1.ObjectMeta{Name: secretsRefreshName + "-cluster"},
+				Spec: appsv1beta1.SecretsRefreshSpec{
+					Targets: appsv1beta1.Targets{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"kubernetes.io/metadata.name": testNamespace},
+						},
+						SecretSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"auto-refresh": "enabled"},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, csr)).To(Succeed())
+			csrKey := client.ObjectKeyFromObject(csr)
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, csr))).To(Succeed())
+				_, err := (&SecretsRefreshStatusReconciler{Client: k8sClient}).Reconcile(ctx, reconcile.Request{NamespacedName: csrKey})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+			requests := controllerReconciler.findSecretsRefreshForSecret(ctx, secret)
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].secretsRefreshKeys()).To(ConsistOf(csrKey))
+
+			By("Restarting and recording the refresh in the ClusterSecretsRefresh")
+			_, err := controllerReconciler.Reconcile(ctx, requests[0])
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(k8sClient.Get(ctx, csrKey, csr)).To(Succeed())
+			Expect(csr.Status.RefreshHistory).To(HaveLen(1))
+
+			By("Maintaining the status of the ClusterSecretsRefresh")
+			_, err = (&SecretsRefreshStatusReconciler{Client: k8sClient}).Reconcile(ctx, reconcile.Request{NamespacedName: csrKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, csrKey, csr)).To(Succeed())
+			Expect(csr.Finalizers).To(ContainElement(SecretsRefreshFinalizer))
+			Expect(csr.Status.MatchedNamespaces).To(BeEquivalentTo(1))
+			Expect(meta.IsStatusConditionTrue(csr.Status.Conditions, appsv1beta1.ConditionReady)).To(BeTrue())
+		})
+
+		It("should require allNamespaces for a ClusterSecretsRefresh without namespace selector", func() {
+			csr := &appsv1beta1.ClusterSecretsRefresh{
+				ObjectMeta: metav1.ObjectMeta{Name: secretsRefreshName + "-unconfirmed"},
+				Spec: appsv1beta1.SecretsRefreshSpec{
+					Targets: appsv1beta1.Targets{
+						SecretSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"auto-refresh": "enabled"},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, csr)).To(Succeed())
+			csrKey := client.ObjectKeyFromObject(csr)
+			statusReconciler := &SecretsRefreshStatusReconciler{Client: k8sClient}
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, csr))).To(Succeed())
+				_, err := statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: csrKey})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			By("Selecting no namespace and reporting the selector")
+			namespaces, err := filteredNamespaces(ctx, k8sClient, fromClusterSecretsRefresh(csr))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(namespaces).To(BeEmpty())
+			_, err = statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: csrKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, csrKey, csr)).To(Succeed())
+			degraded := meta.FindStatusCondition(csr.Status.Conditions, appsv1beta1.ConditionDegraded)
+			Expect(degraded).NotTo(BeNil())
+			Expect(degraded.Reason).To(Equal(ReasonInvalidSelector))
+
+			By("Selecting every namespace once confirmed")
+			csr.Spec.Targets.AllNamespaces = true
+			Expect(k8sClient.Update(ctx, csr)).To(Succeed())
+			namespaces, err = filteredNamespaces(ctx, k8sClient, fromClusterSecretsRefresh(csr))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(namespaces).To(ContainElement(HaveField("Name", testNamespace)))
+		})
+
+		It("should neither map nor restart for a suspended SecretsRefresh", func() {
+			sr := &appsv1beta1.SecretsRefresh{}
+			srKey := types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			sr.Spec.Suspend = true
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("Mapping the secret to no request")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+			Expect(controllerReconciler.findSecretsRefreshForSecret(ctx, secret)).To(BeEmpty())
+
+			By("Not restarting for a change observed before the suspension")
+			_, err := controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName:   client.ObjectKeyFromObject(secret),
+				SecretsRefreshes: srKey.String(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Reporting the suspension in the Ready condition")
+			statusReconciler := &SecretsRefreshStatusReconciler{Client: k8sClient}
+			_, err = statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: srKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			ready := meta.FindStatusCondition(sr.Status.Conditions, appsv1beta1.ConditionReady)
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal(ReasonSuspended))
+			Expect(meta.IsStatusConditionFalse(sr.Status.Conditions, appsv1beta1.ConditionDegraded)).To(BeTrue())
+		})
+
+		It("should restart the consumers once for a refresh-now token on the SecretsRefresh", func() {
+			sr := &appsv1beta1.SecretsRefresh{}
+			srKey := types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			sr.Spec.Targets.NamespaceSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{"kubernetes.io/metadata.name": testNamespace},
+			}
+			sr.Annotations = map[string]string{appsv1beta1.RefreshNowAnnotation: "token-1"}
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("Mapping the SecretsRefresh to a refresh of all its secrets")
+			requests := controllerReconciler.requestsForSecretsRefresh(ctx, sr)
+			Expect(requests).To(ConsistOf(SecretRequest{SecretsRefreshes: srKey.String(), RefreshNow: "token-1"}))
+
+			By("Restarting the consumers although the secret didn't change")
+			_, err := controllerReconciler.Reconcile(ctx, requests[0])
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(updatedDeployment.Annotations).To(HaveKeyWithValue(appsv1beta1.RefreshedNowAnnotation, "token-1"))
+
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			Expect(sr.Status.LastRefreshNow).To(Equal("token-1"))
+			Expect(sr.Status.RefreshHistory).To(HaveLen(1))
+			Expect(sr.Status.RefreshHistory[0].RefreshNow).To(Equal("token-1"))
+
+			By("Doing nothing for a token that was already handled")
+			Expect(controllerReconciler.requestsForSecretsRefresh(ctx, sr)).To(BeEmpty())
+			generation := updatedDeployment.Generation
+			_, err = controllerReconciler.Reconcile(ctx, requests[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Generation).To(Equal(generation))
+		})
+
+		It("should restart the consumers for a refresh-now token on the secret", func() {
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+			oldSecret := secret.DeepCopy()
+			secret.Annotations = map[string]string{appsv1beta1.RefreshNowAnnotation: "rotate-2026"}
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			By("Mapping the annotated secret to a refresh")
+			requests := controllerReconciler.findRefreshNowForSecret(ctx, oldSecret, secret)
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].NamespacedName).To(Equal(client.ObjectKeyFromObject(secret)))
+			Expect(requests[0].RefreshNow).To(Equal("rotate-2026"))
+			Expect(controllerReconciler.findRefreshNowForSecret(ctx, secret, secret)).To(BeEmpty())
+
+			_, err := controllerReconciler.Reconcile(ctx, requests[0])
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Echoing the token into the refresh history")
+			sr := &appsv1beta1.SecretsRefresh{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, sr)).To(Succeed())
+			Expect(sr.Status.RefreshHistory).To(HaveLen(1))
+			Expect(sr.Status.RefreshHistory[0].RefreshNow).To(Equal("rotate-2026"))
+			Expect(sr.Status.RefreshHistory[0].Workloads).To(HaveLen(1))
+		})
+
+		It("should let the SecretsRefresh with the highest priority govern a secret", func() {
+			srKey := types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}
+			sr := &appsv1beta1.SecretsRefresh{}
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			sr.Spec.Targets.NamespaceSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{"kubernetes.io/metadata.name": testNamespace},
+			}
+			sr.Spec.Rollout.DryRun = true
+			Expect(k8sClient.Update(ctx, sr)).To(Succeed())
+
+			high := &appsv1beta1.SecretsRefresh{
+				ObjectMeta: metav1.ObjectMeta{Name: secretsRefreshName + "-high", Namespace: "default"},
+				Spec: appsv1beta1.SecretsRefreshSpec{
+					Priority: 10,
+					Targets: appsv1beta1.Targets{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"kubernetes.io/metadata.name": testNamespace},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, high)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, high))).To(Succeed())
+			})
+
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("Restarting as the higher priority SecretsRefresh without dry run says")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+			requests := controllerReconciler.findSecretsRefreshForSecret(ctx, secret)
+			Expect(requests).To(HaveLen(1))
+			_, err := controllerReconciler.Reconcile(ctx, requests[0])
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Recording which SecretsRefresh governed the restart in both")
+			governedBy := []appsv1beta1.SecretsRefreshReference{{Kind: "SecretsRefresh", Name: high.Name, Namespace: "default"}}
+			for _, key := range []types.NamespacedName{srKey, client.ObjectKeyFromObject(high)} {
+				Expect(k8sClient.Get(ctx, key, sr)).To(Succeed())
+				Expect(sr.Status.RefreshHistory).To(HaveLen(1))
+				Expect(sr.Status.RefreshHistory[0].GovernedBy).To(Equal(governedBy))
+			}
+			Expect(sr.Status.DryRunRestarts).To(BeEmpty())
+
+			By("Marking only the lower priority SecretsRefresh as conflicting")
+			statusReconciler := &SecretsRefreshStatusReconciler{Client: k8sClient}
+			_, err = statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: srKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			conflicting := meta.FindStatusCondition(sr.Status.Conditions, appsv1beta1.ConditionConflicting)
+			Expect(conflicting.Status).To(Equal(metav1.ConditionTrue))
+			Expect(conflicting.Reason).To(Equal(ReasonLowerPriority))
+			Expect(conflicting.Message).To(ContainSubstring("default/" + high.Name))
+
+			_, err = statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(high)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(high), sr)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(sr.Status.Conditions, appsv1beta1.ConditionConflicting)).To(BeTrue())
+
+			By("Only rechecking the conflicts of the SecretsRefresh objects a changed one overrides")
+			srRequest := reconcile.Request{NamespacedName: srKey}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(high), high)).To(Succeed())
+			Expect(statusReconciler.overriddenSecretsRefreshes(ctx, high)).To(ContainElement(srRequest))
+			Expect(k8sClient.Get(ctx, srKey, sr)).To(Succeed())
+			Expect(statusReconciler.overriddenSecretsRefreshes(ctx, sr)).NotTo(ContainElement(
+				reconcile.Request{NamespacedName: client.ObjectKeyFromObject(high)}))
+			lowered := high.DeepCopy()
+			lowered.Spec.Priority = 0
+			Expect(statusReconciler.overriddenSecretsRefreshes(ctx, lowered)).NotTo(ContainElement(srRequest))
+			Expect(statusReconciler.overriddenSecretsRefreshes(ctx, high, lowered)).To(ContainElement(srRequest))
+		})
+
+		It("should order SecretsRefresh objects by priority, then by namespace and name", func() {
+			srs := []appsv1beta1.SecretsRefresh{
+				{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "team"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "team"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "low", Namespace: "team"}, Spec: appsv1beta1.SecretsRefreshSpec{Priority: -1}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}},
+			}
+			governing, overridden := splitByPrecedence(srs)
+			names := func(srs []appsv1beta1.SecretsRefresh) []string {
+				var result []string
+				for _, sr := range srs {
+					result = append(result, sr.Name)
+				}
+				return result
+			}
+			Expect(names(governing)).To(Equal([]string{"cluster", "a", "b"}))
+			Expect(names(overridden)).To(Equal([]string{"low"}))
+
+			By("Never letting a namespaced SecretsRefresh override ClusterSecretsRefresh objects")
+			tenant := appsv1beta1.SecretsRefresh{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "team"}, Spec: appsv1beta1.SecretsRefreshSpec{Priority: 100}}
+			platform := appsv1beta1.SecretsRefresh{ObjectMeta: metav1.ObjectMeta{Name: "platform"}}
+			legacy := appsv1beta1.SecretsRefresh{ObjectMeta: metav1.ObjectMeta{Name: "legacy"}, Spec: appsv1beta1.SecretsRefreshSpec{Priority: -5}}
+			other := appsv1beta1.SecretsRefresh{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "team"}}
+			governing, overridden = splitByPrecedence([]appsv1beta1.SecretsRefresh{other, legacy, platform, tenant})
+			Expect(names(governing)).To(Equal([]string{"tenant", "platform"}))
+			Expect(names(overridden)).To(Equal([]string{"other", "legacy"}))
+			Expect(overrides(&tenant, &platform)).To(BeFalse())
+			Expect(overrides(&tenant, &other)).To(BeTrue())
+			Expect(overrides(&platform, &legacy)).To(BeTrue())
+		})
+
+		It("should not let a permissive tenant SecretsRefresh loosen a ClusterSecretsRefresh", func() {
+			never, err := policy.CompileRestartWhen("false")
+			Expect(err).NotTo(HaveOccurred())
+			always, err := policy.CompileRestartWhen("true")
+			Expect(err).NotTo(HaveOccurred())
+
+			platform := &appsv1beta1.SecretsRefresh{ObjectMeta: metav1.ObjectMeta{Name: "platform"}}
+			tenant := &appsv1beta1.SecretsRefresh{
+				ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "team"},
+				Spec:       appsv1beta1.SecretsRefreshSpec{Rollout: appsv1beta1.Rollout{DryRun: true}},
+			}
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			input := policy.RestartWhenInput{Now: time.Now()}
+
+			By("Denying the restart the ClusterSecretsRefresh denies, whatever the tenant allows")
+			Expect(controllerReconciler.restartAllowed(ctx, []restartPolicy{
+				{secretsRefresh: platform, restartWhen: never},
+				{secretsRefresh: tenant},
+			}, input)).To(BeFalse())
+			Expect(controllerReconciler.restartAllowed(ctx, []restartPolicy{
+				{secretsRefresh: platform, restartWhen: never},
+				{secretsRefresh: tenant, restartWhen: always},
+			}, input)).To(BeFalse())
+
+			By("Still merging the tenant policies with an OR once the ClusterSecretsRefresh allows it")
+			Expect(controllerReconciler.restartAllowed(ctx, []restartPolicy{
+				{secretsRefresh: platform, restartWhen: always},
+				{secretsRefresh: tenant, restartWhen: never},
+				{secretsRefresh: &appsv1beta1.SecretsRefresh{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "team"}}},
+			}, input)).To(BeTrue())
+			Expect(controllerReconciler.restartAllowed(ctx, []restartPolicy{
+				{secretsRefresh: tenant, restartWhen: never},
+				{secretsRefresh: &appsv1beta1.SecretsRefresh{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "team"}}},
+			}, input)).To(BeTrue())
+
+			By("Ignoring the dry run of the tenant for a change the ClusterSecretsRefresh governs")
+			dryRun, srs := controllerReconciler.dryRunSecretsRefreshes([]restartPolicy{{secretsRefresh: platform}, {secretsRefresh: tenant}})
+			Expect(dryRun).To(BeFalse())
+			Expect(srs).To(BeEmpty())
+			dryRun, srs = controllerReconciler.dryRunSecretsRefreshes([]restartPolicy{{secretsRefresh: tenant}})
+			Expect(dryRun).To(BeTrue())
+			Expect(srs).To(ConsistOf(tenant))
+		})
+
+		It("should drop v1alpha1 from the stored versions once every object was rewritten", func() {
+			crdKey := types.NamespacedName{Name: "secretsrefreshes." + appsv1beta1.GroupVersion.Group}
+			crd := &apiextensionsv1.CustomResourceDefinition{}
+			Expect(k8sClient.Get(ctx, crdKey, crd)).To(Succeed())
+			crd.Status.StoredVersions = []string{"v1alpha1", "v1beta1"}
+			Expect(k8sClient.Status().Update(ctx, crd)).To(Succeed())
+
+			migrator := &StorageVersionMigrator{Client: k8sClient, APIReader: k8sClient}
+			Expect(migrator.Migrate(ctx)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, crdKey, crd)).To(Succeed())
+			Expect(crd.Status.StoredVersions).To(Equal([]string{"v1beta1"}))
+			clusterCRD := &apiextensionsv1.CustomResourceDefinition{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "clustersecretsrefreshes." + appsv1beta1.GroupVersion.Group}, clusterCRD)).To(Succeed())
+			Expect(clusterCRD.Status.StoredVersions).To(Equal([]string{"v1beta1"}))
+
+			By("Leaving the rewritten objects unchanged")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Generation).To(Equal(int64(1)))
+
+			By("Doing nothing once only v1beta1 is stored")
+			resourceVersion := crd.ResourceVersion
+			Expect(migrator.Migrate(ctx)).To(Succeed())
+			Expect(k8sClient.Get(ctx, crdKey, crd)).To(Succeed())
+			Expect(crd.ResourceVersion).To(Equal(resourceVersion))
+		})
+
+		It("should apply the TraktorConfig without restarting the operator", func() {
+			configKey := types.NamespacedName{Name: appsv1beta1.TraktorConfigName}
+			traktorConfig := &appsv1beta1.TraktorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: appsv1beta1.TraktorConfigName},
+				Spec: appsv1beta1.TraktorConfigSpec{
+					Protected: appsv1beta1.Protection{Namespaces: []string{testNamespace}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, traktorConfig)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, traktorConfig))).To(Succeed())
+			})
+
+			operatorConfig := &OperatorConfig{}
+			configReconciler := &TraktorConfigReconciler{Client: k8sClient, Config: operatorConfig}
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: operatorConfig,
+			}
+			secretRequest := SecretRequest{NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace}}
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+
+			By("Leaving workloads in protected namespaces alone")
+			_, err := configReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: configKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, configKey, traktorConfig)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(traktorConfig.Status.Conditions, appsv1beta1.ConditionReady)).To(BeTrue())
+			Expect(traktorConfig.Status.ObservedGeneration).To(Equal(traktorConfig.Generation))
+
+			_, err = controllerReconciler.Reconcile(ctx, secretRequest)
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Keeping the last valid configuration when the new one is invalid")
+			traktorConfig.Spec.Notifiers = []appsv1beta1.NotificationWebhook{{Name: "audit", URL: "https://"}}
+			Expect(k8sClient.Update(ctx, traktorConfig)).To(Succeed())
+			_, err = configReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: configKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, configKey, traktorConfig)).To(Succeed())
+			ready := meta.FindStatusCondition(traktorConfig.Status.Conditions, appsv1beta1.ConditionReady)
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal(ReasonInvalidConfig))
+			Expect(ready.Message).To(HavePrefix("Applying the last valid configuration"))
+			Expect(meta.IsStatusConditionTrue(traktorConfig.Status.Conditions, appsv1beta1.ConditionDegraded)).To(BeTrue())
+			Expect(operatorConfig.protection("Deployment", &metav1.ObjectMeta{Namespace: testNamespace})).NotTo(BeEmpty())
+			Expect(operatorConfig.notifiers()).To(BeEmpty())
+			// The schema only checks the prefix, the operator posts to http and https alone
+			Expect(validateTraktorConfig(&appsv1beta1.TraktorConfigSpec{
+				Notifiers: []appsv1beta1.NotificationWebhook{{Name: "audit", URL: "gopher://audit.example.com"}},
+			})).To(ContainSubstring("notifier audit"))
+
+			By("Restarting within the global rate limit")
+			traktorConfig.Spec = appsv1beta1.TraktorConfigSpec{
+				RateLimits: appsv1beta1.RateLimits{MaxRestartsPerMinute: 1},
+			}
+			Expect(k8sClient.Update(ctx, traktorConfig)).To(Succeed())
+			_, err = configReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: configKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operatorConfig.protection("Deployment", &metav1.ObjectMeta{Namespace: testNamespace})).To(BeEmpty())
+
+			_, err = controllerReconciler.Reconcile(ctx, secretRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).To(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			markRolledOut(ctx, deploymentKey)
+
+			By("Deferring restarts beyond the rate limit")
+			Expect(k8sClient.Get(ctx, secretRequest.NamespacedName, secret)).To(Succeed())
+			secret.StringData = map[string]string{"password": "rate-limited-password"}
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+			result, err := controllerReconciler.Reconcile(ctx, secretRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			Expect(result.RequeueAfter).To(BeNumerically("<=", rateLimitWindow))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.PendingRestarts).To(HaveLen(1))
+			Expect(secretsRefresh.Status.PendingRestarts[0].Reason).To(Equal(ReasonRateLimited))
+		})
+
+		It("should load the TraktorConfig before the first reconciliation", func() {
+			By("Applying the defaults without a TraktorConfig")
+			operatorConfig, err := LoadOperatorConfig(ctx, k8sClient)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operatorConfig.protection("Deployment", &metav1.ObjectMeta{Namespace: testNamespace})).To(BeEmpty())
+
+			traktorConfig := &appsv1beta1.TraktorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: appsv1beta1.TraktorConfigName},
+				Spec: appsv1beta1.TraktorConfigSpec{
+					Protected: appsv1beta1.Protection{Namespaces: []string{testNamespace}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, traktorConfig)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, traktorConfig))).To(Succeed())
+			})
+
+			By("Protecting workloads before the TraktorConfig reconciler has run")
+			operatorConfig, err = LoadOperatorConfig(ctx, k8sClient)
+			Expect(err).NotTo(HaveOccurred())
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: operatorConfig,
+			}
+			_, err = controllerReconciler.Reconcile(ctx, SecretRequest{
+				NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: testNamespace}, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+
+			By("Starting with the defaults when the TraktorConfig is invalid")
+			traktorConfig.Spec.Notifiers = []appsv1beta1.NotificationWebhook{{Name: "audit", URL: "https://"}}
+			Expect(k8sClient.Update(ctx, traktorConfig)).To(Succeed())
+			operatorConfig, err = LoadOperatorConfig(ctx, k8sClient)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(operatorConfig.usesDefaults()).To(BeTrue())
+			Expect(operatorConfig.protection("Deployment", &metav1.ObjectMeta{Namespace: testNamespace})).To(BeEmpty())
+
+			By("Reporting the TraktorConfig as Degraded")
+			configKey := types.NamespacedName{Name: appsv1beta1.TraktorConfigName}
+			configReconciler := &TraktorConfigReconciler{Client: k8sClient, Config: operatorConfig}
+			_, err = configReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: configKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, configKey, traktorConfig)).To(Succeed())
+			degraded := meta.FindStatusCondition(traktorConfig.Status.Conditions, appsv1beta1.ConditionDegraded)
+			Expect(degraded).NotTo(BeNil())
+			Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal(ReasonInvalidConfig))
+			Expect(degraded.Message).To(HavePrefix("Applying the defaults"))
+
+			By("Applying the TraktorConfig once it is fixed")
+			traktorConfig.Spec.Notifiers = nil
+			Expect(k8sClient.Update(ctx, traktorConfig)).To(Succeed())
+			_, err = configReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: configKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, configKey, traktorConfig)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(traktorConfig.Status.Conditions, appsv1beta1.ConditionDegraded)).To(BeTrue())
+			Expect(operatorConfig.usesDefaults()).To(BeFalse())
+			Expect(operatorConfig.protection("Deployment", &metav1.ObjectMeta{Namespace: testNamespace})).NotTo(BeEmpty())
+		})
+
+		It("should only accept a TraktorConfig named default", func() {
+			other := &appsv1beta1.TraktorConfig{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+			err := k8sClient.Create(ctx, other)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("must be named default"))
+		})
+
+		It("should apply the defaults without a TraktorConfig", func() {
+			var operatorConfig *OperatorConfig
+			Expect(operatorConfig.protection("Deployment", &metav1.ObjectMeta{Namespace: operatorNamespace()})).NotTo(BeEmpty())
+			Expect(operatorConfig.protection("Deployment", &metav1.ObjectMeta{Namespace: testNamespace})).To(BeEmpty())
+			Expect(operatorConfig.defaultRestartStrategy()).To(Equal(appsv1beta1.RestartStrategyTemplateAnnotation))
+			Expect(operatorConfig.workloadKindEnabled("Deployment")).To(BeTrue())
+			Expect(operatorConfig.actionsEnabled()).To(BeTrue())
+			Expect(operatorConfig.reserveRestart(time.Now())).To(BeZero())
+		})
+
+		It("should never restart protected workloads", func() {
+			operatorConfig := &OperatorConfig{}
+			operatorConfig.set(&appsv1beta1.TraktorConfigSpec{
+				Protected: appsv1beta1.Protection{
+					Workloads: []appsv1beta1.WorkloadReference{{Kind: "Deployment", Name: deploymentName, Namespace: testNamespace}},
+				},
+			})
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+				Config:   operatorConfig,
+			}
+			secretRequest := SecretRequest{NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace}}
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+
+			By("Skipping a protected Deployment and reporting it on the SecretsRefresh")
+			_, err := controllerReconciler.Reconcile(ctx, secretRequest)
+			Expect(err).NotTo(HaveOccurred())
+			updatedDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(recorder.Events).To(Receive(And(
+				HavePrefix("Warning "+ReasonProtectedWorkload),
+				ContainSubstring("Deployment "+testNamespace+"/"+deploymentName+" is protected"),
+			)))
+
+			By("Skipping Deployments matching the protected selector on manual refreshes")
+			operatorConfig.set(&appsv1beta1.TraktorConfigSpec{
+				Protected: appsv1beta1.Protection{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "critical"}},
+				},
+			})
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			updatedDeployment.Labels = map[string]string{"tier": "critical"}
+			Expect(k8sClient.Update(ctx, updatedDeployment)).To(Succeed())
+			Expect(k8sClient.Get(ctx, secretRequest.NamespacedName, secret)).To(Succeed())
+			secret.Annotations = map[string]string{appsv1beta1.RefreshNowAnnotation: "protected"}
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+			secretRequest.RefreshNow = "protected"
+			_, err = controllerReconciler.Reconcile(ctx, secretRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, updatedDeployment)).To(Succeed())
+			Expect(updatedDeployment.Spec.Template.Annotations).NotTo(HaveKey(appsv1beta1.RestartedAtAnnotation))
+			Expect(recorder.Events).To(Receive(ContainSubstring("labels match the protected selector")))
+
+			By("Refusing to restart a protected Deployment with any strategy")
+			err = controllerReconciler.restartDeployment(ctx, updatedDeployment, nil, secret, nil, appsv1beta1.DisruptionPolicyAllow)
+			var protected *ProtectedWorkloadError
+			Expect(errors.As(err, &protected)).To(BeTrue())
+		})
+
+		It("should expose Traktor metrics", func() {
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			durationReconciler := &RestartDurationReconciler{Client: k8sClient}
+			statusReconciler := &SecretsRefreshStatusReconciler{Client: k8sClient}
+			srKey := types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}
+			secretRequest := SecretRequest{NamespacedName: types.NamespacedName{Name: secretName, Namespace: testNamespace}}
+			deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: testNamespace}
+			// Metrics are global, earlier tests left their values behind
+			forgetSecretsRefreshMetrics(srKey)
+
+			By("Counting changes of selected secrets")
+			changes := testutil.ToFloat64(secretChangesTotal.WithLabelValues(testNamespace))
+			Expect(k8sClient.Get(ctx, secretRequest.NamespacedName, secret)).To(Succeed())
+			oldSecret := secret.DeepCopy()
+			oldSecret.Data = map[string][]byte{"password": []byte("previous-password")}
+			Expect(controllerReconciler.secretChanged(ctx, oldSecret, secret)).NotTo(BeEmpty())
+			Expect(testutil.ToFloat64(secretChangesTotal.WithLabelValues(testNamespace))).To(Equal(changes + 1))
+
+			By("Counting restarts and timing them until the rollout completed")
+			restarts := testutil.ToFloat64(restartsTotal.WithLabelValues("Deployment", testNamespace, "Succeeded"))
+			durations := histogramCount(restartDurationSeconds)
+			_, err := controllerReconciler.Reconcile(ctx, secretRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testutil.ToFloat64(restartsTotal.WithLabelValues("Deployment", testNamespace, "Succeeded"))).To(Equal(restarts + 1))
+
+			result, err := durationReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(rolloutRecheckInterval))
+			Expect(histogramCount(restartDurationSeconds)).To(Equal(durations))
+			markRolledOut(ctx, deploymentKey)
+			_, err = durationReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(histogramCount(restartDurationSeconds)).To(Equal(durations + 1))
+
+			By("Counting blocked and pending restarts")
+			Expect(k8sClient.Get(ctx, srKey, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Rollout.Approval.Required = true
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+			Expect(k8sClient.Get(ctx, secretRequest.NamespacedName, secret)).To(Succeed())
+			secret.StringData = map[string]string{"password": "metrics-password"}
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			blocked := testutil.ToFloat64(blockedRestartsTotal.WithLabelValues(ReasonAwaitingApproval))
+			pending := testutil.ToFloat64(pendingRestartsGauge)
+			_, err = controllerReconciler.Reconcile(ctx, secretRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testutil.ToFloat64(blockedRestartsTotal.WithLabelValues(ReasonAwaitingApproval))).To(Equal(blocked + 1))
+			Expect(testutil.ToFloat64(pendingRestartsGauge)).To(Equal(pending + 1))
+
+			By("Reporting the matched secrets of each SecretsRefresh")
+			_, err = statusReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: srKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, srKey, secretsRefresh)).To(Succeed())
+			Expect(secretsRefresh.Status.MatchedSecrets).To(BeNumerically(">", 0))
+			Expect(testutil.ToFloat64(matchedSecretsGauge.WithLabelValues("default/" + secretsRefreshName))).
+				To(Equal(float64(secretsRefresh.Status.MatchedSecrets)))
+			Expect(testutil.ToFloat64(pendingRestartsGauge)).To(Equal(pending + 1))
+		})
+
+		It("should only remember changes of selected secrets", func() {
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			unselected := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "unselected", Namespace: testNamespace},
+				StringData: map[string]string{"password": "secret"},
+			}
+			Expect(k8sClient.Create(ctx, unselected)).To(Succeed())
+			Expect(controllerReconciler.secretChanged(ctx, unselected.DeepCopy(), unselected)).To(BeEmpty())
+			Expect(controllerReconciler.previousSecrets.load(client.ObjectKeyFromObject(unselected))).To(BeNil())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: testNamespace}, secret)).To(Succeed())
+			Expect(controllerReconciler.secretChanged(ctx, secret.DeepCopy(), secret)).NotTo(BeEmpty())
+			Expect(controllerReconciler.previousSecrets.load(client.ObjectKeyFromObject(secret))).NotTo(BeNil())
+
+			By("Tracking changes for flap detection only while a window needs them")
+			Expect(controllerReconciler.secretChanges.changes).To(BeEmpty())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: secretsRefreshName, Namespace: "default"}, secretsRefresh)).To(Succeed())
+			secretsRefresh.Spec.Triggers.FlapDetection = &appsv1beta1.FlapDetection{
+				MaxChanges: 2,
+				Window:     metav1.Duration{Duration: time.Minute},
+			}
+			Expect(k8sClient.Update(ctx, secretsRefresh)).To(Succeed())
+			Expect(controllerReconciler.secretChanged(ctx, unselected.DeepCopy(), unselected)).To(BeEmpty())
+			Expect(controllerReconciler.secretChanged(ctx, secret.DeepCopy(), secret)).NotTo(BeEmpty())
+			Expect(controllerReconciler.secretChanges.changes).To(HaveKey(client.ObjectKeyFromObject(secret)))
+			Expect(controllerReconciler.secretChanges.changes).NotTo(HaveKey(client.ObjectKeyFromObject(unselected)))
+
+			stale := types.NamespacedName{Name: "stale", Namespace: testNamespace}
+			controllerReconciler.secretChanges.record(stale, time.Now().Add(-2*time.Minute), time.Minute)
+			Expect(controllerReconciler.secretChanges.changes).To(HaveKey(stale))
+			Expect(controllerReconciler.secretChanged(ctx, secret.DeepCopy(), secret)).NotTo(BeEmpty())
+			Expect(controllerReconciler.secretChanges.changes).NotTo(HaveKey(stale))
+			Expect(controllerReconciler.secretChanges.changes[client.ObjectKeyFromObject(secret)]).To(HaveLen(2))
+		})
+
+		It("should filter namespaces correctly based on selector", func() {
+			By("Getting filtered namespaces")
+			controllerReconciler := &SecretsRefreshReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			namespaces, err := controllerReconciler.getFilteredNamespaces(ctx, secretsRefresh)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Verifying test namespace is included")
+			found := false
+			for _, ns := range namespaces {
+				if ns.Name == testNamespace {
+					found = true
+					break
+				}
+			}
+			Expect(found).To(BeTrue())
+		})
+
+		It("should correctly hash secret data for comparison", func() {
+			By("Creating two secrets with identical data")
+			secret1 := &corev1.Secret{
+				Data: map[string][]byte{
+					"key1": []byte("value1"),
+					"key2": []byte("value2"),
+				},
+			}
+			secret2 := &corev1.Secret{
+				Data: map[string][]byte{
+					"key2": []byte("value2"),
+					"key1": []byte("value1"),
+				},
+			}
+
+			hash1 := hashSecretData(secret1)
+			hash2 := hashSecretData(secret2)
+
+			By("Verifying hashes are identical regardless of key order")
+			Expect(hash1).To(Equal(hash2))
+
+			By("Creating a secret with different data")
+			secret3 := &corev1.Secret{
+				Data: map[string][]byte{
+					"key1": []byte("value1"),
+					"key2": []byte("different"),
+				},
+			}
+
+			hash3 := hashSecretData(secret3)
+
+			By("Verifying hash is different when data changes")
+			Expect(hash1).NotTo(Equal(hash3))
+
+			By("Verifying nil secret returns empty hash")
+			hashNil := hashSecretData(nil)
+			Expect(hashNil).To(Equal(""))
+		})
+	})
+})
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// histogramCount returns the number of observations of the histogram
+func histogramCount(histogram prometheus.Histogram) uint64 {
+	GinkgoHelper()
+	metric := &dto.Metric{}
+	Expect(histogram.Write(metric)).To(Succeed())
+	return metric.GetHistogram().GetSampleCount()
+}
+
+// markRolledOut reports the current generation of the deployment as fully rolled out,
+// as the Deployment controller would; envtest doesn't run it
+func markRolledOut(ctx context.Context, name types.NamespacedName) {
+	GinkgoHelper()
+	deployment := &appsv1.Deployment{}
+	Expect(k8sClient.Get(ctx, name, deployment)).To(Succeed())
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	deployment.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: deployment.Generation,
+		Replicas:           replicas,
+		UpdatedReplicas:    replicas,
+		ReadyReplicas:      replicas,
+		AvailableReplicas:  replicas,
+	}
+	Expect(k8sClient.Status().Update(ctx, deployment)).To(Succeed())
+}
+
+// makeReloadable mounts the test secret as a volume and points the reload URL of the
+// Deployment at the server, with a single ready pod on the loopback address
+func makeReloadable(ctx context.Context, name types.NamespacedName, secretName string, server *httptest.Server) {
+	GinkgoHelper()
+	serverURL, err := url.Parse(server.URL)
+	Expect(err).NotTo(HaveOccurred())
+
+	deployment := &appsv1.Deployment{}
+	Expect(k8sClient.Get(ctx, name, deployment)).To(Succeed())
+	deployment.Annotations = map[string]string{
+		appsv1beta1.ReloadURLAnnotation: "http://:" + serverURL.Port() + "/-/reload",
+	}
+	deployment.Spec.Template.Spec.Containers[0].Env = nil
+	deployment.Spec.Template.Spec.Volumes = []corev1.Volume{{
+		Name:         "secret",
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretName}},
+	}}
+	deployment.Spec.Template.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: "secret", MountPath: "/etc/secret"}}
+	Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+	markRolledOut(ctx, name)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name + "-reload",
+			Namespace: name.Namespace,
+			Labels:    map[string]string{"app": "test"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nginx", Image: "nginx:alpine"}},
+		},
+	}
+	Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+	pod.Status = corev1.PodStatus{
+		Phase:      corev1.PodRunning,
+		PodIP:      "127.0.0.1",
+		PodIPs:     []corev1.PodIP{{IP: "127.0.0.1"}},
+		Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+	}
+	Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+}
+
+// expireReloadDelay makes the reload of the Deployment due now
+func expireReloadDelay(ctx context.Context, name types.NamespacedName) {
+	GinkgoHelper()
+	deployment := &appsv1.Deployment{}
+	Expect(k8sClient.Get(ctx, name, deployment)).To(Succeed())
+	Expect(deployment.Annotations).To(HaveKey(appsv1beta1.ReloadAfterAnnotation))
+	deployment.Annotations[appsv1beta1.ReloadAfterAnnotation] = time.Now().Add(-time.Second).Format(time.RFC3339)
+	Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+}
+
+// actionJobTemplate returns a minimal Job template for actions
+func actionJobTemplate() *batchv1.JobTemplateSpec {
+	return &batchv1.JobTemplateSpec{
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{{Name: "sync", Image: "busybox"}},
+				},
+			},
+		},
+	}
+}
+
+// jobTemplatesConfig returns an operator configuration that lets namespaced
+// SecretsRefresh objects run actions from inline job templates
+func jobTemplatesConfig() *OperatorConfig {
+	config := &OperatorConfig{}
+	config.set(&appsv1beta1.TraktorConfigSpec{Features: appsv1beta1.Features{NamespacedJobTemplates: true}})
+	return config
+}
+
+// actionJobs returns the Jobs created for the action in the namespace
+func actionJobs(ctx context.Context, namespace, action string) []batchv1.Job {
+	GinkgoHelper()
+	jobs := &batchv1.JobList{}
+	Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace),
+		client.MatchingLabels{appsv1beta1.ActionLabel: action})).To(Succeed())
+	return jobs.Items
+}
+
+// finishJob marks the Job as succeeded or failed like the Job controller would
+func finishJob(ctx context.Context, job *batchv1.Job, succeeded bool) {
+	GinkgoHelper()
+	now := metav1.Now()
+	job.Status.StartTime = &now
+	if succeeded {
+		job.Status.Succeeded = 1
+		job.Status.CompletionTime = &now
+		job.Status.Conditions = []batchv1.JobCondition{
+			{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue, LastTransitionTime: now},
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: now},
+		}
+	} else {
+		job.Status.Failed = 1
+		job.Status.Conditions = []batchv1.JobCondition{
+			{Type: batchv1.JobFailureTarget, Status: corev1.ConditionTrue, LastTransitionTime: now, Reason: "BackoffLimitExceeded"},
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: now, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit"},
+		}
+	}
+	Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
 }
@@ -0,0 +1,412 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+	"github.com/GDXbsv/traktor/internal/policy"
+)
+
+// Reasons of the Ready and Degraded conditions
+const (
+	ReasonReconciled         = "Reconciled"
+	ReasonInvalidSelector    = "InvalidSelector"
+	ReasonInvalidRestartWhen = "InvalidRestartWhen"
+	ReasonRefreshFailed      = "RefreshFailed"
+	ReasonRefreshBlocked     = "RefreshBlocked"
+	ReasonSuspended          = "Suspended"
+)
+
+// SecretsRefreshFinalizer lets Traktor stop the actions of a SecretsRefresh before it is deleted
+const SecretsRefreshFinalizer = "traktor.gdxcloud.net/finalizer"
+
+// statusResyncInterval is how often the matched counts are recomputed
+const statusResyncInterval = 5 * time.Minute
+
+// maxRefreshHistory bounds status.refreshHistory
+const maxRefreshHistory = 20
+
+// matchedCounts are the objects selected by a SecretsRefresh
+type matchedCounts struct {
+	namespaces int32
+	secrets    int32
+	workloads  int32
+}
+
+// SecretsRefreshStatusReconciler reconciles SecretsRefresh objects: it validates them,
+// maintains the conditions and matched counts in their status and cleans up after them
+// when they are deleted. Secret changes are handled by SecretsRefreshReconciler.
+type SecretsRefreshStatusReconciler struct {
+	client.Client
+}
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=delete
+
+// Reconcile validates a SecretsRefresh and updates its status
+func (r *SecretsRefreshStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	sr := &traktorv1beta1.SecretsRefresh{}
+	if err := getSecretsRefresh(ctx, r.Client, req.NamespacedName, sr); err != nil {
+		if apierrors.IsNotFound(err) {
+			forgetSecretsRefreshMetrics(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !sr.DeletionTimestamp.IsZero() {
+		forgetSecretsRefreshMetrics(req.NamespacedName)
+		if !controllerutil.ContainsFinalizer(sr, SecretsRefreshFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if err := r.stopActionJobs(ctx, sr); err != nil {
+			logger.Error(err, "Failed to stop action Jobs", "secretsRefresh", sr.Name)
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(sr, SecretsRefreshFinalizer)
+		return ctrl.Result{}, r.Update(ctx, secretsRefreshObject(sr))
+	}
+	if controllerutil.AddFinalizer(sr, SecretsRefreshFinalizer) {
+		if err := r.Update(ctx, secretsRefreshObject(sr)); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	reason, message := validateSecretsRefreshSpec(sr)
+	counts := matchedCounts{}
+	if reason == "" {
+		var err error
+		counts, err = r.matchedCounts(ctx, sr)
+		if err != nil {
+			logger.Error(err, "Failed to count matched objects", "secretsRefresh", sr.Name)
+			return ctrl.Result{}, err
+		}
+		reason, message = lastRefreshProblem(sr.Status.RefreshHistory)
+	}
+
+	ready := metav1.Condition{
+		Type:               traktorv1beta1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonReconciled,
+		Message:            fmt.Sprintf("Watching %d secrets in %d namespaces", counts.secrets, counts.namespaces),
+		ObservedGeneration: sr.Generation,
+	}
+	degraded := metav1.Condition{
+		Type:               traktorv1beta1.ConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonReconciled,
+		ObservedGeneration: sr.Generation,
+	}
+	if reason != "" {
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, reason, message
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, reason, message
+	}
+	conflicting := metav1.Condition{
+		Type:               traktorv1beta1.ConditionConflicting,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonReconciled,
+		ObservedGeneration: sr.Generation,
+	}
+	if reason == "" && !sr.Spec.Suspend {
+		overriding, overridden, err := overridingSecretsRefreshes(ctx, r.Client, sr)
+		if err != nil {
+			logger.Error(err, "Failed to check for SecretsRefresh objects with a higher priority", "secretsRefresh", sr.Name)
+			return ctrl.Result{}, err
+		}
+		if len(overriding) > 0 {
+			conflicting.Status, conflicting.Reason = metav1.ConditionTrue, ReasonLowerPriority
+			conflicting.Message = fmt.Sprintf("%d of the selected secrets are governed by %s with a higher priority",
+				overridden, formatReferences(overriding))
+		}
+	}
+	if reason == "" && sr.Spec.Suspend {
+		// Suspending is deliberate, so the SecretsRefresh isn't degraded
+		ready.Status, ready.Reason = metav1.ConditionFalse, ReasonSuspended
+		ready.Message = fmt.Sprintf("Suspended, not watching %d secrets in %d namespaces", counts.secrets, counts.namespaces)
+	}
+
+	err := updateSecretsRefreshStatus(ctx, r.Client, sr, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+		changed := meta.SetStatusCondition(&status.Conditions, ready)
+		changed = meta.SetStatusCondition(&status.Conditions, degraded) || changed
+		changed = meta.SetStatusCondition(&status.Conditions, conflicting) || changed
+		current := matchedCounts{status.MatchedNamespaces, status.MatchedSecrets, status.MatchedWorkloads}
+		if status.ObservedGeneration == sr.Generation && current == counts && !changed {
+			return false
+		}
+		status.ObservedGeneration = sr.Generation
+		status.MatchedNamespaces = counts.namespaces
+		status.MatchedSecrets = counts.secrets
+		status.MatchedWorkloads = counts.workloads
+		return true
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	matchedSecretsGauge.WithLabelValues(secretsRefreshMetricLabel(req.NamespacedName)).Set(float64(counts.secrets))
+	pendingRestarts.set(req.NamespacedName, len(sr.Status.PendingRestarts))
+
+	// Secrets and workloads come and go without touching the SecretsRefresh
+	return ctrl.Result{RequeueAfter: statusResyncInterval}, nil
+}
+
+// stopActionJobs deletes the Jobs of the actions of the SecretsRefresh that are still
+// running. Finished Jobs are left to their TTL.
+func (r *SecretsRefreshStatusReconciler) stopActionJobs(ctx context.Context, sr *traktorv1beta1.SecretsRefresh) error {
+	logger := log.FromContext(ctx)
+
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs, client.HasLabels{traktorv1beta1.ActionLabel}); err != nil {
+		return err
+	}
+	owner := client.ObjectKeyFromObject(sr).String()
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if job.Annotations[traktorv1beta1.ActionSecretsRefreshAnnotation] != owner {
+			continue
+		}
+		if result, _, _ := jobResult(job); result != ActionResultRunning {
+			continue
+		}
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		logger.Info("Stopped action Job of deleted SecretsRefresh",
+			"job", job.Name,
+			"namespace", job.Namespace,
+			"secretsRefresh", sr.Name)
+	}
+	return nil
+}
+
+// validateSecretsRefreshSpec returns the reason and message of the first problem of the
+// spec that prevents the SecretsRefresh from working, empty if there is none
+func validateSecretsRefreshSpec(sr *traktorv1beta1.SecretsRefresh) (string, string) {
+	if _, err := metav1.LabelSelectorAsSelector(sr.Spec.Targets.NamespaceSelector); err != nil {
+		return ReasonInvalidSelector, fmt.Sprintf("invalid namespace selector: %v", err)
+	}
+	if _, err := metav1.LabelSelectorAsSelector(sr.Spec.Targets.SecretSelector); err != nil {
+		return ReasonInvalidSelector, fmt.Sprintf("invalid secret selector: %v", err)
+	}
+	if selectsNoNamespace(sr) {
+		return ReasonInvalidSelector, "empty namespace selector, set allNamespaces to select all namespaces"
+	}
+	if sr.Spec.Triggers.RestartWhen != "" {
+		if _, err := policy.CompileRestartWhen(sr.Spec.Triggers.RestartWhen); err != nil {
+			return ReasonInvalidRestartWhen, err.Error()
+		}
+	}
+	for _, w := range sr.Spec.Rollout.MaintenanceWindows {
+		if _, err := policy.ParseWindow(w); err != nil {
+			return ReasonInvalidMaintenanceWindow, err.Error()
+		}
+	}
+	return "", ""
+}
+
+// lastRefreshProblem returns the reason and message of the most recent refresh if it
+// didn't succeed
+func lastRefreshProblem(history []traktorv1beta1.RefreshRecord) (string, string) {
+	if len(history) == 0 {
+		return "", ""
+	}
+	last := history[len(history)-1]
+	switch last.Result {
+	case traktorv1beta1.RefreshResultFailed:
+		return ReasonRefreshFailed, fmt.Sprintf("Refresh for Secret %s/%s failed: %s", last.SecretNamespace, last.SecretName, last.Message)
+	case traktorv1beta1.RefreshResultBlocked:
+		return ReasonRefreshBlocked, fmt.Sprintf("Refresh for Secret %s/%s blocked: %s", last.SecretNamespace, last.SecretName, last.Message)
+	}
+	return "", ""
+}
+
+// matchedCounts counts the namespaces and secrets a SecretsRefresh selects and the
+// Deployments using those secrets
+func (r *SecretsRefreshStatusReconciler) matchedCounts(ctx context.Context, sr *traktorv1beta1.SecretsRefresh) (matchedCounts, error) {
+	counts := matchedCounts{}
+
+	namespaces, err := filteredNamespaces(ctx, r.Client, sr)
+	if err != nil {
+		return counts, err
+	}
+	secretSelector := labels.Everything()
+	if sr.Spec.Targets.SecretSelector != nil {
+		if secretSelector, err = metav1.LabelSelectorAsSelector(sr.Spec.Targets.SecretSelector); err != nil {
+			return counts, err
+		}
+	}
+
+	for _, namespace := range namespaces {
+		counts.namespaces++
+
+		secrets := &corev1.SecretList{}
+		if err := r.List(ctx, secrets, client.InNamespace(namespace.Name), client.MatchingLabelsSelector{Selector: secretSelector}); err != nil {
+			return counts, err
+		}
+		if len(secrets.Items) == 0 {
+			continue
+		}
+		counts.secrets += int32(len(secrets.Items))
+
+		deployments := &appsv1.DeploymentList{}
+		if err := r.List(ctx, deployments, client.InNamespace(namespace.Name)); err != nil {
+			return counts, err
+		}
+		for i := range deployments.Items {
+			uses := slices.ContainsFunc(secrets.Items, func(secret corev1.Secret) bool {
+				return len(secretReferences(&deployments.Items[i], secret.Name)) > 0
+			})
+			if uses {
+				counts.workloads++
+			}
+		}
+	}
+	return counts, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SecretsRefreshStatusReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&traktorv1beta1.SecretsRefresh{}).
+		Watches(&traktorv1beta1.ClusterSecretsRefresh{}, &handler.EnqueueRequestForObject{}).
+		// Changing the selectors or priority of one SecretsRefresh may resolve or cause
+		// conflicts of those it overrides
+		Watches(&traktorv1beta1.SecretsRefresh{},
+			r.overriddenSecretsRefreshHandler(),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&traktorv1beta1.ClusterSecretsRefresh{},
+			r.overriddenSecretsRefreshHandler(),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("secretsrefresh-status").
+		Complete(r)
+}
+
+// overriddenSecretsRefreshHandler enqueues the SecretsRefresh and ClusterSecretsRefresh
+// objects a changed one overrides before or after the change. Only their Conflicting
+// condition can depend on it, so a change doesn't recompute the precedence of all of them.
+func (r *SecretsRefreshStatusReconciler) overriddenSecretsRefreshHandler() handler.EventHandler {
+	enqueue := func(ctx context.Context, q workqueue.TypedRateLimitingInterface[reconcile.Request], changed ...client.Object) {
+		for _, req := range r.overriddenSecretsRefreshes(ctx, changed...) {
+			q.Add(req)
+		}
+	}
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, q, e.Object)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, q, e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, q, e.Object)
+		},
+	}
+}
+
+// overriddenSecretsRefreshes maps versions of a changed SecretsRefresh or
+// ClusterSecretsRefresh to the SecretsRefresh and ClusterSecretsRefresh objects any of
+// them overrides
+func (r *SecretsRefreshStatusReconciler) overriddenSecretsRefreshes(ctx context.Context, changed ...client.Object) []reconcile.Request {
+	var overriding []*traktorv1beta1.SecretsRefresh
+	for _, obj := range changed {
+		switch obj := obj.(type) {
+		case *traktorv1beta1.SecretsRefresh:
+			overriding = append(overriding, obj)
+		case *traktorv1beta1.ClusterSecretsRefresh:
+			overriding = append(overriding, fromClusterSecretsRefresh(obj))
+		}
+	}
+
+	srs, err := listSecretsRefreshes(ctx, r.Client)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list SecretsRefresh objects")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range srs {
+		if slices.ContainsFunc(overriding, func(sr *traktorv1beta1.SecretsRefresh) bool { return overrides(sr, &srs[i]) }) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&srs[i])})
+		}
+	}
+	return requests
+}
+
+// recordRefresh adds a handled secret change to the refresh history of the matching
+// SecretsRefresh objects, governing or overridden, and sends it to their notification
+// webhooks. Changes that restarted nothing are only recorded once. refreshNow is the
+// token of a manual refresh.
+func (r *SecretsRefreshReconciler) recordRefresh(ctx context.Context, policies []restartPolicy, overridden []*traktorv1beta1.SecretsRefresh, secret *corev1.Secret, restarted []*appsv1.Deployment, problems []string, result, refreshNow string, now time.Time) error {
+	record := traktorv1beta1.RefreshRecord{
+		SecretName:      secret.Name,
+		SecretNamespace: secret.Namespace,
+		Version:         secretVersion(secret),
+		Result:          result,
+		Message:         strings.Join(problems, "; "),
+		RefreshNow:      refreshNow,
+		GovernedBy:      governingReferences(policies),
+		Time:            metav1.NewTime(now),
+	}
+	for _, deployment := range restarted {
+		record.Workloads = append(record.Workloads, traktorv1beta1.WorkloadReference{
+			Kind:      "Deployment",
+			Name:      deployment.Name,
+			Namespace: deployment.Namespace,
+		})
+	}
+
+	srs := make([]*traktorv1beta1.SecretsRefresh, 0, len(policies)+len(overridden))
+	for _, p := range policies {
+		srs = append(srs, p.secretsRefresh)
+	}
+	for _, sr := range append(srs, overridden...) {
+		recorded := false
+		err := r.updateStatus(ctx, sr, func(status *traktorv1beta1.SecretsRefreshStatus) bool {
+			recorded = false
+			if len(record.Workloads) == 0 && slices.ContainsFunc(status.RefreshHistory, func(existing traktorv1beta1.RefreshRecord) bool {
+				return existing.SecretName == record.SecretName && existing.SecretNamespace == record.SecretNamespace &&
+					existing.Version == record.Version && existing.Result == record.Result && existing.Message == record.Message &&
+					existing.RefreshNow == record.RefreshNow
+			}) {
+				return false
+			}
+			status.RefreshHistory = append(status.RefreshHistory, record)
+			if overflow := len(status.RefreshHistory) - maxRefreshHistory; overflow > 0 {
+				status.RefreshHistory = status.RefreshHistory[overflow:]
+			}
+			if len(record.Workloads) > 0 {
+				status.LastRefreshTime = &record.Time
+			}
+			recorded = true
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if recorded {
+			r.notify(ctx, sr, record)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// RestartStrategy restarts the pods of a Deployment because a secret it uses changed
+type RestartStrategy interface {
+	Restart(ctx context.Context, deployment *appsv1.Deployment, secret *corev1.Secret) error
+}
+
+// NewRestartStrategy returns the implementation of a restart strategy
+func NewRestartStrategy(strategy traktorv1beta1.RestartStrategyType, c client.Client) (RestartStrategy, error) {
+	switch strategy {
+	case traktorv1beta1.RestartStrategyTemplateAnnotation, "":
+		return &templateAnnotationStrategy{client: c}, nil
+	case traktorv1beta1.RestartStrategyDeletePods:
+		return &deletePodsStrategy{client: c}, nil
+	case traktorv1beta1.RestartStrategyScaleBounce:
+		return &scaleBounceStrategy{client: c}, nil
+	case traktorv1beta1.RestartStrategyServerSideApply:
+		return &serverSideApplyStrategy{client: c, fieldManager: traktorv1beta1.DefaultFieldManager}, nil
+	default:
+		return nil, fmt.Errorf("unknown restart strategy %q", strategy)
+	}
+}
+
+// restartStrategyFor returns the restart strategy of the Deployment: a reload when it has
+// a reload URL and only mounts the secret, otherwise its own annotation, GitOps mode or
+// the restart strategy of the first SecretsRefresh that sets one, otherwise the default
+// restart strategy of the TraktorConfig
+func (r *SecretsRefreshReconciler) restartStrategyFor(deployment *appsv1.Deployment, policies []restartPolicy, references []string) (traktorv1beta1.RestartStrategyType, RestartStrategy) {
+	if r.Config.reloadEndpointsEnabled() && reloadable(deployment, references) {
+		return restartStrategyReload, &reloadStrategy{client: r.Client}
+	}
+
+	if raw, ok := deployment.Annotations[traktorv1beta1.RestartStrategyAnnotation]; ok {
+		strategyType := traktorv1beta1.RestartStrategyType(raw)
+		if strategy, err := NewRestartStrategy(strategyType, r.Client); err == nil {
+			return strategyType, strategy
+		}
+		r.recordEvent(deployment, corev1.EventTypeWarning, "InvalidRestartStrategy",
+			fmt.Sprintf("Ignoring unknown restart strategy %q in annotation %s", raw, traktorv1beta1.RestartStrategyAnnotation))
+	}
+
+	for _, p := range policies {
+		if gitOps := p.secretsRefresh.Spec.Rollout.GitOps; gitOps != nil {
+			return gitOpsStrategy(gitOps, r.Client)
+		}
+	}
+
+	strategyType := r.Config.defaultRestartStrategy()
+	for _, p := range policies {
+		if s := p.secretsRefresh.Spec.Rollout.Strategy; s != "" {
+			strategyType = s
+			break
+		}
+	}
+	strategy, err := NewRestartStrategy(strategyType, r.Client)
+	if err != nil {
+		// The CRD only admits known strategies
+		return traktorv1beta1.RestartStrategyTemplateAnnotation, &templateAnnotationStrategy{client: r.Client}
+	}
+	return strategyType, strategy
+}
+
+// templateAnnotationStrategy restarts a deployment using Strategic Merge Patch,
+// similar to 'kubectl rollout restart deployment'
+type templateAnnotationStrategy struct {
+	client client.Client
+}
+
+func (s *templateAnnotationStrategy) Restart(ctx context.Context, deployment *appsv1.Deployment, secret *corev1.Secret) error {
+	annotations, err := restartAnnotations(deployment.Spec.Template.Annotations, secret)
+	if err != nil {
+		return err
+	}
+
+	// Create a patch that adds/updates the restartedAt and secretVersions annotations
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": annotations,
+				},
+			},
+		},
+	}
+
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch: %w", err)
+	}
+
+	// Apply the patch using StrategicMergePatchType
+	return s.client.Patch(ctx, deployment, client.RawPatch(types.StrategicMergePatchType, patchBytes))
+}
+
+// serverSideApplyStrategy sets the same pod template annotations as templateAnnotationStrategy
+// with server-side apply, so they are owned by Traktor's field manager only. With
+// conflictManagers set, it refuses to take the annotations over from those managers.
+type serverSideApplyStrategy struct {
+	client           client.Client
+	fieldManager     string
+	conflictManagers []string
+}
+
+func (s *serverSideApplyStrategy) Restart(ctx context.Context, deployment *appsv1.Deployment, secret *corev1.Secret) error {
+	annotations, err := restartAnnotations(deployment.Spec.Template.Annotations, secret)
+	if err != nil {
+		return err
+	}
+
+	apply := &unstructured.Unstructured{}
+	apply.SetAPIVersion(appsv1.SchemeGroupVersion.String())
+	apply.SetKind("Deployment")
+	apply.SetName(deployment.Name)
+	apply.SetNamespace(deployment.Namespace)
+	if err := unstructured.SetNestedStringMap(apply.Object, annotations, "spec", "template", "metadata", "annotations"); err != nil {
+		return fmt.Errorf("failed to build apply configuration: %w", err)
+	}
+
+	if len(s.conflictManagers) == 0 {
+		return s.client.Patch(ctx, apply, client.Apply, client.FieldOwner(s.fieldManager), client.ForceOwnership)
+	}
+
+	if owners := restartAnnotationOwners(deployment, s.conflictManagers); len(owners) > 0 {
+		return &GitOpsConflictError{Managers: owners}
+	}
+	err = s.client.Patch(ctx, apply, client.Apply, client.FieldOwner(s.fieldManager))
+	if apierrors.IsConflict(err) {
+		return &GitOpsConflictError{Managers: s.conflictManagers, cause: err}
+	}
+	return err
+}
+
+// deletePodsStrategy leaves the pod template alone and marks the Deployment for
+// the PodRecycleReconciler, which evicts its current pods one at a time
+type deletePodsStrategy struct {
+	client client.Client
+}
+
+func (s *deletePodsStrategy) Restart(ctx context.Context, deployment *appsv1.Deployment, secret *corev1.Secret) error {
+	annotations, err := restartAnnotations(deployment.Annotations, secret)
+	if err != nil {
+		return err
+	}
+	// Round up so pods created within the current second are recycled too
+	annotations[traktorv1beta1.RecycleBeforeAnnotation] = time.Now().Add(time.Second).Format(time.RFC3339)
+
+	return patchDeploymentAnnotations(ctx, s.client, deployment, annotations, nil)
+}
+
+// scaleBounceStrategy scales the Deployment to zero, the PodRecycleReconciler scales
+// it back once all pods are gone
+type scaleBounceStrategy struct {
+	client client.Client
+}
+
+func (s *scaleBounceStrategy) Restart(ctx context.Context, deployment *appsv1.Deployment, secret *corev1.Secret) error {
+	annotations, err := restartAnnotations(deployment.Annotations, secret)
+	if err != nil {
+		return err
+	}
+	// Keep the replica count of a bounce that is still in progress
+	if _, bouncing := deployment.Annotations[traktorv1beta1.ScaleBounceReplicasAnnotation]; !bouncing {
+		annotations[traktorv1beta1.ScaleBounceReplicasAnnotation] = strconv.Itoa(int(deploymentReplicas(deployment)))
+	}
+
+	return patchDeploymentAnnotations(ctx, s.client, deployment, annotations, func(d *appsv1.Deployment) {
+		d.Spec.Replicas = new(int32)
+	})
+}
+
+// patchDeploymentAnnotations sets annotations on the Deployment itself and applies
+// an optional spec mutation in the same patch
+func patchDeploymentAnnotations(ctx context.Context, c client.Client, deployment *appsv1.Deployment, annotations map[string]string, mutate func(*appsv1.Deployment)) error {
+	patched := deployment.DeepCopy()
+	if patched.Annotations == nil {
+		patched.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		patched.Annotations[key] = value
+	}
+	if mutate != nil {
+		mutate(patched)
+	}
+	return c.Patch(ctx, patched, client.MergeFrom(deployment))
+}
@@ -0,0 +1,346 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// versionRecheckInterval is how often old versions still referenced by a workload are checked again
+const versionRecheckInterval = 5 * time.Minute
+
+// restartStrategyRewrite is the internal strategy switching workloads to the newest version of a secret
+const restartStrategyRewrite = traktorv1beta1.RestartStrategyType("RewriteReferences")
+
+// secretVersionSuffix matches the version suffix of secret names such as app-db-v7
+var secretVersionSuffix = regexp.MustCompile(`-v(\d+)$`)
+
+// secretSeries is a version of a versioned secret. Only the newest version knows its
+// older versions; versions are immutable, so workloads are never restarted for them.
+type secretSeries struct {
+	// older are the names of the older versions of the secret, empty unless it is the newest
+	older []string
+	// retention is how long older versions are kept once the newest appeared
+	retention time.Duration
+	// since is when the newest version appeared
+	since time.Time
+}
+
+// seriesLabel returns the label naming the series of a secret
+func seriesLabel(vs *traktorv1beta1.VersionedSecrets) string {
+	if vs.SeriesLabel != "" {
+		return vs.SeriesLabel
+	}
+	return traktorv1beta1.DefaultSeriesLabel
+}
+
+// versionRetention returns how long old versions are kept
+func versionRetention(vs *traktorv1beta1.VersionedSecrets) time.Duration {
+	if vs.Retention != nil && vs.Retention.Duration >= 0 {
+		return vs.Retention.Duration
+	}
+	return traktorv1beta1.DefaultVersionRetention
+}
+
+// secretVersionNumber returns the version of a secret in its series, from the version
+// label or the -v<N> suffix of its name
+func secretVersionNumber(secret *corev1.Secret, vs *traktorv1beta1.VersionedSecrets) (int, bool) {
+	raw := ""
+	if vs.VersionLabel != "" {
+		raw = secret.Labels[vs.VersionLabel]
+	} else if match := secretVersionSuffix.FindStringSubmatch(secret.Name); match != nil {
+		raw = match[1]
+	}
+	version, err := strconv.Atoi(raw)
+	return version, err == nil
+}
+
+// versionSeriesFor returns the series of the secret when it is a version of a series
+// configured by one of the matching SecretsRefresh objects, nil otherwise
+func (r *SecretsRefreshReconciler) versionSeriesFor(ctx context.Context, policies []restartPolicy, secret *corev1.Secret) (*secretSeries, error) {
+	for _, p := range policies {
+		vs := p.secretsRefresh.Spec.Triggers.VersionedSecrets
+		if vs == nil {
+			continue
+		}
+		series := secret.Labels[seriesLabel(vs)]
+		version, ok := secretVersionNumber(secret, vs)
+		if series == "" || !ok {
+			continue
+		}
+
+		versions := &corev1.SecretList{}
+		if err := r.List(ctx, versions, client.InNamespace(secret.Namespace), client.MatchingLabels{seriesLabel(vs): series}); err != nil {
+			return nil, fmt.Errorf("failed to list versions of secret series %s: %w", series, err)
+		}
+
+		result := &secretSeries{retention: versionRetention(vs), since: secret.CreationTimestamp.Time}
+		for i := range versions.Items {
+			other := &versions.Items[i]
+			if other.Name == secret.Name {
+				continue
+			}
+			otherVersion, ok := secretVersionNumber(other, vs)
+			if !ok {
+				continue
+			}
+			if otherVersion >= version {
+				// Only the newest version takes over the references
+				return &secretSeries{}, nil
+			}
+			result.older = append(result.older, other.Name)
+		}
+		slices.Sort(result.older)
+		return result, nil
+	}
+	return nil, nil
+}
+
+// referencedBy returns the older versions the Deployment references
+func (s *secretSeries) referencedBy(deployment *appsv1.Deployment) []string {
+	if s == nil {
+		return nil
+	}
+	var outdated []string
+	for _, name := range s.older {
+		if len(secretReferences(deployment, name)) > 0 {
+			outdated = append(outdated, name)
+		}
+	}
+	return outdated
+}
+
+// restartStrategyForVersion returns the strategy for a Deployment referencing the changed
+// secret: workloads still on an older version of a versioned secret are switched to it,
+// all others are restarted with their usual strategy
+func (r *SecretsRefreshReconciler) restartStrategyForVersion(deployment *appsv1.Deployment, policies []restartPolicy, secretName string, series *secretSeries) (traktorv1beta1.RestartStrategyType, RestartStrategy) {
+	if outdated := series.referencedBy(deployment); len(outdated) > 0 {
+		return restartStrategyRewrite, &rewriteStrategy{client: r.Client, outdated: outdated}
+	}
+	return r.restartStrategyFor(deployment, policies, secretReferences(deployment, secretName))
+}
+
+// rewriteStrategy switches the references of a Deployment from older versions of a
+// secret to the newest one, which rolls the pods out like any template change
+type rewriteStrategy struct {
+	client client.Client
+	// outdated are the names of the older versions to replace
+	outdated []string
+}
+
+func (s *rewriteStrategy) Restart(ctx context.Context, deployment *appsv1.Deployment, secret *corev1.Secret) error {
+	annotations, err := restartAnnotations(deployment.Spec.Template.Annotations, secret)
+	if err != nil {
+		return err
+	}
+
+	patched := deployment.DeepCopy()
+	rewriteSecretReferences(&patched.Spec.Template.Spec, s.outdated, secret.Name)
+	if patched.Spec.Template.Annotations == nil {
+		patched.Spec.Template.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		patched.Spec.Template.Annotations[key] = value
+	}
+	return s.client.Patch(ctx, patched, client.MergeFrom(deployment))
+}
+
+// rewriteSecretReferences replaces every reference to one of the old secret names in
+// the pod spec with the new name, in the same places secretReferences looks at
+func rewriteSecretReferences(podSpec *corev1.PodSpec, old []string, name string) {
+	rewrite := func(ref *string) {
+		if slices.Contains(old, *ref) {
+			*ref = name
+		}
+	}
+	rewriteEnv := func(envFrom []corev1.EnvFromSource, env []corev1.EnvVar) {
+		for i := range envFrom {
+			if envFrom[i].SecretRef != nil {
+				rewrite(&envFrom[i].SecretRef.Name)
+			}
+		}
+		for i := range env {
+			if env[i].ValueFrom != nil && env[i].ValueFrom.SecretKeyRef != nil {
+				rewrite(&env[i].ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+
+	for i := range podSpec.Volumes {
+		if podSpec.Volumes[i].Secret != nil {
+			rewrite(&podSpec.Volumes[i].Secret.SecretName)
+		}
+	}
+	for i := range podSpec.InitContainers {
+		rewriteEnv(podSpec.InitContainers[i].EnvFrom, podSpec.InitContainers[i].Env)
+	}
+	for i := range podSpec.Containers {
+		rewriteEnv(podSpec.Containers[i].EnvFrom, podSpec.Containers[i].Env)
+	}
+	for i := range podSpec.EphemeralContainers {
+		rewriteEnv(podSpec.EphemeralContainers[i].EnvFrom, podSpec.EphemeralContainers[i].Env)
+	}
+	for i := range podSpec.ImagePullSecrets {
+		rewrite(&podSpec.ImagePullSecrets[i].Name)
+	}
+}
+
+// hasSeriesLabel reports whether the secret carries the series label of a SecretsRefresh
+// handling versioned secrets, so only created secrets that may be a new version are
+// matched against the SecretsRefresh objects
+func (r *SecretsRefreshReconciler) hasSeriesLabel(ctx context.Context, secret client.Object) bool {
+	secretLabels := secret.GetLabels()
+	if len(secretLabels) == 0 {
+		return false
+	}
+	srs, err := listSecretsRefreshes(ctx, r.Client)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list SecretsRefresh objects")
+		return false
+	}
+	for _, sr := range srs {
+		if vs := sr.Spec.Triggers.VersionedSecrets; vs != nil && secretLabels[seriesLabel(vs)] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// findVersionedSecretsRefreshForSecret enqueues a created secret when it is a version
+// of a series configured by a matching SecretsRefresh
+func (r *SecretsRefreshReconciler) findVersionedSecretsRefreshForSecret(ctx context.Context, secret client.Object) []SecretRequest {
+	logger := log.FromContext(ctx)
+
+	srs, err := r.secretsRefreshesForSecret(ctx, secret)
+	if err != nil {
+		logger.Error(err, "Failed to list SecretsRefresh objects")
+		return nil
+	}
+
+	for _, sr := range srs {
+		vs := sr.Spec.Triggers.VersionedSecrets
+		if vs != nil && secret.GetLabels()[seriesLabel(vs)] != "" {
+			return []SecretRequest{newSecretRequest(secret, srs)}
+		}
+	}
+	return nil
+}
+
+// secretVersionConsumers returns the pod specs in the namespace that may still use an
+// old version of a secret: those of the Deployments and all other workloads, of the
+// ReplicaSets kept for rollout undo and of the pods that haven't terminated
+func (r *SecretsRefreshReconciler) secretVersionConsumers(ctx context.Context, namespace string, deployments []appsv1.Deployment) ([]*corev1.PodSpec, error) {
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	inNamespace := client.InNamespace(namespace)
+
+	var podSpecs []*corev1.PodSpec
+	for i := range deployments {
+		podSpecs = append(podSpecs, &deployments[i].Spec.Template.Spec)
+	}
+
+	replicaSets := &appsv1.ReplicaSetList{}
+	if err := reader.List(ctx, replicaSets, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+	for i := range replicaSets.Items {
+		podSpecs = append(podSpecs, &replicaSets.Items[i].Spec.Template.Spec)
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := reader.List(ctx, statefulSets, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for i := range statefulSets.Items {
+		podSpecs = append(podSpecs, &statefulSets.Items[i].Spec.Template.Spec)
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := reader.List(ctx, daemonSets, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	for i := range daemonSets.Items {
+		podSpecs = append(podSpecs, &daemonSets.Items[i].Spec.Template.Spec)
+	}
+
+	cronJobs := &batchv1.CronJobList{}
+	if err := reader.List(ctx, cronJobs, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list cronjobs: %w", err)
+	}
+	for i := range cronJobs.Items {
+		podSpecs = append(podSpecs, &cronJobs.Items[i].Spec.JobTemplate.Spec.Template.Spec)
+	}
+
+	jobs := &batchv1.JobList{}
+	if err := reader.List(ctx, jobs, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for i := range jobs.Items {
+		podSpecs = append(podSpecs, &jobs.Items[i].Spec.Template.Spec)
+	}
+
+	// Bare pods and pods of workloads Traktor doesn't know, terminated ones no longer
+	// need the secret
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for i := range pods.Items {
+		if phase := pods.Items[i].Status.Phase; phase != corev1.PodSucceeded && phase != corev1.PodFailed {
+			podSpecs = append(podSpecs, &pods.Items[i].Spec)
+		}
+	}
+	return podSpecs, nil
+}
+
+// collectSecretVersions deletes older versions of the series that no pod spec in the
+// namespace references once the retention period has passed. It returns when the
+// remaining versions become eligible, zero if there are none.
+func (r *SecretsRefreshReconciler) collectSecretVersions(ctx context.Context, secret *corev1.Secret, series *secretSeries, consumers []*corev1.PodSpec, now time.Time) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+
+	if len(series.older) == 0 {
+		return 0, nil
+	}
+	if wait := series.since.Add(series.retention).Sub(now); wait > 0 {
+		return wait, nil
+	}
+
+	var retry time.Duration
+	for _, name := range series.older {
+		referenced := slices.ContainsFunc(consumers, func(podSpec *corev1.PodSpec) bool {
+			return len(podSpecSecretReferences(podSpec, name)) > 0
+		})
+		if referenced {
+			// Deleted once the workload has been switched to the newest version
+			retry = versionRecheckInterval
+			continue
+		}
+
+		old := &corev1.Secret{}
+		old.Name = name
+		old.Namespace = secret.Namespace
+		if err := r.Delete(ctx, old); err != nil && !apierrors.IsNotFound(err) {
+			return 0, fmt.Errorf("failed to delete old secret version %s: %w", name, err)
+		}
+		logger.Info("Deleted unreferenced old secret version",
+			"secret", name,
+			"namespace", secret.Namespace,
+			"newest", secret.Name)
+	}
+	return retry, nil
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// Reasons recorded for restarts deferred because of the workload state
+const (
+	ReasonWorkloadPaused    = "WorkloadPaused"
+	ReasonRolloutInProgress = "RolloutInProgress"
+)
+
+// secretVersion identifies the data of a secret without exposing it
+func secretVersion(secret *corev1.Secret) string {
+	sum := sha256.Sum256([]byte(hashSecretData(secret)))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// stampedVersions returns the secret versions recorded in the given annotations
+func stampedVersions(annotations map[string]string) map[string]string {
+	versions := map[string]string{}
+	if raw, ok := annotations[traktorv1beta1.SecretVersionsAnnotation]; ok {
+		// A broken annotation is overwritten on the next restart
+		_ = json.Unmarshal([]byte(raw), &versions)
+	}
+	return versions
+}
+
+// secretVersions returns the secret versions stamped on the pod template of the Deployment
+func secretVersions(deployment *appsv1.Deployment) map[string]string {
+	return stampedVersions(deployment.Spec.Template.Annotations)
+}
+
+// secretVersionApplied reports whether the pods of the Deployment already run with
+// the current data of the secret, e.g. because a previous attempt restarted it.
+// Strategies that leave the pod template alone stamp the Deployment itself.
+func secretVersionApplied(deployment *appsv1.Deployment, secret *corev1.Secret) bool {
+	version := secretVersion(secret)
+	return secretVersions(deployment)[secret.Name] == version ||
+		stampedVersions(deployment.Annotations)[secret.Name] == version
+}
+
+// secretVersionsAnnotation returns the secret versions annotation with the current
+// version of the secret stamped in
+func secretVersionsAnnotation(annotations map[string]string, secret *corev1.Secret) (string, error) {
+	versions := stampedVersions(annotations)
+	versions[secret.Name] = secretVersion(secret)
+	raw, err := json.Marshal(versions)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal secret versions: %w", err)
+	}
+	return string(raw), nil
+}
+
+// restartAnnotations returns the annotations that record a restart for the secret,
+// based on the current annotations of the pod template or the workload
+func restartAnnotations(annotations map[string]string, secret *corev1.Secret) (map[string]string, error) {
+	versions, err := secretVersionsAnnotation(annotations, secret)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		traktorv1beta1.RestartedAtAnnotation:    time.Now().Format(time.RFC3339),
+		traktorv1beta1.SecretVersionsAnnotation: versions,
+	}, nil
+}
+
+// stampSecretVersion records the current secret version on a Deployment scaled to zero,
+// so the pods it starts later are known to be fresh without restarting anything now
+func (r *SecretsRefreshReconciler) stampSecretVersion(ctx context.Context, deployment *appsv1.Deployment, secret *corev1.Secret) error {
+	versions, err := secretVersionsAnnotation(deployment.Spec.Template.Annotations, secret)
+	if err != nil {
+		return err
+	}
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						traktorv1beta1.SecretVersionsAnnotation: versions,
+					},
+				},
+			},
+		},
+	}
+
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch: %w", err)
+	}
+	return r.Patch(ctx, deployment, client.RawPatch(types.StrategicMergePatchType, patchBytes))
+}
+
+// restartRequired reports whether the Deployment has to be restarted to pick up the
+// secret through the given references. Hot-reloading workloads read mounted secret
+// files live, so only references that never update in place need a restart.
+func restartRequired(deployment *appsv1.Deployment, references []string) bool {
+	if deployment.Annotations[traktorv1beta1.HotReloadAnnotation] != "true" {
+		return true
+	}
+	for _, reference := range references {
+		if reference != ReferenceVolume {
+			return true
+		}
+	}
+	return false
+}
+
+// rolloutBlocker returns why the Deployment can't be restarted right now: it is
+// paused, so the restart would roll out on resume, or it is still rolling out a
+// previous change, so a restart would stack ReplicaSets
+func rolloutBlocker(deployment *appsv1.Deployment) string {
+	if deployment.Spec.Paused {
+		return ReasonWorkloadPaused
+	}
+	if rolloutInProgress(deployment) {
+		return ReasonRolloutInProgress
+	}
+	return ""
+}
+
+// rolloutInProgress reports whether the Deployment controller is still replacing the
+// pods of a previous ReplicaSet. Availability doesn't count: a crashlooping Deployment
+// that runs the current template everywhere has nothing left to roll out, and a
+// restart may be exactly what brings it back.
+func rolloutInProgress(deployment *appsv1.Deployment) bool {
+	status := deployment.Status
+	if status.ObservedGeneration < deployment.Generation {
+		return true
+	}
+	for _, condition := range status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing &&
+			(condition.Reason == "NewReplicaSetAvailable" || condition.Reason == "ProgressDeadlineExceeded") {
+			// Finished or given up, either way nothing is rolling out
+			return false
+		}
+	}
+	return status.UpdatedReplicas < deploymentReplicas(deployment) || status.Replicas > status.UpdatedReplicas
+}
+
+// deferWorkloads records the workloads whose restart waits for them to be resumed or
+// to finish their rollout in the status of the matching SecretsRefresh objects
+func (r *SecretsRefreshReconciler) deferWorkloads(ctx context.Context, policies []restartPolicy, secret *corev1.Secret, deferred []*appsv1.Deployment, reason string, now time.Time) error {
+	workloads := make([]traktorv1beta1.WorkloadReference, 0, len(deferred))
+	for _, deployment := range deferred {
+		workloads = append(workloads, traktorv1beta1.WorkloadReference{
+			Kind:      "Deployment",
+			Name:      deployment.Name,
+			Namespace: deployment.Namespace,
+		})
+	}
+	pending := traktorv1beta1.PendingRestart{
+		SecretName:      secret.Name,
+		SecretNamespace: secret.Namespace,
+		DetectedAt:      metav1.NewTime(now),
+		Reason:          reason,
+		Workloads:       workloads,
+	}
+
+	for _, p := range policies {
+		added, err := r.upsertPendingRestart(ctx, p.secretsRefresh, pending)
+		if err != nil {
+			return err
+		}
+		if added {
+			r.recordEvent(p.secretsRefresh, corev1.EventTypeNormal, "RestartDeferred",
+				fmt.Sprintf("Restarts of %d workload(s) for secret %s/%s deferred: %s",
+					len(workloads), secret.Namespace, secret.Name, reason))
+		}
+	}
+	return nil
+}
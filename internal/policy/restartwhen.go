@@ -0,0 +1,210 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policy contains the CEL based policies that decide whether a secret
+// change should restart a workload.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/ext"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Variable names available to spec.restartWhen expressions.
+// "namespace" is a reserved word in CEL, so the namespace is exposed as
+// namespaceObject, the same name Kubernetes admission policies use.
+const (
+	VarOldSecret = "oldSecret"
+	VarNewSecret = "newSecret"
+	VarWorkload  = "workload"
+	VarNamespace = "namespaceObject"
+	VarNow       = "now"
+)
+
+// Tenants author restartWhen expressions that run on the shared reconcile worker, so
+// their cost is bounded like Kubernetes bounds CEL in admission policies.
+const (
+	// RestartWhenCostLimit is the cost an evaluation may spend before it's aborted
+	RestartWhenCostLimit = 1_000_000
+	// RestartWhenEstimatedCostLimit is the worst case cost an expression may have on
+	// objects as large as the API server accepts, more expensive ones are rejected
+	RestartWhenEstimatedCostLimit = 100 * RestartWhenCostLimit
+	// restartWhenTimeout aborts evaluations that run longer, whatever their cost
+	restartWhenTimeout = time.Second
+	// maxObjectSize is the largest request the API server accepts, it bounds the size
+	// of every string, list and map an expression can see
+	maxObjectSize = 3 * 1024 * 1024
+)
+
+// objectSizeEstimator estimates every value of unknown size as large as an object may be
+type objectSizeEstimator struct{}
+
+func (objectSizeEstimator) EstimateSize(checker.AstNode) *checker.SizeEstimate {
+	return &checker.SizeEstimate{Min: 0, Max: maxObjectSize}
+}
+
+func (objectSizeEstimator) EstimateCallCost(string, string, *checker.AstNode, []checker.AstNode) *checker.CallEstimate {
+	return nil
+}
+
+var (
+	envOnce sync.Once
+	env     *cel.Env
+	envErr  error
+)
+
+// restartWhenEnv returns the shared CEL environment used to compile restartWhen expressions
+func restartWhenEnv() (*cel.Env, error) {
+	envOnce.Do(func() {
+		objectType := cel.MapType(cel.StringType, cel.DynType)
+		env, envErr = cel.NewEnv(
+			cel.Variable(VarOldSecret, objectType),
+			cel.Variable(VarNewSecret, objectType),
+			cel.Variable(VarWorkload, objectType),
+			cel.Variable(VarNamespace, objectType),
+			cel.Variable(VarNow, cel.TimestampType),
+			ext.Strings(),
+		)
+	})
+	return env, envErr
+}
+
+// RestartWhen is a compiled and type-checked spec.restartWhen expression
+type RestartWhen struct {
+	expression string
+	program    cel.Program
+}
+
+// CompileRestartWhen parses and type-checks a restartWhen expression.
+// The expression must evaluate to a bool.
+func CompileRestartWhen(expression string) (*RestartWhen, error) {
+	e, err := restartWhenEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+
+	ast, issues := e.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid restartWhen expression: %w", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("restartWhen expression must return bool, got %s", ast.OutputType())
+	}
+
+	cost, err := e.EstimateCost(ast, objectSizeEstimator{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate restartWhen cost: %w", err)
+	}
+	if cost.Max > RestartWhenEstimatedCostLimit {
+		return nil, fmt.Errorf("restartWhen expression is too expensive: estimated cost %d exceeds the limit of %d", cost.Max, RestartWhenEstimatedCostLimit)
+	}
+
+	program, err := e.Program(ast,
+		cel.CostLimit(RestartWhenCostLimit),
+		cel.InterruptCheckFrequency(100),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build restartWhen program: %w", err)
+	}
+
+	return &RestartWhen{expression: expression, program: program}, nil
+}
+
+// String returns the source expression
+func (p *RestartWhen) String() string {
+	return p.expression
+}
+
+// RestartWhenInput holds the objects exposed to a restartWhen expression.
+// Nil objects are exposed as empty maps.
+type RestartWhenInput struct {
+	OldSecret runtime.Object
+	NewSecret runtime.Object
+	Workload  runtime.Object
+	Namespace runtime.Object
+	Now       time.Time
+}
+
+// Evaluate runs the expression against the given input
+func (p *RestartWhen) Evaluate(input RestartWhenInput) (bool, error) {
+	vars := map[string]interface{}{
+		VarNow: input.Now,
+	}
+
+	objects := map[string]runtime.Object{
+		VarOldSecret: input.OldSecret,
+		VarNewSecret: input.NewSecret,
+		VarWorkload:  input.Workload,
+		VarNamespace: input.Namespace,
+	}
+	for name, obj := range objects {
+		value, err := toMap(obj)
+		if err != nil {
+			return false, fmt.Errorf("failed to convert %s: %w", name, err)
+		}
+		vars[name] = value
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), restartWhenTimeout)
+	defer cancel()
+	out, _, err := p.program.ContextEval(ctx, vars)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate restartWhen expression: %w", err)
+	}
+
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("restartWhen expression returned %T, expected bool", out.Value())
+	}
+
+	return result, nil
+}
+
+// toMap converts a Kubernetes object to its unstructured representation
+func toMap(obj runtime.Object) (map[string]interface{}, error) {
+	if obj == nil || reflect.ValueOf(obj).IsNil() {
+		return map[string]interface{}{}, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+// Cache keeps compiled restartWhen expressions keyed by their source
+type Cache struct {
+	programs sync.Map
+}
+
+// Get returns the compiled expression, compiling and caching it on first use
+func (c *Cache) Get(expression string) (*RestartWhen, error) {
+	if cached, ok := c.programs.Load(expression); ok {
+		return cached.(*RestartWhen), nil
+	}
+
+	compiled, err := CompileRestartWhen(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	c.programs.Store(expression, compiled)
+	return compiled, nil
+}
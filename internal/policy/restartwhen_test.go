@@ -0,0 +1,124 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("RestartWhen", func() {
+	var (
+		oldSecret  *corev1.Secret
+		newSecret  *corev1.Secret
+		deployment *appsv1.Deployment
+		namespace  *corev1.Namespace
+	)
+
+	BeforeEach(func() {
+		oldSecret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "db",
+				Annotations: map[string]string{"rotation-id": "1"},
+			},
+		}
+		newSecret = oldSecret.DeepCopy()
+
+		replicas := int32(3)
+		deployment = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "app"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		}
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"tier": "critical"}},
+		}
+	})
+
+	It("should compare annotations of old and new secret", func() {
+		p, err := CompileRestartWhen(`oldSecret.metadata.annotations['rotation-id'] != newSecret.metadata.annotations['rotation-id']`)
+		Expect(err).NotTo(HaveOccurred())
+
+		input := RestartWhenInput{OldSecret: oldSecret, NewSecret: newSecret}
+		Expect(p.Evaluate(input)).To(BeFalse())
+
+		newSecret.Annotations["rotation-id"] = "2"
+		Expect(p.Evaluate(input)).To(BeTrue())
+	})
+
+	It("should expose workload, namespace and now", func() {
+		p, err := CompileRestartWhen(`workload.spec.replicas >= 2 && namespaceObject.metadata.labels.tier == 'critical' && now.getHours('UTC') < 9`)
+		Expect(err).NotTo(HaveOccurred())
+
+		early := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+		input := RestartWhenInput{Workload: deployment, Namespace: namespace, Now: early}
+		Expect(p.Evaluate(input)).To(BeTrue())
+
+		input.Now = early.Add(10 * time.Hour)
+		Expect(p.Evaluate(input)).To(BeFalse())
+	})
+
+	It("should expose a missing previous secret as an empty map", func() {
+		p, err := CompileRestartWhen(`!has(oldSecret.metadata)`)
+		Expect(err).NotTo(HaveOccurred())
+
+		var missing *corev1.Secret
+		Expect(p.Evaluate(RestartWhenInput{OldSecret: missing, NewSecret: newSecret})).To(BeTrue())
+	})
+
+	It("should return an error when evaluation fails", func() {
+		p, err := CompileRestartWhen(`newSecret.metadata.labels['missing'] == 'x'`)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = p.Evaluate(RestartWhenInput{NewSecret: newSecret})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject expressions whose worst case cost is over budget", func() {
+		_, err := CompileRestartWhen(`newSecret.data.exists(k, oldSecret.data.exists(l, newSecret.data[k] == oldSecret.data[l]))`)
+		Expect(err).To(MatchError(ContainSubstring("too expensive")))
+
+		_, err = CompileRestartWhen(`workload.spec.template.spec.containers.exists(c, c.name == 'app')`)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should abort evaluations that exceed the cost limit", func() {
+		p, err := CompileRestartWhen(`newSecret.metadata.annotations['blob'].contains('needle')`)
+		Expect(err).NotTo(HaveOccurred())
+
+		newSecret.Annotations["blob"] = strings.Repeat("x", 20*1024*1024)
+		_, err = p.Evaluate(RestartWhenInput{NewSecret: newSecret})
+		Expect(err).To(MatchError(ContainSubstring("cost limit")))
+	})
+
+	It("should reuse compiled programs from the cache", func() {
+		cache := &Cache{}
+		first, err := cache.Get(`true`)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := cache.Get(`true`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second).To(BeIdenticalTo(first))
+
+		_, err = cache.Get(`1 + `)
+		Expect(err).To(HaveOccurred())
+	})
+})
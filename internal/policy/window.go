@@ -0,0 +1,110 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// Window is a parsed maintenance window
+type Window struct {
+	schedule cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+// ParseWindow parses and validates a maintenance window
+func ParseWindow(w traktorv1beta1.MaintenanceWindow) (*Window, error) {
+	schedule, err := cron.ParseStandard(w.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", w.Schedule, err)
+	}
+	// Schedules like the 30th of February are valid cron but never open a window
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("schedule %q never fires", w.Schedule)
+	}
+
+	if w.Duration.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive, got %s", w.Duration.Duration)
+	}
+
+	location := time.UTC
+	if w.TimeZone != "" {
+		location, err = time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", w.TimeZone, err)
+		}
+	}
+
+	return &Window{schedule: schedule, duration: w.Duration.Duration, location: location}, nil
+}
+
+// State reports whether the window is open at now. If it is open, at is when it
+// closes, otherwise at is when it opens next, zero if it never opens.
+func (w *Window) State(now time.Time) (open bool, at time.Time) {
+	local := now.In(w.location)
+
+	// The first start after (now - duration) is either inside the window or the next opening
+	start := w.schedule.Next(local.Add(-w.duration))
+	if start.IsZero() {
+		return false, time.Time{}
+	}
+	if !start.After(local) {
+		return true, start.Add(w.duration)
+	}
+	return false, start
+}
+
+// Windows is a set of maintenance windows. An empty set is always open.
+type Windows []*Window
+
+// ParseWindows parses all maintenance windows of a SecretsRefresh
+func ParseWindows(windows []traktorv1beta1.MaintenanceWindow) (Windows, error) {
+	parsed := make(Windows, 0, len(windows))
+	for i, w := range windows {
+		p, err := ParseWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %d: %w", i, err)
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+// NextOpen returns whether any window is open at now and, if none is, when the
+// earliest one opens, zero if none ever opens.
+func (ws Windows) NextOpen(now time.Time) (open bool, next time.Time) {
+	if len(ws) == 0 {
+		return true, now
+	}
+
+	for _, w := range ws {
+		isOpen, at := w.State(now)
+		if isOpen {
+			return true, now
+		}
+		if !at.IsZero() && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	return false, next
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+var _ = Describe("Maintenance windows", func() {
+	// 22:00-02:00 on weekdays, Berlin time
+	nightly := traktorv1beta1.MaintenanceWindow{
+		Schedule: "0 22 * * 1-5",
+		Duration: metav1.Duration{Duration: 4 * time.Hour},
+		TimeZone: "Europe/Berlin",
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	Expect(err).NotTo(HaveOccurred())
+
+	It("should be open inside the window, including after midnight", func() {
+		w, err := ParseWindow(nightly)
+		Expect(err).NotTo(HaveOccurred())
+
+		// Monday 2026-01-05 23:30 Berlin
+		open, closes := w.State(time.Date(2026, 1, 5, 23, 30, 0, 0, berlin))
+		Expect(open).To(BeTrue())
+		Expect(closes).To(BeTemporally("==", time.Date(2026, 1, 6, 2, 0, 0, 0, berlin)))
+
+		// Tuesday 01:00 is still inside Monday's window
+		open, _ = w.State(time.Date(2026, 1, 6, 1, 0, 0, 0, berlin))
+		Expect(open).To(BeTrue())
+	})
+
+	It("should report the next opening outside the window", func() {
+		w, err := ParseWindow(nightly)
+		Expect(err).NotTo(HaveOccurred())
+
+		// Saturday 03:00 Berlin opens again Monday 22:00
+		open, opens := w.State(time.Date(2026, 1, 10, 3, 0, 0, 0, berlin))
+		Expect(open).To(BeFalse())
+		Expect(opens).To(BeTemporally("==", time.Date(2026, 1, 12, 22, 0, 0, 0, berlin)))
+	})
+
+	It("should pick the earliest opening of several windows", func() {
+		windows, err := ParseWindows([]traktorv1beta1.MaintenanceWindow{
+			nightly,
+			{Schedule: "0 12 * * *", Duration: metav1.Duration{Duration: time.Hour}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		open, next := windows.NextOpen(time.Date(2026, 1, 10, 3, 0, 0, 0, time.UTC))
+		Expect(open).To(BeFalse())
+		Expect(next).To(BeTemporally("==", time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)))
+
+		open, _ = windows.NextOpen(time.Date(2026, 1, 10, 12, 30, 0, 0, time.UTC))
+		Expect(open).To(BeTrue())
+	})
+
+	It("should treat no windows as always open", func() {
+		open, _ := Windows{}.NextOpen(time.Now())
+		Expect(open).To(BeTrue())
+	})
+
+	It("should reject invalid windows", func() {
+		_, err := ParseWindow(traktorv1beta1.MaintenanceWindow{Schedule: "* *", Duration: metav1.Duration{Duration: time.Hour}})
+		Expect(err).To(HaveOccurred())
+		_, err = ParseWindow(traktorv1beta1.MaintenanceWindow{Schedule: "0 1 * * *"})
+		Expect(err).To(HaveOccurred())
+		_, err = ParseWindow(traktorv1beta1.MaintenanceWindow{Schedule: "0 0 30 2 *", Duration: metav1.Duration{Duration: time.Hour}})
+		Expect(err).To(MatchError(ContainSubstring("never fires")))
+	})
+
+	It("should never open a window whose schedule never fires", func() {
+		schedule, err := cron.ParseStandard("0 0 30 2 *")
+		Expect(err).NotTo(HaveOccurred())
+		never := &Window{schedule: schedule, duration: time.Hour, location: time.UTC}
+
+		open, at := never.State(time.Now())
+		Expect(open).To(BeFalse())
+		Expect(at).To(BeZero())
+
+		By("Still reporting the next opening of the other windows")
+		daily, err := ParseWindow(traktorv1beta1.MaintenanceWindow{Schedule: "0 3 * * *", Duration: metav1.Duration{Duration: time.Hour}})
+		Expect(err).NotTo(HaveOccurred())
+		now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+		open, next := Windows{never, daily}.NextOpen(now)
+		Expect(open).To(BeFalse())
+		Expect(next).To(Equal(time.Date(2026, 3, 3, 3, 0, 0, 0, time.UTC)))
+		open, next = Windows{never}.NextOpen(now)
+		Expect(open).To(BeFalse())
+		Expect(next).To(BeZero())
+	})
+})
@@ -0,0 +1,108 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var clustersecretsrefreshlog = logf.Log.WithName("clustersecretsrefresh-resource")
+
+// SetupClusterSecretsRefreshWebhookWithManager registers the webhook for ClusterSecretsRefresh in the manager.
+func SetupClusterSecretsRefreshWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&traktorv1beta1.ClusterSecretsRefresh{}).
+		WithValidator(&ClusterSecretsRefreshCustomValidator{}).
+		WithDefaulter(&ClusterSecretsRefreshCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-traktor-gdxcloud-net-v1beta1-clustersecretsrefresh,mutating=true,failurePolicy=fail,sideEffects=None,groups=traktor.gdxcloud.net,resources=clustersecretsrefreshes,verbs=create;update,versions=v1beta1,name=mclustersecretsrefresh-v1beta1.kb.io,admissionReviewVersions=v1
+
+// ClusterSecretsRefreshCustomDefaulter sets the policy defaults of ClusterSecretsRefresh resources when they are created or updated.
+type ClusterSecretsRefreshCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &ClusterSecretsRefreshCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type ClusterSecretsRefresh.
+func (d *ClusterSecretsRefreshCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	clustersecretsrefresh, ok := obj.(*traktorv1beta1.ClusterSecretsRefresh)
+	if !ok {
+		return fmt.Errorf("expected a ClusterSecretsRefresh object but got %T", obj)
+	}
+	clustersecretsrefreshlog.Info("Defaulting for ClusterSecretsRefresh", "name", clustersecretsrefresh.GetName())
+
+	defaultSecretsRefreshSpec(&clustersecretsrefresh.Spec)
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-traktor-gdxcloud-net-v1beta1-clustersecretsrefresh,mutating=false,failurePolicy=fail,sideEffects=None,groups=traktor.gdxcloud.net,resources=clustersecretsrefreshes,verbs=create;update,versions=v1beta1,name=vclustersecretsrefresh-v1beta1.kb.io,admissionReviewVersions=v1
+
+// ClusterSecretsRefreshCustomValidator validates ClusterSecretsRefresh resources when they are created or updated.
+type ClusterSecretsRefreshCustomValidator struct{}
+
+var _ webhook.CustomValidator = &ClusterSecretsRefreshCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type ClusterSecretsRefresh.
+func (v *ClusterSecretsRefreshCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	clustersecretsrefresh, ok := obj.(*traktorv1beta1.ClusterSecretsRefresh)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterSecretsRefresh object but got %T", obj)
+	}
+	clustersecretsrefreshlog.Info("Validation for ClusterSecretsRefresh upon creation", "name", clustersecretsrefresh.GetName())
+
+	return validateClusterSecretsRefresh(clustersecretsrefresh)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type ClusterSecretsRefresh.
+func (v *ClusterSecretsRefreshCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	clustersecretsrefresh, ok := newObj.(*traktorv1beta1.ClusterSecretsRefresh)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterSecretsRefresh object for the newObj but got %T", newObj)
+	}
+	clustersecretsrefreshlog.Info("Validation for ClusterSecretsRefresh upon update", "name", clustersecretsrefresh.GetName())
+
+	return validateClusterSecretsRefresh(clustersecretsrefresh)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type ClusterSecretsRefresh.
+func (v *ClusterSecretsRefreshCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateClusterSecretsRefresh validates a ClusterSecretsRefresh
+func validateClusterSecretsRefresh(csr *traktorv1beta1.ClusterSecretsRefresh) (admission.Warnings, error) {
+	warnings, allErrs := validateSecretsRefreshSpec(&csr.Spec, true)
+	if len(allErrs) == 0 {
+		return warnings, nil
+	}
+
+	return warnings, apierrors.NewInvalid(
+		traktorv1beta1.GroupVersion.WithKind("ClusterSecretsRefresh").GroupKind(),
+		csr.Name, allErrs)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+var _ = Describe("ClusterSecretsRefresh Webhook", func() {
+	var (
+		ctx       context.Context
+		obj       *traktorv1beta1.ClusterSecretsRefresh
+		validator ClusterSecretsRefreshCustomValidator
+		defaulter ClusterSecretsRefreshCustomDefaulter
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		obj = &traktorv1beta1.ClusterSecretsRefresh{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-csr"},
+			Spec: traktorv1beta1.SecretsRefreshSpec{
+				Targets: traktorv1beta1.Targets{
+					SecretSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"auto-refresh": "enabled"}},
+				},
+			},
+		}
+		validator = ClusterSecretsRefreshCustomValidator{}
+		defaulter = ClusterSecretsRefreshCustomDefaulter{}
+	})
+
+	Context("When validating the namespace selector", func() {
+		It("should deny an empty namespace selector without allNamespaces", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.targets.namespaceSelector"))
+
+			obj.Spec.Targets.NamespaceSelector = &metav1.LabelSelector{}
+			_, err = validator.ValidateUpdate(ctx, obj.DeepCopy(), obj)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should admit all namespaces when confirmed", func() {
+			obj.Spec.Targets.AllNamespaces = true
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("should admit a namespace selector", func() {
+			obj.Spec.Targets.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "production"}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("When validating actions", func() {
+		It("should admit job templates and CronJobs of any namespace", func() {
+			obj.Spec.Targets.AllNamespaces = true
+			obj.Spec.Actions = []traktorv1beta1.Action{
+				{Name: "inline", RunJob: &traktorv1beta1.RunJobAction{JobTemplate: &batchv1.JobTemplateSpec{}}},
+				{Name: "shared", RunJob: &traktorv1beta1.RunJobAction{
+					CronJobRef: &traktorv1beta1.CronJobReference{Name: "sync", Namespace: "jobs"},
+				}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("When validating notifications", func() {
+		It("should deny webhooks without an http or https URL", func() {
+			obj.Spec.Targets.AllNamespaces = true
+			obj.Spec.Notifications = &traktorv1beta1.Notifications{
+				Webhooks: []traktorv1beta1.NotificationWebhook{
+					{Name: "chat", URL: "https://chat.example.com/hooks/traktor"},
+					{Name: "broken", URL: "https://"},
+					{Name: "file", URL: "file:///etc/passwd"},
+				},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.notifications.webhooks[1].url"))
+			Expect(err.Error()).To(ContainSubstring("spec.notifications.webhooks[2].url"))
+			Expect(err.Error()).NotTo(ContainSubstring("spec.notifications.webhooks[0]"))
+		})
+	})
+
+	Context("When defaulting", func() {
+		It("should fill in the policy defaults", func() {
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Rollout.DisruptionPolicy).To(Equal(traktorv1beta1.DisruptionPolicyWarn))
+		})
+	})
+})
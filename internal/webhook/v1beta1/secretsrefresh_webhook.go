@@ -0,0 +1,332 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1beta1 contains the admission webhooks for the v1beta1 API, which also cover
+// v1alpha1 requests as they are converted before admission.
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+	"github.com/GDXbsv/traktor/internal/policy"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var secretsrefreshlog = logf.Log.WithName("secretsrefresh-resource")
+
+// SetupSecretsRefreshWebhookWithManager registers the webhook for SecretsRefresh in the manager.
+func SetupSecretsRefreshWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&traktorv1beta1.SecretsRefresh{}).
+		WithValidator(&SecretsRefreshCustomValidator{Reader: mgr.GetClient()}).
+		WithDefaulter(&SecretsRefreshCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-traktor-gdxcloud-net-v1beta1-secretsrefresh,mutating=true,failurePolicy=fail,sideEffects=None,groups=traktor.gdxcloud.net,resources=secretsrefreshes,verbs=create;update,versions=v1beta1,name=msecretsrefresh-v1beta1.kb.io,admissionReviewVersions=v1
+
+// SecretsRefreshCustomDefaulter sets the policy defaults of SecretsRefresh resources when they are created or updated.
+type SecretsRefreshCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &SecretsRefreshCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type SecretsRefresh.
+func (d *SecretsRefreshCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	secretsrefresh, ok := obj.(*traktorv1beta1.SecretsRefresh)
+	if !ok {
+		return fmt.Errorf("expected a SecretsRefresh object but got %T", obj)
+	}
+	secretsrefreshlog.Info("Defaulting for SecretsRefresh", "name", secretsrefresh.GetName())
+
+	defaultSecretsRefreshSpec(&secretsrefresh.Spec)
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-traktor-gdxcloud-net-v1beta1-secretsrefresh,mutating=false,failurePolicy=fail,sideEffects=None,groups=traktor.gdxcloud.net,resources=secretsrefreshes,verbs=create;update,versions=v1beta1,name=vsecretsrefresh-v1beta1.kb.io,admissionReviewVersions=v1
+
+// SecretsRefreshCustomValidator validates SecretsRefresh resources when they are created or updated.
+type SecretsRefreshCustomValidator struct {
+	// Reader reads the TraktorConfig. Without it the TraktorConfig defaults apply.
+	Reader client.Reader
+}
+
+var _ webhook.CustomValidator = &SecretsRefreshCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type SecretsRefresh.
+func (v *SecretsRefreshCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	secretsrefresh, ok := obj.(*traktorv1beta1.SecretsRefresh)
+	if !ok {
+		return nil, fmt.Errorf("expected a SecretsRefresh object but got %T", obj)
+	}
+	secretsrefreshlog.Info("Validation for SecretsRefresh upon creation", "name", secretsrefresh.GetName())
+
+	return validateSecretsRefresh(secretsrefresh, v.namespacedJobTemplatesAllowed(ctx))
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type SecretsRefresh.
+func (v *SecretsRefreshCustomValidator) ValidateUpdate(ctx context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	secretsrefresh, ok := newObj.(*traktorv1beta1.SecretsRefresh)
+	if !ok {
+		return nil, fmt.Errorf("expected a SecretsRefresh object for the newObj but got %T", newObj)
+	}
+	secretsrefreshlog.Info("Validation for SecretsRefresh upon update", "name", secretsrefresh.GetName())
+
+	return validateSecretsRefresh(secretsrefresh, v.namespacedJobTemplatesAllowed(ctx))
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type SecretsRefresh.
+func (v *SecretsRefreshCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// namespacedJobTemplatesAllowed reports whether the TraktorConfig lets namespaced
+// SecretsRefresh objects run actions from inline job templates
+func (v *SecretsRefreshCustomValidator) namespacedJobTemplatesAllowed(ctx context.Context) bool {
+	if v.Reader == nil {
+		return false
+	}
+	config := &traktorv1beta1.TraktorConfig{}
+	if err := v.Reader.Get(ctx, client.ObjectKey{Name: traktorv1beta1.TraktorConfigName}, config); err != nil {
+		return false
+	}
+	return config.Spec.Features.NamespacedJobTemplates
+}
+
+// validateSecretsRefresh validates a SecretsRefresh
+func validateSecretsRefresh(sr *traktorv1beta1.SecretsRefresh, allowJobTemplates bool) (admission.Warnings, error) {
+	warnings, allErrs := validateSecretsRefreshSpec(&sr.Spec, false)
+	allErrs = append(allErrs, validateNamespacedActions(sr, allowJobTemplates)...)
+	if len(allErrs) == 0 {
+		return warnings, nil
+	}
+
+	return warnings, apierrors.NewInvalid(
+		traktorv1beta1.GroupVersion.WithKind("SecretsRefresh").GroupKind(),
+		sr.Name, allErrs)
+}
+
+// validateSecretsRefreshSpec collects all errors and warnings of a SecretsRefresh or
+// ClusterSecretsRefresh spec
+func validateSecretsRefreshSpec(spec *traktorv1beta1.SecretsRefreshSpec, cluster bool) (admission.Warnings, field.ErrorList) {
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	targetsPath := specPath.Child("targets")
+	triggersPath := specPath.Child("triggers")
+	rolloutPath := specPath.Child("rollout")
+
+	targets := &spec.Targets
+	allErrs = append(allErrs, metav1validation.ValidateLabelSelector(targets.NamespaceSelector,
+		metav1validation.LabelSelectorValidationOptions{}, targetsPath.Child("namespaceSelector"))...)
+	allErrs = append(allErrs, metav1validation.ValidateLabelSelector(targets.SecretSelector,
+		metav1validation.LabelSelectorValidationOptions{}, targetsPath.Child("secretSelector"))...)
+
+	switch {
+	case targets.AllNamespaces && !emptySelector(targets.NamespaceSelector):
+		allErrs = append(allErrs, field.Forbidden(targetsPath.Child("allNamespaces"), "may not be set together with namespaceSelector"))
+	case cluster && !targets.AllNamespaces && emptySelector(targets.NamespaceSelector):
+		allErrs = append(allErrs, field.Required(targetsPath.Child("namespaceSelector"),
+			"an empty selector would select every namespace, set allNamespaces: true to confirm"))
+	}
+	if emptySelector(targets.SecretSelector) {
+		warnings = append(warnings, "spec.targets.secretSelector is empty, every secret of the selected namespaces is watched")
+	}
+
+	triggers := &spec.Triggers
+	if triggers.RestartWhen != "" {
+		if _, err := policy.CompileRestartWhen(triggers.RestartWhen); err != nil {
+			allErrs = append(allErrs, field.Invalid(triggersPath.Child("restartWhen"), triggers.RestartWhen, err.Error()))
+		}
+	}
+
+	if cb := triggers.ChangedBy; cb != nil {
+		changedByPath := triggersPath.Child("changedBy")
+		for i, pattern := range cb.Allow {
+			if _, err := path.Match(pattern, ""); err != nil {
+				allErrs = append(allErrs, field.Invalid(changedByPath.Child("allow").Index(i), pattern, err.Error()))
+			}
+		}
+		for i, pattern := range cb.Deny {
+			if _, err := path.Match(pattern, ""); err != nil {
+				allErrs = append(allErrs, field.Invalid(changedByPath.Child("deny").Index(i), pattern, err.Error()))
+			}
+		}
+	}
+
+	if fd := triggers.FlapDetection; fd != nil && fd.Window.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(triggersPath.Child("flapDetection", "window"), fd.Window.Duration.String(), "must be positive"))
+	}
+
+	if vs := triggers.VersionedSecrets; vs != nil && vs.Retention != nil && vs.Retention.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(triggersPath.Child("versionedSecrets", "retention"), vs.Retention.Duration.String(), "must not be negative"))
+	}
+
+	rollout := &spec.Rollout
+	if rollout.Approval.TTL != nil && !rollout.Approval.Required {
+		// Kept when approval is turned off again, so only a warning
+		warnings = append(warnings, "spec.rollout.approval.ttl has no effect without spec.rollout.approval.required")
+	}
+
+	if rollout.GitOps != nil && rollout.Strategy != "" {
+		allErrs = append(allErrs, field.Forbidden(rolloutPath.Child("strategy"), "may not be set together with gitOps, which takes precedence"))
+	}
+
+	if rollout.DisruptionPolicy == traktorv1beta1.DisruptionPolicySurgeTemporarily &&
+		(rollout.GitOps != nil || (rollout.Strategy != "" && rollout.Strategy != traktorv1beta1.RestartStrategyTemplateAnnotation)) {
+		allErrs = append(allErrs, field.Invalid(rolloutPath.Child("disruptionPolicy"), rollout.DisruptionPolicy,
+			"SurgeTemporarily requires the TemplateAnnotation restart strategy"))
+	}
+
+	for i, w := range rollout.MaintenanceWindows {
+		if _, err := policy.ParseWindow(w); err != nil {
+			allErrs = append(allErrs, field.Invalid(rolloutPath.Child("maintenanceWindows").Index(i), w, err.Error()))
+		}
+	}
+
+	if rollout.Approval.TTL != nil && rollout.Approval.TTL.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(rolloutPath.Child("approval", "ttl"), rollout.Approval.TTL.Duration.String(), "must be positive"))
+	}
+
+	if rollout.Cooldown != nil && rollout.Cooldown.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(rolloutPath.Child("cooldown"), rollout.Cooldown.Duration.String(), "must not be negative"))
+	}
+
+	if rb := rollout.Rollback; rb != nil && rb.Timeout != nil && rb.Timeout.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(rolloutPath.Child("rollback", "timeout"), rb.Timeout.Duration.String(), "must be positive"))
+	}
+
+	for i, action := range spec.Actions {
+		actionPath := specPath.Child("actions").Index(i)
+		runJob := action.RunJob
+		if runJob == nil {
+			allErrs = append(allErrs, field.Required(actionPath.Child("runJob"), "required for RunJob actions"))
+			continue
+		}
+		if (runJob.JobTemplate == nil) == (runJob.CronJobRef == nil) {
+			allErrs = append(allErrs, field.Invalid(actionPath.Child("runJob"), action.Name, "exactly one of jobTemplate and cronJobRef must be set"))
+		}
+	}
+
+	if n := spec.Notifications; n != nil {
+		// The operator posts from inside the cluster, tenants must not pick the targets
+		if !cluster {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("notifications"),
+				"only a ClusterSecretsRefresh may send notifications, use the notifiers of the TraktorConfig instead"))
+		}
+		for i, hook := range n.Webhooks {
+			if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("notifications", "webhooks").Index(i).Child("url"), hook.URL, "must be an absolute http or https URL"))
+			}
+		}
+	}
+
+	return warnings, allErrs
+}
+
+// validateNamespacedActions rejects actions a namespaced SecretsRefresh may not run: the
+// operator creates their Jobs with its own permissions, so they must not come from
+// another namespace or, unless the TraktorConfig allows it, from an inline template
+func validateNamespacedActions(sr *traktorv1beta1.SecretsRefresh, allowJobTemplates bool) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, action := range sr.Spec.Actions {
+		runJob := action.RunJob
+		if runJob == nil {
+			continue
+		}
+		runJobPath := field.NewPath("spec", "actions").Index(i).Child("runJob")
+		// An empty namespace resolves to the namespace of the SecretsRefresh
+		if ref := runJob.CronJobRef; ref != nil && ref.Namespace != "" && ref.Namespace != sr.Namespace {
+			allErrs = append(allErrs, field.Forbidden(runJobPath.Child("cronJobRef", "namespace"),
+				"a SecretsRefresh may only reference CronJobs in its own namespace"))
+		}
+		if runJob.JobTemplate != nil && !allowJobTemplates {
+			allErrs = append(allErrs, field.Forbidden(runJobPath.Child("jobTemplate"),
+				"only a ClusterSecretsRefresh may set a jobTemplate unless the TraktorConfig enables features.namespacedJobTemplates; reference a CronJob instead"))
+		}
+	}
+	return allErrs
+}
+
+// emptySelector reports whether the label selector selects everything
+func emptySelector(sel *metav1.LabelSelector) bool {
+	return sel == nil || (len(sel.MatchLabels) == 0 && len(sel.MatchExpressions) == 0)
+}
+
+// defaultSecretsRefreshSpec fills in the policy defaults of a SecretsRefresh or
+// ClusterSecretsRefresh spec. The restart strategy is left empty because the first
+// matching SecretsRefresh that sets one wins.
+func defaultSecretsRefreshSpec(spec *traktorv1beta1.SecretsRefreshSpec) {
+	rollout := &spec.Rollout
+	if rollout.DisruptionPolicy == "" {
+		rollout.DisruptionPolicy = traktorv1beta1.DisruptionPolicyWarn
+	}
+	if rollout.Approval.Required && rollout.Approval.TTL == nil {
+		rollout.Approval.TTL = &metav1.Duration{Duration: traktorv1beta1.DefaultApprovalTTL}
+	}
+	if gitOps := rollout.GitOps; gitOps != nil {
+		if gitOps.Mode == "" {
+			gitOps.Mode = traktorv1beta1.GitOpsModeServerSideApply
+		}
+		if gitOps.Mode == traktorv1beta1.GitOpsModeServerSideApply {
+			if gitOps.FieldManager == "" {
+				gitOps.FieldManager = traktorv1beta1.DefaultFieldManager
+			}
+			if len(gitOps.Managers) == 0 {
+				gitOps.Managers = slices.Clone(traktorv1beta1.DefaultGitOpsManagers)
+			}
+		}
+	}
+	if rb := rollout.Rollback; rb != nil {
+		if rb.FailureThreshold == 0 {
+			rb.FailureThreshold = traktorv1beta1.DefaultRollbackThreshold
+		}
+		if rb.Timeout == nil {
+			rb.Timeout = &metav1.Duration{Duration: traktorv1beta1.DefaultRollbackTimeout}
+		}
+	}
+	for i := range spec.Actions {
+		if spec.Actions[i].Type == "" {
+			spec.Actions[i].Type = traktorv1beta1.ActionTypeRunJob
+		}
+		if spec.Actions[i].Order == "" {
+			spec.Actions[i].Order = traktorv1beta1.ActionOrderAfter
+		}
+	}
+	if vs := spec.Triggers.VersionedSecrets; vs != nil {
+		if vs.SeriesLabel == "" {
+			vs.SeriesLabel = traktorv1beta1.DefaultSeriesLabel
+		}
+		if vs.Retention == nil {
+			vs.Retention = &metav1.Duration{Duration: traktorv1beta1.DefaultVersionRetention}
+		}
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+var _ = Describe("SecretsRefresh Webhook", func() {
+	var (
+		ctx       context.Context
+		obj       *traktorv1beta1.SecretsRefresh
+		oldObj    *traktorv1beta1.SecretsRefresh
+		validator SecretsRefreshCustomValidator
+		defaulter SecretsRefreshCustomDefaulter
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		obj = &traktorv1beta1.SecretsRefresh{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-sr", Namespace: "default"},
+		}
+		oldObj = obj.DeepCopy()
+		validator = SecretsRefreshCustomValidator{}
+		defaulter = SecretsRefreshCustomDefaulter{}
+	})
+
+	Context("When validating selectors", func() {
+		It("should deny invalid namespace and secret selectors", func() {
+			obj.Spec.Targets.NamespaceSelector = &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Matches"}},
+			}
+			obj.Spec.Targets.SecretSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{"auto-refresh": "not a valid value!"},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.targets.namespaceSelector.matchExpressions[0].operator"))
+			Expect(err.Error()).To(ContainSubstring("spec.targets.secretSelector.matchLabels"))
+		})
+
+		It("should admit an empty namespace selector of a SecretsRefresh and warn about an empty secret selector", func() {
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ContainElement(ContainSubstring("spec.targets.secretSelector")))
+		})
+
+		It("should deny allNamespaces together with a namespace selector", func() {
+			obj.Spec.Targets.AllNamespaces = true
+			obj.Spec.Targets.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "backend"}}
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.targets.allNamespaces"))
+		})
+	})
+
+	Context("When validating conflicting fields", func() {
+		It("should deny a restart strategy together with gitOps", func() {
+			obj.Spec.Rollout.Strategy = traktorv1beta1.RestartStrategyDeletePods
+			obj.Spec.Rollout.GitOps = &traktorv1beta1.GitOps{}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.rollout.strategy"))
+		})
+
+		It("should deny SurgeTemporarily with a strategy that doesn't patch the pod template", func() {
+			obj.Spec.Rollout.DisruptionPolicy = traktorv1beta1.DisruptionPolicySurgeTemporarily
+			obj.Spec.Rollout.Strategy = traktorv1beta1.RestartStrategyScaleBounce
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.rollout.disruptionPolicy"))
+
+			obj.Spec.Rollout.Strategy = traktorv1beta1.RestartStrategyTemplateAnnotation
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should warn about an approvalTTL without approval", func() {
+			obj.Spec.Rollout.Approval.TTL = &metav1.Duration{Duration: time.Hour}
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ContainElement(ContainSubstring("spec.rollout.approval.ttl")))
+		})
+	})
+
+	Context("When defaulting", func() {
+		It("should fill in the policy defaults", func() {
+			obj.Spec.Rollout.Approval.Required = true
+			obj.Spec.Rollout.GitOps = &traktorv1beta1.GitOps{}
+			obj.Spec.Actions = []traktorv1beta1.Action{{Name: "sync"}}
+			obj.Spec.Rollout.Rollback = &traktorv1beta1.Rollback{}
+			obj.Spec.Triggers.VersionedSecrets = &traktorv1beta1.VersionedSecrets{}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+			Expect(obj.Spec.Rollout.DisruptionPolicy).To(Equal(traktorv1beta1.DisruptionPolicyWarn))
+			Expect(obj.Spec.Rollout.Strategy).To(BeEmpty())
+			Expect(obj.Spec.Rollout.Approval.TTL.Duration).To(Equal(24 * time.Hour))
+			Expect(obj.Spec.Rollout.GitOps.Mode).To(Equal(traktorv1beta1.GitOpsModeServerSideApply))
+			Expect(obj.Spec.Rollout.GitOps.FieldManager).To(Equal("traktor"))
+			Expect(obj.Spec.Rollout.GitOps.Managers).To(ContainElement("argocd-controller"))
+			Expect(obj.Spec.Actions[0].Type).To(Equal(traktorv1beta1.ActionTypeRunJob))
+			Expect(obj.Spec.Actions[0].Order).To(Equal(traktorv1beta1.ActionOrderAfter))
+			Expect(obj.Spec.Rollout.Rollback.FailureThreshold).To(BeEquivalentTo(100))
+			Expect(obj.Spec.Rollout.Rollback.Timeout.Duration).To(Equal(10 * time.Minute))
+			Expect(obj.Spec.Triggers.VersionedSecrets.SeriesLabel).To(Equal("traktor.gdxcloud.net/series"))
+			Expect(obj.Spec.Triggers.VersionedSecrets.Retention.Duration).To(Equal(24 * time.Hour))
+		})
+
+		It("should keep values that are set", func() {
+			obj.Spec.Rollout.DisruptionPolicy = traktorv1beta1.DisruptionPolicySkip
+			obj.Spec.Rollout.GitOps = &traktorv1beta1.GitOps{Mode: traktorv1beta1.GitOpsModeRecyclePods}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+			Expect(obj.Spec.Rollout.DisruptionPolicy).To(Equal(traktorv1beta1.DisruptionPolicySkip))
+			Expect(obj.Spec.Rollout.Approval.TTL).To(BeNil())
+			Expect(obj.Spec.Rollout.GitOps.FieldManager).To(BeEmpty())
+			Expect(obj.Spec.Rollout.GitOps.Managers).To(BeEmpty())
+		})
+	})
+
+	Context("When validating restartWhen", func() {
+		It("should admit an empty expression", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should admit a valid boolean expression", func() {
+			obj.Spec.Triggers.RestartWhen = `newSecret.metadata.annotations['rotation-id'] != oldSecret.metadata.annotations['rotation-id']`
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should deny an expression with a syntax error on create", func() {
+			obj.Spec.Triggers.RestartWhen = `newSecret.metadata.annotations[`
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.triggers.restartWhen"))
+		})
+
+		It("should deny an expression referencing an unknown variable on update", func() {
+			obj.Spec.Triggers.RestartWhen = `deployment.spec.replicas > 1`
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("undeclared reference"))
+		})
+
+		It("should deny an expression that does not return a bool", func() {
+			obj.Spec.Triggers.RestartWhen = `workload.metadata.name`
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("must return bool"))
+		})
+
+		It("should deny an expression whose estimated cost is over budget", func() {
+			obj.Spec.Triggers.RestartWhen = `newSecret.data.exists(k, oldSecret.data.exists(l, newSecret.data[k] == oldSecret.data[l]))`
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.triggers.restartWhen"))
+			Expect(err.Error()).To(ContainSubstring("too expensive"))
+		})
+	})
+
+	Context("When validating maintenance windows", func() {
+		It("should admit a valid window", func() {
+			obj.Spec.Rollout.MaintenanceWindows = []traktorv1beta1.MaintenanceWindow{{
+				Schedule: "0 22 * * 1-5",
+				Duration: metav1.Duration{Duration: 2 * time.Hour},
+				TimeZone: "Europe/Berlin",
+			}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should deny invalid schedules, durations and time zones", func() {
+			obj.Spec.Rollout.MaintenanceWindows = []traktorv1beta1.MaintenanceWindow{
+				{Schedule: "every night", Duration: metav1.Duration{Duration: time.Hour}},
+				{Schedule: "0 22 * * *"},
+				{Schedule: "0 22 * * *", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Mars/Olympus"},
+				{Schedule: "0 0 30 2 *", Duration: metav1.Duration{Duration: time.Hour}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.rollout.maintenanceWindows[0]"))
+			Expect(err.Error()).To(ContainSubstring("spec.rollout.maintenanceWindows[1]"))
+			Expect(err.Error()).To(ContainSubstring("spec.rollout.maintenanceWindows[2]"))
+			Expect(err.Error()).To(ContainSubstring("spec.rollout.maintenanceWindows[3]"))
+			Expect(err.Error()).To(ContainSubstring("never fires"))
+		})
+	})
+	Context("When validating approval", func() {
+		It("should deny a non-positive approvalTTL", func() {
+			obj.Spec.Rollout.Approval.Required = true
+			obj.Spec.Rollout.Approval.TTL = &metav1.Duration{Duration: 0}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.rollout.approval.ttl"))
+		})
+	})
+	Context("When validating cooldown and flap detection", func() {
+		It("should deny a negative cooldown and an empty flap detection window", func() {
+			obj.Spec.Rollout.Cooldown = &metav1.Duration{Duration: -time.Minute}
+			obj.Spec.Triggers.FlapDetection = &traktorv1beta1.FlapDetection{MaxChanges: 3}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.rollout.cooldown"))
+			Expect(err.Error()).To(ContainSubstring("spec.triggers.flapDetection.window"))
+		})
+	})
+	Context("When validating actions", func() {
+		It("should deny RunJob actions without exactly one Job source", func() {
+			obj.Spec.Actions = []traktorv1beta1.Action{
+				{Name: "missing"},
+				{Name: "both", RunJob: &traktorv1beta1.RunJobAction{
+					JobTemplate: &batchv1.JobTemplateSpec{},
+					CronJobRef:  &traktorv1beta1.CronJobReference{Name: "sync"},
+				}},
+				{Name: "valid", RunJob: &traktorv1beta1.RunJobAction{
+					CronJobRef: &traktorv1beta1.CronJobReference{Name: "sync"},
+				}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.actions[0].runJob"))
+			Expect(err.Error()).To(ContainSubstring("spec.actions[1].runJob"))
+			Expect(err.Error()).NotTo(ContainSubstring("spec.actions[2]"))
+		})
+		It("should deny CronJobs of other namespaces", func() {
+			obj.Spec.Actions = []traktorv1beta1.Action{
+				{Name: "foreign", RunJob: &traktorv1beta1.RunJobAction{
+					CronJobRef: &traktorv1beta1.CronJobReference{Name: "sync", Namespace: "kube-system"},
+				}},
+				{Name: "own", RunJob: &traktorv1beta1.RunJobAction{
+					CronJobRef: &traktorv1beta1.CronJobReference{Name: "sync", Namespace: "default"},
+				}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.actions[0].runJob.cronJobRef.namespace"))
+			Expect(err.Error()).NotTo(ContainSubstring("spec.actions[1]"))
+		})
+
+		It("should deny job templates unless the TraktorConfig allows them", func() {
+			obj.Spec.Actions = []traktorv1beta1.Action{{Name: "inline", RunJob: &traktorv1beta1.RunJobAction{
+				JobTemplate: &batchv1.JobTemplateSpec{},
+			}}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.actions[0].runJob.jobTemplate"))
+
+			By("enabling namespaced job templates in the TraktorConfig")
+			config := &traktorv1beta1.TraktorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: traktorv1beta1.TraktorConfigName},
+				Spec: traktorv1beta1.TraktorConfigSpec{
+					Features: traktorv1beta1.Features{NamespacedJobTemplates: true},
+				},
+			}
+			scheme := runtime.NewScheme()
+			Expect(traktorv1beta1.AddToScheme(scheme)).To(Succeed())
+			validator.Reader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(config).Build()
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+	Context("When validating rollback", func() {
+		It("should deny a non-positive rollback timeout", func() {
+			obj.Spec.Rollout.Rollback = &traktorv1beta1.Rollback{Timeout: &metav1.Duration{}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.rollout.rollback.timeout"))
+		})
+	})
+	Context("When validating versioned secrets", func() {
+		It("should deny a negative retention", func() {
+			obj.Spec.Triggers.VersionedSecrets = &traktorv1beta1.VersionedSecrets{Retention: &metav1.Duration{Duration: -time.Hour}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.triggers.versionedSecrets.retention"))
+		})
+	})
+	Context("When validating changedBy", func() {
+		It("should deny malformed field manager patterns", func() {
+			obj.Spec.Triggers.ChangedBy = &traktorv1beta1.ChangedBy{
+				Allow: []string{"external-secrets", "vault-*"},
+				Deny:  []string{"kubectl-["},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.triggers.changedBy.deny[0]"))
+			Expect(err.Error()).NotTo(ContainSubstring("spec.triggers.changedBy.allow"))
+		})
+	})
+	Context("When validating notifications", func() {
+		It("should deny notifications of a namespaced SecretsRefresh", func() {
+			obj.Spec.Notifications = &traktorv1beta1.Notifications{
+				Webhooks: []traktorv1beta1.NotificationWebhook{
+					{Name: "chat", URL: "https://chat.example.com/hooks/traktor"},
+				},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.notifications: Forbidden"))
+		})
+	})
+})
@@ -0,0 +1,119 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var traktorconfiglog = logf.Log.WithName("traktorconfig-resource")
+
+// SetupTraktorConfigWebhookWithManager registers the webhook for TraktorConfig in the manager.
+func SetupTraktorConfigWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&traktorv1beta1.TraktorConfig{}).
+		WithValidator(&TraktorConfigCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-traktor-gdxcloud-net-v1beta1-traktorconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=traktor.gdxcloud.net,resources=traktorconfigs,verbs=create;update,versions=v1beta1,name=vtraktorconfig-v1beta1.kb.io,admissionReviewVersions=v1
+
+// TraktorConfigCustomValidator validates TraktorConfig resources when they are created or updated,
+// so an invalid configuration is rejected instead of being ignored by the operator.
+type TraktorConfigCustomValidator struct{}
+
+var _ webhook.CustomValidator = &TraktorConfigCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type TraktorConfig.
+func (v *TraktorConfigCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	traktorconfig, ok := obj.(*traktorv1beta1.TraktorConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected a TraktorConfig object but got %T", obj)
+	}
+	traktorconfiglog.Info("Validation for TraktorConfig upon creation", "name", traktorconfig.GetName())
+
+	return nil, validateTraktorConfig(traktorconfig)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type TraktorConfig.
+func (v *TraktorConfigCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	traktorconfig, ok := newObj.(*traktorv1beta1.TraktorConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected a TraktorConfig object for the newObj but got %T", newObj)
+	}
+	traktorconfiglog.Info("Validation for TraktorConfig upon update", "name", traktorconfig.GetName())
+
+	return nil, validateTraktorConfig(traktorconfig)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type TraktorConfig.
+func (v *TraktorConfigCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateTraktorConfig rejects what the operator refuses to apply, the schema of the CRD validates the rest.
+// It mirrors the validation of the TraktorConfig reconciler.
+func validateTraktorConfig(config *traktorv1beta1.TraktorConfig) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	protectedPath := specPath.Child("protected")
+	for i, namespace := range config.Spec.Protected.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			allErrs = append(allErrs, field.Invalid(protectedPath.Child("namespaces").Index(i), namespace, msg))
+		}
+	}
+	for i, ref := range config.Spec.Protected.Workloads {
+		workloadPath := protectedPath.Child("workloads").Index(i)
+		if ref.Kind != "Deployment" {
+			allErrs = append(allErrs, field.NotSupported(workloadPath.Child("kind"), ref.Kind, []string{"Deployment"}))
+		}
+		if ref.Namespace == "" {
+			allErrs = append(allErrs, field.Required(workloadPath.Child("namespace"), "protected workloads must name their namespace"))
+		}
+	}
+	allErrs = append(allErrs, metav1validation.ValidateLabelSelector(config.Spec.Protected.Selector,
+		metav1validation.LabelSelectorValidationOptions{}, protectedPath.Child("selector"))...)
+
+	for i, notifier := range config.Spec.Notifiers {
+		if u, err := url.Parse(notifier.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("notifiers").Index(i).Child("url"), notifier.URL, "must be an absolute http or https URL"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		traktorv1beta1.GroupVersion.WithKind("TraktorConfig").GroupKind(),
+		config.Name, allErrs)
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2026.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	traktorv1beta1 "github.com/GDXbsv/traktor/api/v1beta1"
+)
+
+var _ = Describe("TraktorConfig Webhook", func() {
+	var (
+		ctx       context.Context
+		obj       *traktorv1beta1.TraktorConfig
+		validator TraktorConfigCustomValidator
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		obj = &traktorv1beta1.TraktorConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: traktorv1beta1.TraktorConfigName},
+			Spec: traktorv1beta1.TraktorConfigSpec{
+				Protected: traktorv1beta1.Protection{
+					Namespaces: []string{"kube-system"},
+					Workloads:  []traktorv1beta1.WorkloadReference{{Kind: "Deployment", Name: "coredns", Namespace: "kube-system"}},
+				},
+				Notifiers: []traktorv1beta1.NotificationWebhook{{Name: "audit", URL: "https://audit.example.com/refreshes"}},
+			},
+		}
+		validator = TraktorConfigCustomValidator{}
+	})
+
+	Context("When validating a TraktorConfig", func() {
+		It("should admit a valid configuration", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = validator.ValidateUpdate(ctx, obj, obj)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should deny notifiers without an http or https URL", func() {
+			obj.Spec.Notifiers = append(obj.Spec.Notifiers,
+				traktorv1beta1.NotificationWebhook{Name: "nohost", URL: "https://"},
+				traktorv1beta1.NotificationWebhook{Name: "gopher", URL: "gopher://audit.example.com"})
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.notifiers[1].url"))
+			Expect(err.Error()).To(ContainSubstring("spec.notifiers[2].url"))
+			Expect(err.Error()).NotTo(ContainSubstring("spec.notifiers[0].url"))
+		})
+
+		It("should deny protections the operator can't apply", func() {
+			obj.Spec.Protected = traktorv1beta1.Protection{
+				Namespaces: []string{"Kube_System"},
+				Workloads: []traktorv1beta1.WorkloadReference{
+					{Kind: "StatefulSet", Name: "db", Namespace: "data"},
+					{Kind: "Deployment", Name: "coredns"},
+				},
+				Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "tier", Operator: metav1.LabelSelectorOpIn},
+				}},
+			}
+			_, err := validator.ValidateUpdate(ctx, obj, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.protected.namespaces[0]"))
+			Expect(err.Error()).To(ContainSubstring("spec.protected.workloads[0].kind"))
+			Expect(err.Error()).To(ContainSubstring("spec.protected.workloads[1].namespace"))
+			Expect(err.Error()).To(ContainSubstring("spec.protected.selector"))
+		})
+	})
+})